@@ -2,65 +2,21 @@ SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0;
 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0;
 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE="TRADITIONAL";
 
-DROP SCHEMA IF EXISTS turnos_odontologia;
-CREATE SCHEMA turnos_odontologia;
-USE turnos_odontologia;
-
---
--- Table structure for table `odontologos`
---
-DROP TABLE IF EXISTS odontologos;
-CREATE TABLE odontologos (
-  idOdontologo INT UNSIGNED NOT NULL AUTO_INCREMENT,
-  nombreOdontologo VARCHAR(50) NOT NULL,
-  apellidoOdontologo VARCHAR(50) NOT NULL,
-  matriculaOdontologo VARCHAR(50) NOT NULL,
-  PRIMARY KEY (idOdontologo)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
 --
--- Table structure for table `pacientes`
+-- Datos de ejemplo. El esquema lo crean las migraciones embebidas en el
+-- servidor: ejecutar primero `server migrate up` sobre la base vacía.
 --
-DROP TABLE IF EXISTS pacientes;
-CREATE TABLE pacientes (
-  idPaciente INT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT "id de la tabla pacientes",
-  nombrePaciente varchar(50) NOT NULL,
-  apellidoPaciente varchar(50) NOT NULL,
-  domicilioPaciente varchar(50) NOT NULL,
-  dniPaciente varchar(50) NOT NULL,
-  fechaDeAltaPaciente datetime NOT NULL,
-  PRIMARY KEY (idPaciente)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-
---
--- Table structure for table `turnos`
---
-DROP TABLE IF EXISTS turnos;
-CREATE TABLE turnos (
-  idTurno INT UNSIGNED NOT NULL AUTO_INCREMENT,
-  descripcionTurno VARCHAR(150) NOT NULL,
-  fechaTurno DATETIME NOT NULL,
-  idOdontologo INT UNSIGNED NOT NULL,
-  idPaciente INT UNSIGNED NOT NULL,
-  PRIMARY KEY (idTurno),
-  KEY fk_odontologos_turnos(idOdontologo),
-  CONSTRAINT fk_odontologos_turnos FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)  
-  ON DELETE CASCADE
-  ON UPDATE CASCADE,
-  KEY fk_pacientes_turnos(idPaciente),
-  CONSTRAINT fk_pacientes_turnos FOREIGN KEY (idPaciente) REFERENCES pacientes(idPaciente)  
-  ON DELETE CASCADE
-  ON UPDATE CASCADE
-)ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE SCHEMA IF NOT EXISTS turnos_odontologia;
+USE turnos_odontologia;
 
 --
 -- Dumping data for table `pacientes`
 --
 SET AUTOCOMMIT=0;
-INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaAltaPaciente) VALUES ("Mercedes","Allende","5 1466", "10435935", "1953-09-28");
-INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaAltaPaciente) VALUES ("Carmen","Cingolani","21 1225", "17005342", "1970-07-01");
-INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaAltaPaciente) VALUES ("Francisco","Miguez","76 390", "31824331", "1985-10-09");
-INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaAltaPaciente) VALUES ("Diego","Lopez","9 1189", "25047223", "1977-06-27");
+INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente) VALUES ("Mercedes","Allende","5 1466", "10435935", "1953-09-28");
+INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente) VALUES ("Carmen","Cingolani","21 1225", "17005342", "1970-07-01");
+INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente) VALUES ("Francisco","Miguez","76 390", "31824331", "1985-10-09");
+INSERT INTO pacientes(nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente) VALUES ("Diego","Lopez","9 1189", "25047223", "1977-06-27");
 COMMIT;
 
 --
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+// horariosRequest es el cuerpo del reemplazo del horario semanal.
+type horariosRequest struct {
+	Horarios []domain.Horario `json:"horarios"`
+}
+
+// excepcionRequest es el cuerpo del alta de una excepción.
+type excepcionRequest struct {
+	Desde  string `json:"desde" binding:"required"`
+	Hasta  string `json:"hasta" binding:"required"`
+	Motivo string `json:"motivo" binding:"required"`
+}
+
+// GET
+// @Summary Obtener la agenda de un odontólogo
+// @Description Obtiene el horario semanal y las excepciones del odontólogo. Sin horarios el odontólogo atiende a cualquier hora
+// @Tags Odontologos
+// @Produce json
+// @Param idOdontologo path int true "ID del odontólogo"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Router /api/v1/odontologos/{idOdontologo}/agenda [get]
+func (h *odontologoHandler) GetAgenda() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idOdontologo"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		agenda, err := h.s.GetAgenda(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, agenda, "Agenda del odontólogo")
+	}
+}
+
+// PUT
+// @Summary Reemplazar el horario semanal de un odontólogo
+// @Description Reemplaza todas las franjas del horario semanal. dia va de 0 (domingo) a 6 (sábado) y desde y hasta son horas 15:04 en UTC. Una lista vacía quita la restricción horaria
+// @Tags Odontologos
+// @Produce json
+// @Param idOdontologo path int true "ID del odontólogo"
+// @Param body body horariosRequest true "Franjas del horario semanal"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/odontologos/{idOdontologo}/agenda/horarios [put]
+func (h *odontologoHandler) UpdateHorarios() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idOdontologo"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		var request horariosRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			web.Failure(c, 400, bindError(err))
+			return
+		}
+		agenda, err := h.s.UpdateHorarios(c.Request.Context(), id, request.Horarios)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, agenda, "El horario del odontólogo ha sido actualizado")
+	}
+}
+
+// POST
+// @Summary Agregar una excepción a la agenda de un odontólogo
+// @Description Registra un período, por ejemplo vacaciones, en el que el odontólogo no atiende. desde y hasta son días 2006-01-02 y ambos se incluyen
+// @Tags Odontologos
+// @Produce json
+// @Param idOdontologo path int true "ID del odontólogo"
+// @Param body body excepcionRequest true "Período y motivo de la excepción"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/odontologos/{idOdontologo}/agenda/excepciones [post]
+func (h *odontologoHandler) CreateExcepcion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idOdontologo"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		var request excepcionRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			web.Failure(c, 400, bindError(err))
+			return
+		}
+		excepcion, err := h.s.CreateExcepcion(c.Request.Context(), domain.Excepcion{
+			IdOdontologo: id,
+			Desde:        request.Desde,
+			Hasta:        request.Hasta,
+			Motivo:       request.Motivo,
+		})
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 201, excepcion, "La excepción ha sido creada correctamente")
+	}
+}
+
+// DELETE
+// @Summary Eliminar una excepción de la agenda de un odontólogo
+// @Description Elimina la excepción; los turnos de ese período vuelven a estar permitidos
+// @Tags Odontologos
+// @Produce json
+// @Param idOdontologo path int true "ID del odontólogo"
+// @Param idExcepcion path int true "ID de la excepción"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Router /api/v1/odontologos/{idOdontologo}/agenda/excepciones/{idExcepcion} [delete]
+func (h *odontologoHandler) DeleteExcepcion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idOdontologo"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		idExcepcion, err := strconv.Atoi(c.Param("idExcepcion"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID de excepción inválido"))
+			return
+		}
+		if err := h.s.DeleteExcepcion(c.Request.Context(), id, idExcepcion); err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, nil, "La excepción ha sido eliminada")
+	}
+}
+
+// GET
+// @Summary Buscar turnos libres de un odontólogo
+// @Description Calcula los turnos libres según el horario semanal, las excepciones, los feriados y los turnos ya reservados. Sin desde busca a partir de ahora y sin hasta durante siete días, con un máximo de 31
+// @Tags Odontologos
+// @Produce json
+// @Param idOdontologo path int true "ID del odontólogo"
+// @Param desde query string false "Comienzo del rango"
+// @Param hasta query string false "Fin del rango; una fecha sin hora incluye el día completo"
+// @Param duracion query int false "Duración del turno en minutos"
+// @Param paso query int false "Minutos entre comienzos posibles; por defecto la granularidad configurada"
+// @Param limit query int false "Cantidad máxima de turnos libres (por defecto 100)"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/odontologos/{idOdontologo}/disponibilidad [get]
+func (h *odontologoHandler) GetDisponibilidad() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idOdontologo"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		consulta, err := consultaDisponibilidad(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		if limit := c.Query("limit"); limit != "" {
+			if consulta.Limite, err = strconv.Atoi(limit); err != nil {
+				web.Failure(c, 400, errors.New("limit inválido"))
+				return
+			}
+		}
+		libres, err := h.s.Disponibilidad(c.Request.Context(), id, consulta)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		if libres == nil {
+			libres = []domain.TurnoLibre{}
+		}
+		web.Success(c, 200, libres, fmt.Sprintf("Se encontraron %d turnos libres", len(libres)))
+	}
+}
+
+// GET
+// @Summary Buscar el primer turno libre con cualquier odontólogo
+// @Description Devuelve el turno libre más temprano entre todos los odontólogos activos. Acepta los mismos parámetros que la disponibilidad de un odontólogo
+// @Tags Odontologos
+// @Produce json
+// @Param desde query string false "Comienzo del rango"
+// @Param hasta query string false "Fin del rango; una fecha sin hora incluye el día completo"
+// @Param duracion query int false "Duración del turno en minutos"
+// @Param paso query int false "Minutos entre comienzos posibles; por defecto la granularidad configurada"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/odontologos/disponibilidad [get]
+func (h *odontologoHandler) GetPrimerTurnoLibre() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		consulta, err := consultaDisponibilidad(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		libre, err := h.s.PrimerTurnoLibre(c.Request.Context(), consulta)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, libre, "Primer turno libre")
+	}
+}
+
+// consultaDisponibilidad lee los parámetros comunes de las búsquedas de
+// turnos libres. Los ausentes quedan en cero para que el servicio complete
+// sus valores por defecto.
+func consultaDisponibilidad(c *gin.Context) (domain.ConsultaDisponibilidad, error) {
+	var consulta domain.ConsultaDisponibilidad
+	var err error
+	if consulta.Desde, err = fechaQuery(c, "desde", false); err != nil {
+		return consulta, err
+	}
+	if consulta.Hasta, err = fechaQuery(c, "hasta", true); err != nil {
+		return consulta, err
+	}
+	if duracion := c.Query("duracion"); duracion != "" {
+		if consulta.DuracionMinutos, err = strconv.Atoi(duracion); err != nil {
+			return consulta, errors.New("duracion inválida")
+		}
+	}
+	if paso := c.Query("paso"); paso != "" {
+		if consulta.PasoMinutos, err = strconv.Atoi(paso); err != nil {
+			return consulta, errors.New("paso inválido")
+		}
+	}
+	return consulta, nil
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/auditoria"
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+type auditoriaHandler struct {
+	s auditoria.Service
+}
+
+func NewAuditoriaHandler(s auditoria.Service) *auditoriaHandler {
+	return &auditoriaHandler{
+		s: s,
+	}
+}
+
+// GET
+// @Summary Listar auditoría
+// @Description Lista los movimientos registrados filtrando por entidad, registro, actor y rango de fechas
+// @Tags Auditoria
+// @Produce json
+// @Param entidad query string false "odontologo, paciente o turno"
+// @Param idEntidad query int false "ID del registro auditado"
+// @Param actor query string false "Actor que hizo el cambio"
+// @Param desde query string false "Fecha mínima (inclusive), en RFC 3339, 2006-01-02 15:04 o 2006-01-02"
+// @Param hasta query string false "Fecha máxima (inclusive); si es sólo un día lo incluye completo"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param page query int false "Página"
+// @Param limit query int false "Registros por página"
+// @Success 200 {object} web.pageResponse
+// @Failure 400 {object} web.errorResponse
+// @Failure 401 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/audit [get]
+func (h *auditoriaHandler) GetAuditoria() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, err := parseListOptions(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		filter := domain.AuditoriaFilter{
+			ListOptions: opts,
+			Entidad:     c.Query("entidad"),
+			Actor:       c.Query("actor"),
+		}
+		if filter.Desde, err = fechaQuery(c, "desde", false); err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		if filter.Hasta, err = fechaQuery(c, "hasta", true); err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		if idEntidad := c.Query("idEntidad"); idEntidad != "" {
+			if filter.IdEntidad, err = strconv.Atoi(idEntidad); err != nil {
+				web.Failure(c, 400, errors.New("idEntidad inválido"))
+				return
+			}
+		}
+		entradas, total, err := h.s.List(c.Request.Context(), filter)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.SuccessPage(c, 200, entradas, opts.Page, opts.Limit, total, "Listado de auditoría")
+	}
+}
+
+// GET
+// @Summary Verificar auditoría
+// @Description Recorre la cadena de hashes e informa la primera entrada alterada
+// @Tags Auditoria
+// @Produce json
+// @Success 200 {object} web.response
+// @Failure 401 {object} web.errorResponse
+// @Router /api/v1/audit/verify [get]
+func (h *auditoriaHandler) VerificarAuditoria() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, err := h.s.Verificar(c.Request.Context())
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, v, "Verificación de la auditoría")
+	}
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+	"github.com/MechiBakker/BE3-FINAL/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+type cacheHandler struct {
+	c *store.CacheStore
+}
+
+func NewCacheHandler(c *store.CacheStore) *cacheHandler {
+	return &cacheHandler{
+		c: c,
+	}
+}
+
+// GET
+// @Summary Estadísticas del caché
+// @Description Retorna los aciertos, fallos y ocupación del caché de lecturas
+// @Tags Cache
+// @Produce json
+// @Success 200 {object} web.response
+// @Failure 401 {object} web.errorResponse
+// @Router /api/v1/cache/stats [get]
+func (h *cacheHandler) GetStats() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		web.Success(c, 200, h.c.Stats(), "Estadísticas del caché")
+	}
+}
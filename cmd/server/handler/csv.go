@@ -0,0 +1,345 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportRows limita las filas de un CSV de importación, que se procesa
+// completo en una transacción.
+const maxImportRows = 10000
+
+// csvColumn es una columna de los CSV de importación y exportación. Las
+// columnas sin set sólo se exportan, y las opcionales pueden faltar en un
+// CSV de importación.
+type csvColumn[T any] struct {
+	name     string
+	get      func(x *T) string
+	set      func(x *T, value string) error
+	optional bool
+}
+
+// csvText arma el set de una columna de texto.
+func csvText[T any](field func(x *T) *string) func(x *T, value string) error {
+	return func(x *T, value string) error {
+		*field(x) = value
+		return nil
+	}
+}
+
+// csvInt arma el set de una columna numérica. Una celda vacía deja el valor
+// en cero.
+func csvInt[T any](field func(x *T) *int) func(x *T, value string) error {
+	return func(x *T, value string) error {
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return domain.NewError(domain.ErrValidation, "%q no es un número entero", value)
+		}
+		*field(x) = n
+		return nil
+	}
+}
+
+// csvID exporta un ID opcional, con la celda vacía si es cero.
+func csvID(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.Itoa(id)
+}
+
+// csvFecha arma el set de una columna de fecha. Una celda vacía deja la
+// fecha en cero para que la informe la validación de campos vacíos.
+func csvFecha[T any](field func(x *T) *domain.Fecha) func(x *T, value string) error {
+	return func(x *T, value string) error {
+		if value == "" {
+			return nil
+		}
+		f, err := domain.ParseFecha(value)
+		if err != nil {
+			return err
+		}
+		*field(x) = f
+		return nil
+	}
+}
+
+// csvRow es una fila leída del CSV junto con su número de línea y el primer
+// error al interpretar sus celdas.
+type csvRow[T any] struct {
+	line int
+	item T
+	err  error
+}
+
+// csvError informa el error de una fila del CSV. Fila es el número de línea,
+// contando el encabezado como línea 1.
+type csvError struct {
+	Fila  int    `json:"fila"`
+	Error string `json:"error"`
+}
+
+// csvReport es la respuesta de una importación.
+type csvReport struct {
+	DryRun            bool       `json:"dryRun"`
+	Filas             int        `json:"filas"`
+	Importadas        int        `json:"importadas"`
+	ColumnasIgnoradas []string   `json:"columnasIgnoradas,omitempty"`
+	Errores           []csvError `json:"errores"`
+}
+
+// importCSV lee el CSV de la petición, valida cada fila con validate y da de
+// alta las válidas con importar. El CSV puede venir como cuerpo o como el
+// archivo "file" de un formulario multipart. La primera línea nombra las
+// columnas con los campos JSON de la entidad; el parámetro map permite
+// renombrarlas ("Nombre:nombrePaciente,DNI:dniPaciente") y delimiter cambia
+// el separador. Con dryRun=true se informa el resultado sin guardar nada.
+// Si alguna fila falla no se importa ninguna.
+func importCSV[T any](c *gin.Context, columns []csvColumn[T], validate func(x *T) (bool, error),
+	importar func(ctx context.Context, items []T, dryRun bool) (map[int]error, error)) {
+	dryRun := false
+	if value := c.Query("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			web.Failure(c, 400, errors.New("dryRun inválido"))
+			return
+		}
+	}
+	mapping, err := parseMapping(c.Query("map"))
+	if err != nil {
+		web.Failure(c, 400, err)
+		return
+	}
+	body, err := csvBody(c)
+	if err != nil {
+		web.Failure(c, 400, err)
+		return
+	}
+	defer body.Close()
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	if delimiter := c.Query("delimiter"); delimiter != "" {
+		if len([]rune(delimiter)) != 1 {
+			web.Failure(c, 400, errors.New("delimiter debe ser un único carácter"))
+			return
+		}
+		reader.Comma = []rune(delimiter)[0]
+	}
+	rows, ignored, err := readCSV(reader, columns, mapping)
+	if err != nil {
+		web.Failure(c, 400, err)
+		return
+	}
+
+	report := csvReport{DryRun: dryRun, Filas: len(rows), ColumnasIgnoradas: ignored, Errores: []csvError{}}
+	var items []T
+	var lines []int
+	for _, row := range rows {
+		if row.err != nil {
+			report.Errores = append(report.Errores, csvError{Fila: row.line, Error: row.err.Error()})
+			continue
+		}
+		if _, err := validate(&row.item); err != nil {
+			report.Errores = append(report.Errores, csvError{Fila: row.line, Error: err.Error()})
+			continue
+		}
+		items = append(items, row.item)
+		lines = append(lines, row.line)
+	}
+	// Con errores de validación las filas válidas se prueban igual, para
+	// informar todos los problemas de una vez, pero sin guardarlas.
+	errores, err := importar(c.Request.Context(), items, dryRun || len(report.Errores) > 0)
+	if err != nil {
+		web.Failure(c, 500, err)
+		return
+	}
+	for i, err := range errores {
+		report.Errores = append(report.Errores, csvError{Fila: lines[i], Error: err.Error()})
+	}
+	sort.Slice(report.Errores, func(i, j int) bool { return report.Errores[i].Fila < report.Errores[j].Fila })
+
+	switch {
+	case len(report.Errores) > 0:
+		web.Success(c, 422, report, "El archivo tiene errores; no se importó ninguna fila")
+	case dryRun:
+		web.Success(c, 200, report, "El archivo es válido; no se guardó ninguna fila")
+	default:
+		report.Importadas = len(items)
+		web.Success(c, 201, report, fmt.Sprintf("Se importaron %d filas", len(items)))
+	}
+}
+
+// csvBody devuelve el archivo "file" de un formulario multipart o, si la
+// petición no es multipart, su cuerpo.
+func csvBody(c *gin.Context) (io.ReadCloser, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return c.Request.Body, nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("falta el archivo CSV en el campo file")
+	}
+	return header.Open()
+}
+
+// parseMapping lee pares "columna:campo" separados por comas.
+func parseMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, par := range strings.Split(value, ",") {
+		if par = strings.TrimSpace(par); par == "" {
+			continue
+		}
+		column, field, ok := strings.Cut(par, ":")
+		if !ok {
+			return nil, fmt.Errorf("map inválido: %q no tiene la forma columna:campo", par)
+		}
+		mapping[strings.ToLower(strings.TrimSpace(column))] = strings.TrimSpace(field)
+	}
+	return mapping, nil
+}
+
+// readCSV lee el encabezado y las filas de reader. Las columnas que no
+// corresponden a un campo importable se ignoran y se devuelven aparte.
+func readCSV[T any](reader *csv.Reader, columns []csvColumn[T], mapping map[string]string) ([]csvRow[T], []string, error) {
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("el CSV está vacío")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSV inválido: %w", err)
+	}
+	setters := make([]func(x *T, value string) error, len(header))
+	presentes := make(map[string]bool)
+	var ignored []string
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		field := name
+		if mapped, ok := mapping[strings.ToLower(name)]; ok {
+			field = mapped
+		}
+		for _, column := range columns {
+			if column.set != nil && strings.EqualFold(column.name, field) && !presentes[column.name] {
+				setters[i] = column.set
+				presentes[column.name] = true
+				break
+			}
+		}
+		if setters[i] == nil {
+			ignored = append(ignored, name)
+		}
+	}
+	var faltantes []string
+	for _, column := range columns {
+		if column.set != nil && !column.optional && !presentes[column.name] {
+			faltantes = append(faltantes, column.name)
+		}
+	}
+	if len(faltantes) > 0 {
+		return nil, nil, fmt.Errorf("faltan columnas en el CSV: %s", strings.Join(faltantes, ", "))
+	}
+
+	var rows []csvRow[T]
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, ignored, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("CSV inválido: %w", err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, nil, fmt.Errorf("el CSV supera las %d filas", maxImportRows)
+		}
+		row := csvRow[T]{}
+		row.line, _ = reader.FieldPos(0)
+		for i, value := range record {
+			if i < len(setters) && setters[i] != nil {
+				if err := setters[i](&row.item, csvValue(value)); err != nil && row.err == nil {
+					row.err = err
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// exportCSV escribe como CSV todos los registros que devuelve export, que
+// los lee de una misma vista del store para no saltear ni repetir registros
+// si cambian mientras se recorren las páginas. Acepta los mismos filtros y
+// orden que el listado; page y limit se ignoran. Los registros se leen
+// completos antes de escribir la respuesta, para no retener la vista
+// mientras el cliente descarga.
+func exportCSV[T any](c *gin.Context, nombre string, columns []csvColumn[T],
+	export func(ctx context.Context, opts domain.ListOptions) ([]T, error)) {
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		web.Failure(c, 400, errors.New("format debe ser csv"))
+		return
+	}
+	opts, err := parseListOptions(c)
+	if err != nil {
+		web.Failure(c, 400, err)
+		return
+	}
+	items, err := export(c.Request.Context(), opts)
+	if err != nil {
+		web.Failure(c, 500, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", nombre+".csv"))
+	c.Status(200)
+	w := csv.NewWriter(c.Writer)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.name
+	}
+	w.Write(record)
+	for i := range items {
+		for j, column := range columns {
+			record[j] = csvCell(column.get(&items[i]))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Error(err)
+	}
+}
+
+// csvFormula son los caracteres con los que una planilla de cálculo
+// interpreta una celda como fórmula.
+const csvFormula = "=+-@\t\r"
+
+// csvCell antepone un apóstrofo a las celdas que una planilla de cálculo
+// ejecutaría como fórmula. La importación lo quita con csvValue.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormula, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvValue limpia una celda importada y quita el apóstrofo que agrega
+// csvCell.
+func csvValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormula, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
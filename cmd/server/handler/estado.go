@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+// cancelacionRequest es el cuerpo de la cancelación de un turno.
+type cancelacionRequest struct {
+	Motivo string `json:"motivo"`
+}
+
+// bindCancelacion lee el cuerpo de una cancelación. Un cuerpo vacío equivale
+// a un motivo vacío, para que el servicio responda que falta el motivo.
+func bindCancelacion(c *gin.Context) (cancelacionRequest, error) {
+	var request cancelacionRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		return cancelacionRequest{}, bindError(err)
+	}
+	return request, nil
+}
+
+// POST
+// @Summary Confirmar un turno
+// @Description Pasa a confirmado un turno solicitado
+// @Tags Turnos
+// @Produce json
+// @Param idTurno path int true "ID del turno"
+// @Param If-Match header string false "ETag de la versión leída"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/confirmar [post]
+func (h *turnoHandler) ConfirmarTurno() gin.HandlerFunc {
+	return h.cambiarEstado(domain.EstadoConfirmado, "El turno ha sido confirmado")
+}
+
+// POST
+// @Summary Cancelar un turno
+// @Description Cancela un turno solicitado o confirmado. El turno se conserva con el motivo y libera su horario
+// @Tags Turnos
+// @Accept json
+// @Produce json
+// @Param idTurno path int true "ID del turno"
+// @Param If-Match header string false "ETag de la versión leída"
+// @Param body body cancelacionRequest true "Motivo de la cancelación"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/cancelar [post]
+func (h *turnoHandler) CancelarTurno() gin.HandlerFunc {
+	return h.cambiarEstado(domain.EstadoCancelado, "El turno ha sido cancelado")
+}
+
+// POST
+// @Summary Registrar la llegada del paciente
+// @Description Pasa el turno a en_sala cuando el paciente llega a la sala de espera
+// @Tags Turnos
+// @Produce json
+// @Param idTurno path int true "ID del turno"
+// @Param If-Match header string false "ETag de la versión leída"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/en-sala [post]
+func (h *turnoHandler) TurnoEnSala() gin.HandlerFunc {
+	return h.cambiarEstado(domain.EstadoEnSala, "El paciente está en la sala de espera")
+}
+
+// POST
+// @Summary Marcar un turno como atendido
+// @Description Pasa a atendido un turno cuyo paciente está en la sala de espera
+// @Tags Turnos
+// @Produce json
+// @Param idTurno path int true "ID del turno"
+// @Param If-Match header string false "ETag de la versión leída"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/atender [post]
+func (h *turnoHandler) AtenderTurno() gin.HandlerFunc {
+	return h.cambiarEstado(domain.EstadoAtendido, "El turno ha sido atendido")
+}
+
+// POST
+// @Summary Marcar un turno como ausente
+// @Description Registra que el paciente no se presentó a un turno solicitado o confirmado
+// @Tags Turnos
+// @Produce json
+// @Param idTurno path int true "ID del turno"
+// @Param If-Match header string false "ETag de la versión leída"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/ausente [post]
+func (h *turnoHandler) TurnoAusente() gin.HandlerFunc {
+	return h.cambiarEstado(domain.EstadoAusente, "El turno ha sido marcado como ausente")
+}
+
+// cambiarEstado arma el handler de una transición hacia estado. Sólo la
+// cancelación lee un cuerpo, con el motivo.
+func (h *turnoHandler) cambiarEstado(estado, mensaje string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idTurno"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		var request cancelacionRequest
+		if estado == domain.EstadoCancelado {
+			if request, err = bindCancelacion(c); err != nil {
+				web.Failure(c, 400, err)
+				return
+			}
+		}
+		t, err := h.s.CambiarEstado(c.Request.Context(), id, estado, request.Motivo, version)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		setETag(c, t.Version)
+		web.Success(c, 200, t, mensaje)
+	}
+}
+
+// GET
+// @Summary Historial de estados de un turno
+// @Description Lista los estados por los que pasó el turno, con quién y cuándo hizo cada cambio
+// @Tags Turnos
+// @Produce json
+// @Param idTurno path int true "ID del turno"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/historial [get]
+func (h *turnoHandler) GetHistorialTurno() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idTurno"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		historial, err := h.s.Historial(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, historial, "Historial de estados del turno")
+	}
+}
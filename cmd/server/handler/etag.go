@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag publica la versión del registro en el encabezado ETag.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// ifMatch devuelve la versión del encabezado If-Match, o 0 si no se envió o
+// vale "*". Acepta el formato de ETag, con o sin el prefijo W/. Un encabezado
+// mal formado es un error del pedido y no una versión desactualizada.
+func ifMatch(c *gin.Context) (int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(header, "W/"))
+	if err != nil {
+		return 0, fmt.Errorf("If-Match inválido: %s", header)
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("If-Match inválido: %s", header)
+	}
+	return version, nil
+}
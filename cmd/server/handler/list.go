@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// parseListOptions lee los parámetros page, limit, sort, order y deleted
+// comunes a todos los listados.
+func parseListOptions(c *gin.Context) (domain.ListOptions, error) {
+	var opts domain.ListOptions
+	var err error
+	if page := c.Query("page"); page != "" {
+		if opts.Page, err = strconv.Atoi(page); err != nil {
+			return opts, errors.New("page inválido")
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil {
+			return opts, errors.New("limit inválido")
+		}
+	}
+	opts.Sort = c.Query("sort")
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, errors.New("order debe ser asc o desc")
+	}
+	if deleted := c.Query("deleted"); deleted != "" {
+		if opts.Deleted, err = strconv.ParseBool(deleted); err != nil {
+			return opts, errors.New("deleted inválido")
+		}
+	}
+	opts.Normalize()
+	return opts, nil
+}
+
+// fechaQuery lee el parámetro name como domain.Fecha; si no está devuelve la
+// fecha cero. Con finDelDia, una fecha sin hora se toma hasta el último
+// instante de ese día para que los rangos la incluyan.
+func fechaQuery(c *gin.Context, name string, finDelDia bool) (domain.Fecha, error) {
+	value := c.Query(name)
+	if value == "" {
+		return domain.Fecha{}, nil
+	}
+	f, err := domain.ParseFecha(value)
+	if err != nil {
+		return domain.Fecha{}, domain.NewError(domain.ErrValidation, "%s: %s", name, err)
+	}
+	if finDelDia && domain.SoloDia(value) {
+		f = domain.Fecha(f.Time().Add(24*time.Hour - time.Nanosecond))
+	}
+	return f, nil
+}
+
+// bindError traduce un error de ShouldBindJSON. Los errores de domain, como
+// una fecha mal escrita, se informan tal cual; el resto es JSON inválido.
+func bindError(err error) error {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+	return errors.New("Invalid Json")
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"strconv"
 
@@ -22,6 +23,7 @@ type Request struct {
 	ApellidoOdontologo  string `json:"apellidoOdontologo,omitempty"`
 	MatriculaOdontologo string `json:"matriculaOdontologo,omitempty"`
 }
+
 func NewOdontologoHandler(s odontologo.Service) *odontologoHandler {
 	return &odontologoHandler{
 		s: s,
@@ -34,36 +36,69 @@ func NewOdontologoHandler(s odontologo.Service) *odontologoHandler {
 // @Tags Odontologos
 // @Produce json
 // @Param body body domain.Odontologo true "Datos del odontólogo a crear"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
 // @Router /api/v1/odontologos  [post]
 func (h *odontologoHandler) CreateOdontologo() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var odontologo domain.Odontologo
 
 		err := c.ShouldBindJSON(&odontologo)
-	
+
 		if err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
-		p, err := h.s.Create(odontologo)
+		p, err := h.s.Create(c.Request.Context(), odontologo)
 		if err != nil {
-			web.Failure(c, 400, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 201, p, "El odontólogo ha sido creado correctamente")
 	}
 }
 
+// GET
+// @Summary Listar odontólogos
+// @Description Lista odontólogos con filtros, orden y paginación
+// @Tags Odontologos
+// @Produce json
+// @Param apellido query string false "Apellido (coincidencia parcial)"
+// @Param matricula query string false "Matrícula exacta"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param page query int false "Página"
+// @Param limit query int false "Registros por página"
+// @Param deleted query bool false "Listar sólo los registros dados de baja"
+// @Success 200 {object} web.pageResponse
+// @Failure 400 {object} web.errorResponse
+// @Router /api/v1/odontologos [get]
+func (h *odontologoHandler) GetOdontologos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, err := parseListOptions(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		odontologos, total, err := h.s.List(c.Request.Context(), odontologoFilter(c, opts))
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.SuccessPage(c, 200, odontologos, opts.Page, opts.Limit, total, "Listado de odontólogos")
+	}
+}
+
 // GET
 // @Summary Obtener un odontologo por ID
 // @Description Obtiene un odontologo por su ID
 // @Tags Odontologos
 // @Produce json
 // @Param idOdontologo path int true "ID del odontólogo a obtener"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Header 200 {string} ETag "Versión del registro"
+// @Failure 400 {object} web.errorResponse
 // @Router /api/v1/odontologos/{idOdontologo} [get]
 func (h *odontologoHandler) GetOdontologoByID() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -74,11 +109,12 @@ func (h *odontologoHandler) GetOdontologoByID() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		odontologo, err := h.s.GetByID(id)
+		odontologo, err := h.s.GetByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el odontólogo"))
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, odontologo.Version)
 		web.Success(c, 200, odontologo, "El odontólogo se ha encontrado por su ID")
 	}
 }
@@ -86,7 +122,7 @@ func (h *odontologoHandler) GetOdontologoByID() gin.HandlerFunc {
 func validateEmptys(odontologo *domain.Odontologo) (bool, error) {
 	switch {
 	case odontologo.NombreOdontologo == "" || odontologo.ApellidoOdontologo == "" || odontologo.MatriculaOdontologo == "":
-		return false, errors.New("No puede haber campos vacíos")
+		return false, domain.NewError(domain.ErrValidation, "No puede haber campos vacíos")
 	}
 	return true, nil
 }
@@ -97,35 +133,44 @@ func validateEmptys(odontologo *domain.Odontologo) (bool, error) {
 // @Tags Odontologos
 // @Produce json
 // @Param idOdontologo path int true "ID del odontólogo a actualizar"
+// @Param If-Match header string false "ETag de la versión leída"
 // @Param body body domain.Odontologo true "Datos del odontólogo a actualizar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/odontologos/{idOdontologo} [put]
 func (h *odontologoHandler) UpdateOdontologo() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idParam := c.Param("idOdontologo")
 		id, err := strconv.Atoi(idParam)
-	
+
 		if err != nil {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetByID(id)
+		version, err := ifMatch(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 500, err)
 			return
 		}
 		var odontologo domain.Odontologo
 		err = c.ShouldBindJSON(&odontologo)
 		if err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
-		p, err := h.s.Update(id, odontologo)
+		odontologo.Version = version
+		p, err := h.s.Update(c.Request.Context(), id, odontologo)
 		if err != nil {
-			web.Failure(c, 409, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 200, p, "El odontólogo ha sido correctamente actualizado")
 	}
 }
@@ -136,9 +181,11 @@ func (h *odontologoHandler) UpdateOdontologo() gin.HandlerFunc {
 // @Tags Odontologos
 // @Produce json
 // @Param idOdontologo path int true "ID del odontólogo a actualizar"
+// @Param If-Match header string false "ETag de la versión leída"
 // @Param body body Request true "Campos a actualizar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/odontologos/{idOdontologo} [patch]
 func (h *odontologoHandler) UpdateOdontologoForField() gin.HandlerFunc {
 	type Request struct {
@@ -150,30 +197,37 @@ func (h *odontologoHandler) UpdateOdontologoForField() gin.HandlerFunc {
 		var r Request
 		idParam := c.Param("idOdontologo")
 		id, err := strconv.Atoi(idParam)
-	
+
 		if err != nil {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetByID(id)
+		version, err := ifMatch(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado ese ID"))
+			web.Failure(c, 500, err)
 			return
 		}
 		if err := c.ShouldBindJSON(&r); err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
 		update := domain.Odontologo{
 			NombreOdontologo:    r.NombreOdontologo,
 			ApellidoOdontologo:  r.ApellidoOdontologo,
 			MatriculaOdontologo: r.MatriculaOdontologo,
+			Version:             version,
 		}
-		p, err := h.s.Update(id, update)
+		p, err := h.s.Update(c.Request.Context(), id, update)
 		if err != nil {
-			web.Failure(c, 409, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 200, p, "El odontólogo ha sido correctamente actualizado")
 	}
 }
@@ -184,8 +238,11 @@ func (h *odontologoHandler) UpdateOdontologoForField() gin.HandlerFunc {
 // @Tags Odontologos
 // @Produce json
 // @Param idOdontologo path int true "ID del odontólogo a eliminar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Param If-Match header string false "ETag de la versión leída"
+// @Param reasignarA query int false "ID del odontólogo que recibe sus turnos no finalizados"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/odontologos/{idOdontologo} [delete]
 func (h *odontologoHandler) DeleteOdontologo() gin.HandlerFunc {
 
@@ -198,16 +255,120 @@ func (h *odontologoHandler) DeleteOdontologo() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetByID(id)
+		version, err := ifMatch(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado ese ID"))
+			web.Failure(c, 500, err)
+			return
+		}
+		if destino := c.Query("reasignarA"); destino != "" {
+			idDestino, err := strconv.Atoi(destino)
+			if err != nil {
+				web.Failure(c, 400, errors.New("reasignarA inválido"))
+				return
+			}
+			err = h.s.DeleteReasignando(c.Request.Context(), id, idDestino, version)
+			if err != nil {
+				web.Failure(c, 500, err)
+				return
+			}
+			web.Success(c, 200, nil, "Los turnos fueron reasignados y el odontólogo eliminado")
 			return
 		}
-		err = h.s.Delete(id)
+		err = h.s.Delete(c.Request.Context(), id, version)
 		if err != nil {
-			web.Failure(c, 404, err)
+			web.Failure(c, 500, err)
 			return
 		}
 		web.Success(c, 200, nil, "El odontólogo ha sido correctamente eliminado")
 	}
 }
+
+// POST
+// @Summary Restaurar un odontólogo dado de baja
+// @Description Deshace la baja del odontólogo y los turnos que se dieron de baja con él
+// @Tags Odontologos
+// @Produce json
+// @Param idOdontologo path int true "ID del odontólogo a restaurar"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Router /api/v1/odontologos/{idOdontologo}/restore [post]
+func (h *odontologoHandler) RestoreOdontologo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idOdontologo"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		p, err := h.s.Restore(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		setETag(c, p.Version)
+		web.Success(c, 200, p, "El odontólogo ha sido restaurado")
+	}
+}
+
+func odontologoFilter(c *gin.Context, opts domain.ListOptions) domain.OdontologoFilter {
+	return domain.OdontologoFilter{
+		ListOptions: opts,
+		Apellido:    c.Query("apellido"),
+		Matricula:   c.Query("matricula"),
+	}
+}
+
+var odontologoColumns = []csvColumn[domain.Odontologo]{
+	{name: "idOdontologo", get: func(o *domain.Odontologo) string { return strconv.Itoa(o.IdOdontologo) }},
+	{name: "nombreOdontologo", get: func(o *domain.Odontologo) string { return o.NombreOdontologo }, set: csvText(func(o *domain.Odontologo) *string { return &o.NombreOdontologo })},
+	{name: "apellidoOdontologo", get: func(o *domain.Odontologo) string { return o.ApellidoOdontologo }, set: csvText(func(o *domain.Odontologo) *string { return &o.ApellidoOdontologo })},
+	{name: "matriculaOdontologo", get: func(o *domain.Odontologo) string { return o.MatriculaOdontologo }, set: csvText(func(o *domain.Odontologo) *string { return &o.MatriculaOdontologo })},
+	{name: "version", get: func(o *domain.Odontologo) string { return strconv.Itoa(o.Version) }},
+	{name: "deletedAt", get: func(o *domain.Odontologo) string { return o.DeletedAt }},
+}
+
+// POST
+// @Summary Importar odontólogos desde CSV
+// @Description Da de alta los odontólogos de un CSV cuyo encabezado nombra los campos. Si alguna fila falla no se importa ninguna
+// @Tags Odontologos
+// @Accept text/csv
+// @Produce json
+// @Param dryRun query bool false "Validar sin guardar"
+// @Param map query string false "Renombre de columnas, por ejemplo Nombre:nombreOdontologo"
+// @Param delimiter query string false "Separador de columnas (por defecto ,)"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 422 {object} web.response
+// @Router /api/v1/odontologos/import [post]
+func (h *odontologoHandler) ImportOdontologos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		importCSV(c, odontologoColumns, validateEmptys, h.s.Import)
+	}
+}
+
+// GET
+// @Summary Exportar odontólogos a CSV
+// @Description Descarga los odontólogos que cumplen los filtros del listado
+// @Tags Odontologos
+// @Produce text/csv
+// @Param format query string false "csv"
+// @Param apellido query string false "Apellido (coincidencia parcial)"
+// @Param matricula query string false "Matrícula exacta"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param deleted query bool false "Exportar sólo los registros dados de baja"
+// @Success 200 {file} file
+// @Failure 400 {object} web.errorResponse
+// @Router /api/v1/odontologos/export [get]
+func (h *odontologoHandler) ExportOdontologos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exportCSV(c, "odontologos", odontologoColumns, func(ctx context.Context, opts domain.ListOptions) ([]domain.Odontologo, error) {
+			return h.s.Export(ctx, odontologoFilter(c, opts))
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"strconv"
+	"time"
 
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/MechiBakker/BE3-FINAL/internal/paciente"
@@ -27,8 +29,8 @@ func NewPacienteHandler(s paciente.Service) *pacienteHandler {
 // @Accept json
 // @Produce json
 // @Param paciente body domain.Paciente true "Datos del paciente a crear"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
 // @Router /api/v1/pacientes [post]
 func (h *pacienteHandler) CreatePaciente() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -36,18 +38,51 @@ func (h *pacienteHandler) CreatePaciente() gin.HandlerFunc {
 
 		err := c.ShouldBindJSON(&paciente)
 		if err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
-		p, err := h.s.CreatePaciente(paciente)
+		p, err := h.s.CreatePaciente(c.Request.Context(), paciente)
 		if err != nil {
-			web.Failure(c, 400, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 201, p, "El paciente ha sido creado correctamente")
 	}
 }
 
+// GET
+// @Summary Listar pacientes
+// @Description Lista pacientes con filtros, orden y paginación
+// @Tags Pacientes
+// @Produce json
+// @Param apellido query string false "Apellido (coincidencia parcial)"
+// @Param dni query string false "DNI exacto"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param page query int false "Página"
+// @Param limit query int false "Registros por página"
+// @Param deleted query bool false "Listar sólo los registros dados de baja"
+// @Success 200 {object} web.pageResponse
+// @Failure 400 {object} web.errorResponse
+// @Failure 401 {object} web.errorResponse
+// @Router /api/v1/pacientes [get]
+func (h *pacienteHandler) GetPacientes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, err := parseListOptions(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		pacientes, total, err := h.s.ListPacientes(c.Request.Context(), pacienteFilter(c, opts))
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.SuccessPage(c, 200, pacientes, opts.Page, opts.Limit, total, "Listado de pacientes")
+	}
+}
+
 // GET
 // @Summary Obtener un paciente por ID
 // @Description Obtiene un paciente por su ID
@@ -55,8 +90,9 @@ func (h *pacienteHandler) CreatePaciente() gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param idPaciente path int true "ID del paciente a obtener"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Header 200 {string} ETag "Versión del registro"
+// @Failure 400 {object} web.errorResponse
 // @Router /api/v1/pacientes/{idPaciente} [get]
 func (h *pacienteHandler) GetPacienteByID() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -66,19 +102,22 @@ func (h *pacienteHandler) GetPacienteByID() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		paciente, err := h.s.GetPacienteByID(id)
+		paciente, err := h.s.GetPacienteByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el paciente"))
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, paciente.Version)
 		web.Success(c, 200, paciente, "El paciente se ha encontrado por su ID")
 	}
 }
 
 func validateFieldsPaciente(paciente *domain.Paciente) (bool, error) {
 	switch {
-	case paciente.NombrePaciente == "" || paciente.ApellidoPaciente == "" || paciente.DomicilioPaciente == "" || paciente.DniPaciente == "" || paciente.FechaDeAltaPaciente == "" /* || paciente.FechaDeAltaPaciente > time.Now() */ :
-		return false, errors.New("No puede haber campos vacíos ni la fecha puede ser posterior a la actual")
+	case paciente.NombrePaciente == "" || paciente.ApellidoPaciente == "" || paciente.DomicilioPaciente == "" || paciente.DniPaciente == "" || paciente.FechaDeAltaPaciente.IsZero():
+		return false, domain.NewError(domain.ErrValidation, "No puede haber campos vacíos")
+	case paciente.FechaDeAltaPaciente.Time().After(time.Now()):
+		return false, domain.NewError(domain.ErrValidation, "La fecha de alta no puede ser posterior a la actual")
 	}
 	return true, nil
 }
@@ -90,9 +129,11 @@ func validateFieldsPaciente(paciente *domain.Paciente) (bool, error) {
 // @Accept json
 // @Produce json
 // @Param idPaciente path int true "ID del paciente a actualizar"
+// @Param If-Match header string false "ETag de la versión leída"
 // @Param paciente body domain.Paciente true "Datos del paciente a actualizar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/pacientes/{idPaciente} [put]
 func (h *pacienteHandler) UpdatePaciente() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -103,27 +144,34 @@ func (h *pacienteHandler) UpdatePaciente() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetPacienteByID(id)
+		version, err := ifMatch(c)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetPacienteByID(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
 			return
 		}
 		var paciente domain.Paciente
 		err = c.ShouldBindJSON(&paciente)
 		if err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
 		valid, err := validateFieldsPaciente(&paciente)
 		if !valid {
-			web.Failure(c, 400, err)
+			web.Failure(c, 422, err)
 			return
 		}
-		p, err := h.s.UpdatePaciente(id, paciente)
+		paciente.Version = version
+		p, err := h.s.UpdatePaciente(c.Request.Context(), id, paciente)
 		if err != nil {
-			web.Failure(c, 409, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 200, p, "El paciente ha sido correctamente actualizado")
 	}
 }
@@ -135,17 +183,19 @@ func (h *pacienteHandler) UpdatePaciente() gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param idPaciente path int true "ID del paciente a actualizar"
+// @Param If-Match header string false "ETag de la versión leída"
 // @Param paciente body Request true "Campos a actualizar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/pacientes/{idPaciente} [patch]
 func (h *pacienteHandler) UpdatePacienteForField() gin.HandlerFunc {
 	type Request struct {
-		NombrePaciente      string `json:"nombrePaciente,omitempty"`
-		ApellidoPaciente    string `json:"apellidoPaciente,omitempty"`
-		DomicilioPaciente   string `json:"domicilioPaciente,omitempty"`
-		DniPaciente         string `json:"dniPaciente,omitempty"`
-		FechaDeAltaPaciente string `json:"fechaDeAltaPacientePaciente,omitempty"`
+		NombrePaciente      string       `json:"nombrePaciente,omitempty"`
+		ApellidoPaciente    string       `json:"apellidoPaciente,omitempty"`
+		DomicilioPaciente   string       `json:"domicilioPaciente,omitempty"`
+		DniPaciente         string       `json:"dniPaciente,omitempty"`
+		FechaDeAltaPaciente domain.Fecha `json:"fechaDeAltaPaciente,omitempty" swaggertype:"string" format:"date-time"`
 	}
 	return func(c *gin.Context) {
 
@@ -156,13 +206,18 @@ func (h *pacienteHandler) UpdatePacienteForField() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetPacienteByID(id)
+		version, err := ifMatch(c)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetPacienteByID(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
 			return
 		}
 		if err := c.ShouldBindJSON(&r); err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
 		update := domain.Paciente{
@@ -171,12 +226,14 @@ func (h *pacienteHandler) UpdatePacienteForField() gin.HandlerFunc {
 			DomicilioPaciente:   r.DomicilioPaciente,
 			DniPaciente:         r.DniPaciente,
 			FechaDeAltaPaciente: r.FechaDeAltaPaciente,
+			Version:             version,
 		}
-		p, err := h.s.UpdatePaciente(id, update)
+		p, err := h.s.UpdatePaciente(c.Request.Context(), id, update)
 		if err != nil {
-			web.Failure(c, 409, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 200, p, "El paciente ha sido correctamente actualizado")
 	}
 }
@@ -188,8 +245,10 @@ func (h *pacienteHandler) UpdatePacienteForField() gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param idPaciente path int true "ID del paciente a eliminar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Param If-Match header string false "ETag de la versión leída"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/pacientes/{idPaciente} [delete]
 func (h *pacienteHandler) DeletePaciente() gin.HandlerFunc {
 
@@ -201,16 +260,109 @@ func (h *pacienteHandler) DeletePaciente() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetPacienteByID(id)
+		version, err := ifMatch(c)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 400, err)
 			return
 		}
-		err = h.s.DeletePaciente(id)
+		_, err = h.s.GetPacienteByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, err)
+			web.Failure(c, 500, err)
+			return
+		}
+		err = h.s.DeletePaciente(c.Request.Context(), id, version)
+		if err != nil {
+			web.Failure(c, 500, err)
 			return
 		}
 		web.Success(c, 200, nil, "El paciente ha sido correctamente eliminado")
 	}
 }
+
+// POST
+// @Summary Restaurar un paciente dado de baja
+// @Description Deshace la baja del paciente y los turnos que se dieron de baja con él
+// @Tags Pacientes
+// @Produce json
+// @Param idPaciente path int true "ID del paciente a restaurar"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Router /api/v1/pacientes/{idPaciente}/restore [post]
+func (h *pacienteHandler) RestorePaciente() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idPaciente"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		p, err := h.s.RestorePaciente(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		setETag(c, p.Version)
+		web.Success(c, 200, p, "El paciente ha sido restaurado")
+	}
+}
+
+func pacienteFilter(c *gin.Context, opts domain.ListOptions) domain.PacienteFilter {
+	return domain.PacienteFilter{
+		ListOptions: opts,
+		Apellido:    c.Query("apellido"),
+		Dni:         c.Query("dni"),
+	}
+}
+
+var pacienteColumns = []csvColumn[domain.Paciente]{
+	{name: "idPaciente", get: func(p *domain.Paciente) string { return strconv.Itoa(p.IdPaciente) }},
+	{name: "nombrePaciente", get: func(p *domain.Paciente) string { return p.NombrePaciente }, set: csvText(func(p *domain.Paciente) *string { return &p.NombrePaciente })},
+	{name: "apellidoPaciente", get: func(p *domain.Paciente) string { return p.ApellidoPaciente }, set: csvText(func(p *domain.Paciente) *string { return &p.ApellidoPaciente })},
+	{name: "domicilioPaciente", get: func(p *domain.Paciente) string { return p.DomicilioPaciente }, set: csvText(func(p *domain.Paciente) *string { return &p.DomicilioPaciente })},
+	{name: "dniPaciente", get: func(p *domain.Paciente) string { return p.DniPaciente }, set: csvText(func(p *domain.Paciente) *string { return &p.DniPaciente })},
+	{name: "fechaDeAltaPaciente", get: func(p *domain.Paciente) string { return p.FechaDeAltaPaciente.String() }, set: csvFecha(func(p *domain.Paciente) *domain.Fecha { return &p.FechaDeAltaPaciente })},
+	{name: "version", get: func(p *domain.Paciente) string { return strconv.Itoa(p.Version) }},
+	{name: "deletedAt", get: func(p *domain.Paciente) string { return p.DeletedAt }},
+}
+
+// POST
+// @Summary Importar pacientes desde CSV
+// @Description Da de alta los pacientes de un CSV cuyo encabezado nombra los campos. Si alguna fila falla no se importa ninguna
+// @Tags Pacientes
+// @Accept text/csv
+// @Produce json
+// @Param dryRun query bool false "Validar sin guardar"
+// @Param map query string false "Renombre de columnas, por ejemplo DNI:dniPaciente"
+// @Param delimiter query string false "Separador de columnas (por defecto ,)"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 422 {object} web.response
+// @Router /api/v1/pacientes/import [post]
+func (h *pacienteHandler) ImportPacientes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		importCSV(c, pacienteColumns, validateFieldsPaciente, h.s.ImportPacientes)
+	}
+}
+
+// GET
+// @Summary Exportar pacientes a CSV
+// @Description Descarga los pacientes que cumplen los filtros del listado
+// @Tags Pacientes
+// @Produce text/csv
+// @Param format query string false "csv"
+// @Param apellido query string false "Apellido (coincidencia parcial)"
+// @Param dni query string false "DNI exacto"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param deleted query bool false "Exportar sólo los registros dados de baja"
+// @Success 200 {file} file
+// @Failure 400 {object} web.errorResponse
+// @Failure 401 {object} web.errorResponse
+// @Router /api/v1/pacientes/export [get]
+func (h *pacienteHandler) ExportPacientes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exportCSV(c, "pacientes", pacienteColumns, func(ctx context.Context, opts domain.ListOptions) ([]domain.Paciente, error) {
+			return h.s.ExportPacientes(ctx, pacienteFilter(c, opts))
+		})
+	}
+}
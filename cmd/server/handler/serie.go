@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/internal/serie"
+	"github.com/MechiBakker/BE3-FINAL/pkg/web"
+	"github.com/gin-gonic/gin"
+)
+
+type serieHandler struct {
+	s serie.Service
+}
+
+func NewSerieHandler(s serie.Service) *serieHandler {
+	return &serieHandler{
+		s: s,
+	}
+}
+
+// serieRequest es el cuerpo del alta de una serie. Hay que indicar cantidad
+// o hasta, pero no ambos.
+type serieRequest struct {
+	DescripcionTurno string       `json:"descripcionTurno" binding:"required"`
+	FechaInicio      domain.Fecha `json:"fechaInicio" binding:"required" swaggertype:"string" format:"date-time"`
+	DuracionMinutos  int          `json:"duracionMinutos"`
+	IdOdontologo     int          `json:"idOdontologo" binding:"required"`
+	IdPaciente       int          `json:"idPaciente" binding:"required"`
+	Frecuencia       string       `json:"frecuencia" binding:"required"`
+	Intervalo        int          `json:"intervalo"`
+	Cantidad         int          `json:"cantidad"`
+	Hasta            string       `json:"hasta"`
+}
+
+// turnosSerieRequest es el cuerpo del cambio de los turnos de una serie.
+type turnosSerieRequest struct {
+	DescripcionTurno string       `json:"descripcionTurno,omitempty"`
+	FechaTurno       domain.Fecha `json:"fechaTurno,omitempty" swaggertype:"string" format:"date-time"`
+	DuracionMinutos  int          `json:"duracionMinutos,omitempty"`
+	IdOdontologo     int          `json:"idOdontologo,omitempty"`
+}
+
+// POST
+// @Summary Crear una serie de turnos
+// @Description Reserva los turnos de un paciente que se repiten cada intervalo días, semanas o meses según frecuencia (diaria, semanal o mensual), cantidad veces o hasta el día hasta inclusive. Cada turno se verifica como un alta común; si alguno no se puede reservar no se guarda ninguno y data lista los rechazados
+// @Tags Series
+// @Accept json
+// @Produce json
+// @Param body body serieRequest true "Primer turno y regla de repetición"
+// @Param dryRun query bool false "Verificar sin guardar"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/series [post]
+func (h *serieHandler) CreateSerie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r serieRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, bindError(err))
+			return
+		}
+		dryRun := false
+		if value := c.Query("dryRun"); value != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				web.Failure(c, 400, errors.New("dryRun inválido"))
+				return
+			}
+		}
+		s, turnos, err := h.s.CreateSerie(c.Request.Context(), domain.Serie{
+			DescripcionTurno: r.DescripcionTurno,
+			FechaInicio:      r.FechaInicio,
+			DuracionMinutos:  r.DuracionMinutos,
+			IdOdontologo:     r.IdOdontologo,
+			IdPaciente:       r.IdPaciente,
+			Frecuencia:       r.Frecuencia,
+			Intervalo:        r.Intervalo,
+			Cantidad:         r.Cantidad,
+			Hasta:            r.Hasta,
+		}, dryRun)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		if dryRun {
+			web.Success(c, 200, gin.H{"serie": s, "turnos": turnos}, fmt.Sprintf("Se pueden reservar los %d turnos de la serie", len(turnos)))
+			return
+		}
+		web.Success(c, 201, gin.H{"serie": s, "turnos": turnos}, fmt.Sprintf("La serie ha sido creada con %d turnos", len(turnos)))
+	}
+}
+
+// GET
+// @Summary Obtener una serie de turnos
+// @Description Devuelve la regla de la serie y sus turnos activos ordenados por fecha
+// @Tags Series
+// @Produce json
+// @Param idSerie path int true "ID de la serie"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Router /api/v1/series/{idSerie} [get]
+func (h *serieHandler) GetSerie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idSerie"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		s, turnos, err := h.s.GetSerie(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, gin.H{"serie": s, "turnos": turnos}, "Serie de turnos")
+	}
+}
+
+// PATCH
+// @Summary Modificar turnos de una serie
+// @Description Aplica los campos indicados al turno, a ése y los siguientes o a toda la serie según alcance. Un cambio de fecha corre a todos esos turnos lo mismo que al turno indicado; los turnos que ya no pueden modificarse se saltean. If-Match se controla sólo en el turno indicado
+// @Tags Series
+// @Accept json
+// @Produce json
+// @Param idSerie path int true "ID de la serie"
+// @Param idTurno path int true "ID del turno de la serie"
+// @Param alcance query string false "ocurrencia (por defecto), siguientes o serie"
+// @Param If-Match header string false "ETag de la versión leída del turno"
+// @Param body body turnosSerieRequest true "Campos a modificar"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/series/{idSerie}/turnos/{idTurno} [patch]
+func (h *serieHandler) UpdateTurnosSerie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idSerie, idTurno, err := idsSerie(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		var r turnosSerieRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, bindError(err))
+			return
+		}
+		turnos, err := h.s.UpdateTurnos(c.Request.Context(), idSerie, idTurno, c.DefaultQuery("alcance", domain.AlcanceOcurrencia), domain.Turno{
+			DescripcionTurno: r.DescripcionTurno,
+			FechaTurno:       r.FechaTurno,
+			DuracionMinutos:  r.DuracionMinutos,
+			IdOdontologo:     r.IdOdontologo,
+			Version:          version,
+		})
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, turnos, fmt.Sprintf("Se modificaron %d turnos de la serie", len(turnos)))
+	}
+}
+
+// POST
+// @Summary Cancelar turnos de una serie
+// @Description Cancela con el motivo indicado el turno, ése y los siguientes o toda la serie según alcance. Los turnos que ya no pueden cancelarse se saltean. If-Match se controla sólo en el turno indicado
+// @Tags Series
+// @Accept json
+// @Produce json
+// @Param idSerie path int true "ID de la serie"
+// @Param idTurno path int true "ID del turno de la serie"
+// @Param alcance query string false "ocurrencia (por defecto), siguientes o serie"
+// @Param If-Match header string false "ETag de la versión leída del turno"
+// @Param body body cancelacionRequest true "Motivo de la cancelación"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
+// @Failure 422 {object} web.errorResponse
+// @Router /api/v1/series/{idSerie}/turnos/{idTurno}/cancelar [post]
+func (h *serieHandler) CancelarTurnosSerie() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idSerie, idTurno, err := idsSerie(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		r, err := bindCancelacion(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		turnos, err := h.s.CancelarTurnos(c.Request.Context(), idSerie, idTurno, c.DefaultQuery("alcance", domain.AlcanceOcurrencia), r.Motivo, version)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 200, turnos, fmt.Sprintf("Se cancelaron %d turnos de la serie", len(turnos)))
+	}
+}
+
+// idsSerie lee los IDs de la serie y del turno de la ruta.
+func idsSerie(c *gin.Context) (int, int, error) {
+	idSerie, err := strconv.Atoi(c.Param("idSerie"))
+	if err != nil {
+		return 0, 0, errors.New("ID de serie inválido")
+	}
+	idTurno, err := strconv.Atoi(c.Param("idTurno"))
+	if err != nil {
+		return 0, 0, errors.New("ID de turno inválido")
+	}
+	return idSerie, idTurno, nil
+}
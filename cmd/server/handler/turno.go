@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"strconv"
 
@@ -27,8 +28,9 @@ func NewTurnoHandler(s turno.Service) *turnoHandler {
 // @Accept json
 // @Produce json
 // @Param idTurno path int true "ID del turno a obtener"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
 // @Router /api/v1/turnos/{idTurno} [post]
 func (h *turnoHandler) CreateTurno() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -36,18 +38,142 @@ func (h *turnoHandler) CreateTurno() gin.HandlerFunc {
 
 		err := c.ShouldBindJSON(&turno)
 		if err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
-		p, err := h.s.CreateTurno(turno)
+		p, err := h.s.CreateTurno(c.Request.Context(), turno)
 		if err != nil {
-			web.Failure(c, 400, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 201, p, "El turno ha sido creado correctamente")
 	}
 }
 
+// POST
+// @Summary Crear paciente con su primer turno
+// @Description Da de alta un paciente y su primer turno en una única operación
+// @Tags Turnos
+// @Accept json
+// @Produce json
+// @Param body body turnoConPacienteRequest true "Paciente y turno a crear"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Router /api/v1/turnos/con-paciente [post]
+func (h *turnoHandler) CreateTurnoConPaciente() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r turnoConPacienteRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, bindError(err))
+			return
+		}
+		turno := domain.Turno{
+			DescripcionTurno: r.Turno.DescripcionTurno,
+			FechaTurno:       r.Turno.FechaTurno,
+			DuracionMinutos:  r.Turno.DuracionMinutos,
+			IdOdontologo:     r.Turno.IdOdontologo,
+		}
+		p, t, err := h.s.CreateTurnoConPaciente(c.Request.Context(), r.Paciente, turno)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.Success(c, 201, gin.H{"paciente": p, "turno": t}, "El paciente y su turno han sido creados correctamente")
+	}
+}
+
+type turnoConPacienteRequest struct {
+	Paciente domain.Paciente `json:"paciente" binding:"required"`
+	Turno    struct {
+		DescripcionTurno string       `json:"descripcionTurno" binding:"required"`
+		FechaTurno       domain.Fecha `json:"fechaTurno" binding:"required" swaggertype:"string" format:"date-time"`
+		DuracionMinutos  int          `json:"duracionMinutos"`
+		IdOdontologo     int          `json:"idOdontologo" binding:"required"`
+	} `json:"turno" binding:"required"`
+}
+
+// POST
+// @Summary Crear turno por DNI y matrícula
+// @Description Da de alta un turno identificando al paciente por su DNI y al odontólogo por su matrícula
+// @Tags Turnos
+// @Accept json
+// @Produce json
+// @Param body body turnoPorDniMatriculaRequest true "Turno a crear"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Router /api/v1/turnos/by-dni-matricula [post]
+func (h *turnoHandler) CreateTurnoPorDniMatricula() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r turnoPorDniMatriculaRequest
+		if err := c.ShouldBindJSON(&r); err != nil {
+			web.Failure(c, 400, bindError(err))
+			return
+		}
+		turno := domain.Turno{
+			DescripcionTurno: r.DescripcionTurno,
+			FechaTurno:       r.FechaTurno,
+			DuracionMinutos:  r.DuracionMinutos,
+		}
+		t, err := h.s.CreateTurnoPorDniMatricula(c.Request.Context(), r.DniPaciente, r.MatriculaOdontologo, turno)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		setETag(c, t.Version)
+		web.Success(c, 201, t, "El turno ha sido creado correctamente")
+	}
+}
+
+type turnoPorDniMatriculaRequest struct {
+	DniPaciente         string       `json:"dniPaciente" binding:"required"`
+	MatriculaOdontologo string       `json:"matriculaOdontologo" binding:"required"`
+	FechaTurno          domain.Fecha `json:"fechaTurno" binding:"required" swaggertype:"string" format:"date-time"`
+	DuracionMinutos     int          `json:"duracionMinutos"`
+	DescripcionTurno    string       `json:"descripcionTurno" binding:"required"`
+}
+
+// GET
+// @Summary Listar turnos
+// @Description Lista turnos filtrando por odontólogo, paciente y rango de fechas
+// @Tags Turnos
+// @Produce json
+// @Param idOdontologo query int false "ID del odontólogo"
+// @Param idPaciente query int false "ID del paciente"
+// @Param desde query string false "Fecha mínima (inclusive), en RFC 3339, 2006-01-02 15:04 o 2006-01-02"
+// @Param hasta query string false "Fecha máxima (inclusive); si es sólo un día lo incluye completo"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param page query int false "Página"
+// @Param limit query int false "Registros por página"
+// @Param deleted query bool false "Listar sólo los registros dados de baja"
+// @Success 200 {object} web.pageResponse
+// @Failure 400 {object} web.errorResponse
+// @Router /api/v1/turnos [get]
+func (h *turnoHandler) GetTurnos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, err := parseListOptions(c)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		filter, err := turnoFilter(c, opts)
+		if err != nil {
+			web.Failure(c, 400, err)
+			return
+		}
+		turnos, total, err := h.s.ListTurnos(c.Request.Context(), filter)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		web.SuccessPage(c, 200, turnos, opts.Page, opts.Limit, total, "Listado de turnos")
+	}
+}
+
 // GET
 // @Summary Obtener turno por ID
 // @Description Retorna un turno dado su ID
@@ -55,8 +181,9 @@ func (h *turnoHandler) CreateTurno() gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param idTurno path int true "ID del turno a obtener"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Header 200 {string} ETag "Versión del registro"
+// @Failure 400 {object} web.errorResponse
 // @Router /api/v1/turnos/{idTurno} [get]
 func (h *turnoHandler) GetTurnoByID() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -66,19 +193,20 @@ func (h *turnoHandler) GetTurnoByID() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("Invalid Json"))
 			return
 		}
-		turno, err := h.s.GetTurnoByID(id)
+		turno, err := h.s.GetTurnoByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el turno indicado"))
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, turno.Version)
 		web.Success(c, 200, turno, "El turno se ha encontrado por su ID")
 	}
 }
 
 func validateFieldsTurno(turno *domain.Turno) (bool, error) {
 	switch {
-	case turno.DescripcionTurno == "" || turno.FechaTurno == "" || turno.IdOdontologo == "" || turno.IdPaciente == "":
-		return false, errors.New("No puede haber campos vacíos ni la fecha puede ser posterior a la actual")
+	case turno.DescripcionTurno == "" || turno.FechaTurno.IsZero() || turno.IdOdontologo == 0 || turno.IdPaciente == 0:
+		return false, domain.NewError(domain.ErrValidation, "No puede haber campos vacíos ni la fecha puede ser posterior a la actual")
 	}
 	return true, nil
 }
@@ -90,9 +218,12 @@ func validateFieldsTurno(turno *domain.Turno) (bool, error) {
 // @Accept json
 // @Produce json
 // @Param idTurno path int true "ID del turno a actualizar"
+// @Param If-Match header string false "ETag de la versión leída"
 // @Param body body domain.Turno true "Información actualizada del turno"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/turnos/{idTurno} [put]
 func (h *turnoHandler) UpdateTurno() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -103,27 +234,34 @@ func (h *turnoHandler) UpdateTurno() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetTurnoByID(id)
+		version, err := ifMatch(c)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetTurnoByID(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
 			return
 		}
 		var turno domain.Turno
 		err = c.ShouldBindJSON(&turno)
 		if err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
 		valid, err := validateFieldsTurno(&turno)
 		if !valid {
-			web.Failure(c, 400, err)
+			web.Failure(c, 422, err)
 			return
 		}
-		p, err := h.s.UpdateTurno(id, turno)
+		turno.Version = version
+		p, err := h.s.UpdateTurno(c.Request.Context(), id, turno)
 		if err != nil {
-			web.Failure(c, 409, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 200, p, "El turno ha sido correctamente actualizado")
 	}
 }
@@ -135,16 +273,20 @@ func (h *turnoHandler) UpdateTurno() gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param idTurno path int true "ID del turno a actualizar"
+// @Param If-Match header string false "ETag de la versión leída"
 // @Param body body Request true "Campos a actualizar del turno"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/turnos/{idTurno} [patch]
 func (h *turnoHandler) UpdateTurnoForField() gin.HandlerFunc {
 	type Request struct {
-		DescripcionTurno string `json:"descripcionTurno,omitempty"`
-		FechaTurno       string `json:"fechaTurno,omitempty"`
-		IdOdontologo     string `json:"idOdontologo,omitempty"`
-		IdPaciente       string `json:"idPaciente,omitempty"`
+		DescripcionTurno string       `json:"descripcionTurno,omitempty"`
+		FechaTurno       domain.Fecha `json:"fechaTurno,omitempty" swaggertype:"string" format:"date-time"`
+		DuracionMinutos  int          `json:"duracionMinutos,omitempty"`
+		IdOdontologo     int          `json:"idOdontologo,omitempty"`
+		IdPaciente       int          `json:"idPaciente,omitempty"`
 	}
 	return func(c *gin.Context) {
 
@@ -155,26 +297,34 @@ func (h *turnoHandler) UpdateTurnoForField() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetTurnoByID(id)
+		version, err := ifMatch(c)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 400, err)
+			return
+		}
+		_, err = h.s.GetTurnoByID(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
 			return
 		}
 		if err := c.ShouldBindJSON(&r); err != nil {
-			web.Failure(c, 400, errors.New("Invalid Json"))
+			web.Failure(c, 400, bindError(err))
 			return
 		}
 		update := domain.Turno{
 			DescripcionTurno: r.DescripcionTurno,
 			FechaTurno:       r.FechaTurno,
+			DuracionMinutos:  r.DuracionMinutos,
 			IdOdontologo:     r.IdOdontologo,
 			IdPaciente:       r.IdPaciente,
+			Version:          version,
 		}
-		p, err := h.s.UpdateTurno(id, update)
+		p, err := h.s.UpdateTurno(c.Request.Context(), id, update)
 		if err != nil {
-			web.Failure(c, 409, err)
+			web.Failure(c, 500, err)
 			return
 		}
+		setETag(c, p.Version)
 		web.Success(c, 200, p, "El turno ha sido correctamente actualizado")
 	}
 }
@@ -186,8 +336,10 @@ func (h *turnoHandler) UpdateTurnoForField() gin.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param idTurno path int true "ID del turno a eliminar"
-// @Success 200 {object} web.response 
-// @Failure 400 {object} web.errorResponse 
+// @Param If-Match header string false "ETag de la versión leída"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 412 {object} web.errorResponse
 // @Router /api/v1/turnos/{idTurno} [delete]
 func (h *turnoHandler) DeleteTurno() gin.HandlerFunc {
 
@@ -199,16 +351,130 @@ func (h *turnoHandler) DeleteTurno() gin.HandlerFunc {
 			web.Failure(c, 400, errors.New("ID inválido"))
 			return
 		}
-		_, err = h.s.GetTurnoByID(id)
+		version, err := ifMatch(c)
 		if err != nil {
-			web.Failure(c, 404, errors.New("No se ha encontrado el ID indicado"))
+			web.Failure(c, 400, err)
 			return
 		}
-		err = h.s.DeleteTurno(id)
+		_, err = h.s.GetTurnoByID(c.Request.Context(), id)
 		if err != nil {
-			web.Failure(c, 404, err)
+			web.Failure(c, 500, err)
+			return
+		}
+		err = h.s.DeleteTurno(c.Request.Context(), id, version)
+		if err != nil {
+			web.Failure(c, 500, err)
 			return
 		}
 		web.Success(c, 200, nil, "El turno ha sido correctamente eliminado")
 	}
 }
+
+// POST
+// @Summary Restaurar un turno dado de baja
+// @Description Deshace la baja del turno
+// @Tags Turnos
+// @Produce json
+// @Param idTurno path int true "ID del turno a restaurar"
+// @Success 200 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 404 {object} web.errorResponse
+// @Failure 409 {object} web.errorResponse
+// @Router /api/v1/turnos/{idTurno}/restore [post]
+func (h *turnoHandler) RestoreTurno() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("idTurno"))
+		if err != nil {
+			web.Failure(c, 400, errors.New("ID inválido"))
+			return
+		}
+		p, err := h.s.RestoreTurno(c.Request.Context(), id)
+		if err != nil {
+			web.Failure(c, 500, err)
+			return
+		}
+		setETag(c, p.Version)
+		web.Success(c, 200, p, "El turno ha sido restaurado")
+	}
+}
+
+func turnoFilter(c *gin.Context, opts domain.ListOptions) (domain.TurnoFilter, error) {
+	filter := domain.TurnoFilter{ListOptions: opts}
+	var err error
+	if idOdontologo := c.Query("idOdontologo"); idOdontologo != "" {
+		if filter.IdOdontologo, err = strconv.Atoi(idOdontologo); err != nil {
+			return filter, errors.New("idOdontologo inválido")
+		}
+	}
+	if idPaciente := c.Query("idPaciente"); idPaciente != "" {
+		if filter.IdPaciente, err = strconv.Atoi(idPaciente); err != nil {
+			return filter, errors.New("idPaciente inválido")
+		}
+	}
+	if filter.Desde, err = fechaQuery(c, "desde", false); err != nil {
+		return filter, err
+	}
+	filter.Hasta, err = fechaQuery(c, "hasta", true)
+	return filter, err
+}
+
+var turnoColumns = []csvColumn[domain.Turno]{
+	{name: "idTurno", get: func(t *domain.Turno) string { return strconv.Itoa(t.IdTurno) }},
+	{name: "descripcionTurno", get: func(t *domain.Turno) string { return t.DescripcionTurno }, set: csvText(func(t *domain.Turno) *string { return &t.DescripcionTurno })},
+	{name: "fechaTurno", get: func(t *domain.Turno) string { return t.FechaTurno.String() }, set: csvFecha(func(t *domain.Turno) *domain.Fecha { return &t.FechaTurno })},
+	{name: "duracionMinutos", get: func(t *domain.Turno) string { return strconv.Itoa(t.DuracionMinutos) }, set: csvInt(func(t *domain.Turno) *int { return &t.DuracionMinutos }), optional: true},
+	{name: "idOdontologo", get: func(t *domain.Turno) string { return strconv.Itoa(t.IdOdontologo) }, set: csvInt(func(t *domain.Turno) *int { return &t.IdOdontologo })},
+	{name: "idPaciente", get: func(t *domain.Turno) string { return strconv.Itoa(t.IdPaciente) }, set: csvInt(func(t *domain.Turno) *int { return &t.IdPaciente })},
+	{name: "idSerie", get: func(t *domain.Turno) string { return csvID(t.IdSerie) }, set: csvInt(func(t *domain.Turno) *int { return &t.IdSerie }), optional: true},
+	{name: "estado", get: func(t *domain.Turno) string { return t.Estado }},
+	{name: "motivoCancelacion", get: func(t *domain.Turno) string { return t.MotivoCancelacion }},
+	{name: "version", get: func(t *domain.Turno) string { return strconv.Itoa(t.Version) }},
+	{name: "deletedAt", get: func(t *domain.Turno) string { return t.DeletedAt }},
+}
+
+// POST
+// @Summary Importar turnos desde CSV
+// @Description Da de alta los turnos de un CSV cuyo encabezado nombra los campos. Si alguna fila falla no se importa ninguna
+// @Tags Turnos
+// @Accept text/csv
+// @Produce json
+// @Param dryRun query bool false "Validar sin guardar"
+// @Param map query string false "Renombre de columnas, por ejemplo Fecha:fechaTurno"
+// @Param delimiter query string false "Separador de columnas (por defecto ,)"
+// @Success 201 {object} web.response
+// @Failure 400 {object} web.errorResponse
+// @Failure 422 {object} web.response
+// @Router /api/v1/turnos/import [post]
+func (h *turnoHandler) ImportTurnos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		importCSV(c, turnoColumns, validateFieldsTurno, h.s.ImportTurnos)
+	}
+}
+
+// GET
+// @Summary Exportar turnos a CSV
+// @Description Descarga los turnos que cumplen los filtros del listado
+// @Tags Turnos
+// @Produce text/csv
+// @Param format query string false "csv"
+// @Param idOdontologo query int false "ID del odontólogo"
+// @Param idPaciente query int false "ID del paciente"
+// @Param desde query string false "Fecha mínima (inclusive), en RFC 3339, 2006-01-02 15:04 o 2006-01-02"
+// @Param hasta query string false "Fecha máxima (inclusive); si es sólo un día lo incluye completo"
+// @Param sort query string false "Campo de orden"
+// @Param order query string false "asc o desc"
+// @Param deleted query bool false "Exportar sólo los registros dados de baja"
+// @Success 200 {file} file
+// @Failure 400 {object} web.errorResponse
+// @Router /api/v1/turnos/export [get]
+func (h *turnoHandler) ExportTurnos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exportCSV(c, "turnos", turnoColumns, func(ctx context.Context, opts domain.ListOptions) ([]domain.Turno, error) {
+			filter, err := turnoFilter(c, opts)
+			if err != nil {
+				return nil, err
+			}
+			return h.s.ExportTurnos(ctx, filter)
+		})
+	}
+}
@@ -2,17 +2,28 @@ package main
 
 import (
 	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
+	"github.com/MechiBakker/BE3-FINAL/cmd/server/docs"
 	"github.com/MechiBakker/BE3-FINAL/cmd/server/handler"
+	"github.com/MechiBakker/BE3-FINAL/internal/auditoria"
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/MechiBakker/BE3-FINAL/internal/odontologo"
 	"github.com/MechiBakker/BE3-FINAL/internal/paciente"
+	"github.com/MechiBakker/BE3-FINAL/internal/serie"
 	"github.com/MechiBakker/BE3-FINAL/internal/turno"
-	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+	"github.com/MechiBakker/BE3-FINAL/pkg/config"
 	"github.com/MechiBakker/BE3-FINAL/pkg/middleware"
-	"github.com/MechiBakker/BE3-FINAL/cmd/server/docs"
-	"log"
+	"github.com/MechiBakker/BE3-FINAL/pkg/migrations"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -27,69 +38,252 @@ import (
 // @license.name Apache 2.0
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 func main() {
-	db, err := sql.Open("mysql", "root:root@tcp(localhost:3306)/turnos_odontologia")
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Println(config.Usage())
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = db.Ping()
-	if err != nil {
-		panic(err.Error())
+	for _, warning := range cfg.Warnings {
+		log.Print(warning)
+	}
+	if len(cfg.Args) > 0 {
+		var err error
+		switch cfg.Args[0] {
+		case "migrate":
+			err = runMigrate(cfg, cfg.Args[1:])
+		case "backup":
+			err = runBackup(cfg, cfg.Args[1:])
+		case "restore":
+			err = runRestore(cfg, cfg.Args[1:])
+		case "seed":
+			err = runSeed(cfg, cfg.Args[1:])
+		default:
+			err = fmt.Errorf("subcomando desconocido %q (migrate, backup, restore o seed)", cfg.Args[0])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(cfg.Actors()) == 0 && cfg.Log.Level != config.LevelError {
+		log.Print("sin AUTH_TOKEN ni AUTH_TOKENS: las rutas protegidas rechazarán todas las peticiones")
 	}
 
-	storage := store.NewSqlStore(db)
+	storage, err := newStorage(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var cached *store.CacheStore
+	if cfg.Cache.TTL.Duration > 0 {
+		cached = store.NewCacheStore(storage, cfg.Cache.TTL.Duration, cfg.Cache.Size)
+		storage = cached
+	}
 
 	repo := odontologo.NewRepository(storage)
-	service := odontologo.NewService(repo)
+	service := odontologo.NewService(repo, domain.Calendario{
+		Granularidad: cfg.Agenda.Granularidad.Duration,
+		Feriados:     cfg.Agenda.Feriados,
+	})
 	odontologoHandler := handler.NewOdontologoHandler(service)
 	repoPaciente := paciente.NewRepository(storage)
 	servicePaciente := paciente.NewService(repoPaciente)
 	pacienteHandler := handler.NewPacienteHandler(servicePaciente)
 
 	repoTurno := turno.NewRepository(storage)
-	serviceTurno := turno.NewService(repoTurno)
+	serviceTurno := turno.NewService(repoTurno, service, servicePaciente)
 	turnoHandler := handler.NewTurnoHandler(serviceTurno)
-	
-	engine := gin.Default()
+
+	repoSerie := serie.NewRepository(storage)
+	serviceSerie := serie.NewService(repoSerie)
+	serieHandler := handler.NewSerieHandler(serviceSerie)
+
+	repoAuditoria := auditoria.NewRepository(storage)
+	serviceAuditoria := auditoria.NewService(repoAuditoria)
+	auditoriaHandler := handler.NewAuditoriaHandler(serviceAuditoria)
+
+	if cfg.Log.Level == config.LevelDebug {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	engine := gin.New()
+	if cfg.Log.Level == config.LevelDebug || cfg.Log.Level == config.LevelInfo {
+		engine.Use(gin.Logger())
+		engine.Use(middleware.Logger(cfg.PublicHost()))
+	}
 	engine.Use(gin.Recovery())
-	engine.Use(middleware.Logger())
 
-	docs.SwaggerInfo.Host = "localhost:8080"
+	docs.SwaggerInfo.Host = cfg.PublicHost()
 	engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	engine.SetTrustedProxies([]string{"127.0.0.1"})
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
+	auth := middleware.Authentication(cfg.Actors())
+	authBajas := middleware.AuthenticationForDeleted(cfg.Actors())
 
 	engine.GET("/api/v1/ping", func(c *gin.Context) { c.String(200, "pong") })
 
 	odontologos := engine.Group("/api/v1/odontologos")
 	{
 		odontologos.POST("", odontologoHandler.CreateOdontologo())
+		odontologos.GET("", authBajas, odontologoHandler.GetOdontologos())
+		odontologos.POST("import", auth, odontologoHandler.ImportOdontologos())
+		odontologos.GET("export", authBajas, odontologoHandler.ExportOdontologos())
+		odontologos.GET("disponibilidad", odontologoHandler.GetPrimerTurnoLibre())
 		odontologos.GET(":idOdontologo", odontologoHandler.GetOdontologoByID())
-		odontologos.PUT(":idOdontologo", middleware.Authentication(),odontologoHandler.UpdateOdontologo())
-		odontologos.PATCH(":idOdontologo", middleware.Authentication(),odontologoHandler.UpdateOdontologoForField())
-		odontologos.DELETE(":idOdontologo", middleware.Authentication(),odontologoHandler.DeleteOdontologo())
-	
+		odontologos.PUT(":idOdontologo", auth, odontologoHandler.UpdateOdontologo())
+		odontologos.PATCH(":idOdontologo", auth, odontologoHandler.UpdateOdontologoForField())
+		odontologos.DELETE(":idOdontologo", auth, odontologoHandler.DeleteOdontologo())
+		odontologos.POST(":idOdontologo/restore", auth, odontologoHandler.RestoreOdontologo())
+		odontologos.GET(":idOdontologo/disponibilidad", odontologoHandler.GetDisponibilidad())
+		odontologos.GET(":idOdontologo/agenda", odontologoHandler.GetAgenda())
+		odontologos.PUT(":idOdontologo/agenda/horarios", auth, odontologoHandler.UpdateHorarios())
+		odontologos.POST(":idOdontologo/agenda/excepciones", auth, odontologoHandler.CreateExcepcion())
+		odontologos.DELETE(":idOdontologo/agenda/excepciones/:idExcepcion", auth, odontologoHandler.DeleteExcepcion())
+
 	}
 
 	pacientes := engine.Group("/api/v1/pacientes")
 	{
-		pacientes.POST("", middleware.Authentication(),pacienteHandler.CreatePaciente())
+		pacientes.POST("", auth, pacienteHandler.CreatePaciente())
+		pacientes.GET("", auth, pacienteHandler.GetPacientes())
+		pacientes.POST("import", auth, pacienteHandler.ImportPacientes())
+		pacientes.GET("export", auth, pacienteHandler.ExportPacientes())
 		pacientes.GET(":idPaciente", pacienteHandler.GetPacienteByID())
-		pacientes.PUT(":idPaciente", middleware.Authentication(),pacienteHandler.UpdatePaciente())
-		pacientes.PATCH(":idPaciente", middleware.Authentication(),pacienteHandler.UpdatePacienteForField())
-		pacientes.DELETE(":idPaciente", middleware.Authentication(),pacienteHandler.DeletePaciente())
-	
+		pacientes.PUT(":idPaciente", auth, pacienteHandler.UpdatePaciente())
+		pacientes.PATCH(":idPaciente", auth, pacienteHandler.UpdatePacienteForField())
+		pacientes.DELETE(":idPaciente", auth, pacienteHandler.DeletePaciente())
+		pacientes.POST(":idPaciente/restore", auth, pacienteHandler.RestorePaciente())
 
 	}
 
 	turnos := engine.Group("/api/v1/turnos")
 	{
-		turnos.POST("", middleware.Authentication(),turnoHandler.CreateTurno())
+		turnos.POST("", auth, turnoHandler.CreateTurno())
+		turnos.GET("", authBajas, turnoHandler.GetTurnos())
+		turnos.POST("import", auth, turnoHandler.ImportTurnos())
+		turnos.GET("export", authBajas, turnoHandler.ExportTurnos())
+		turnos.POST("con-paciente", auth, turnoHandler.CreateTurnoConPaciente())
+		turnos.POST("by-dni-matricula", auth, turnoHandler.CreateTurnoPorDniMatricula())
 		turnos.GET(":idTurno", turnoHandler.GetTurnoByID())
-		turnos.PUT(":idTurno", middleware.Authentication(),turnoHandler.UpdateTurno())
-		turnos.PATCH(":idTurno", middleware.Authentication(),turnoHandler.UpdateTurnoForField())
-		turnos.DELETE(":idTurno", middleware.Authentication(),turnoHandler.DeleteTurno())
+		turnos.PUT(":idTurno", auth, turnoHandler.UpdateTurno())
+		turnos.PATCH(":idTurno", auth, turnoHandler.UpdateTurnoForField())
+		turnos.DELETE(":idTurno", auth, turnoHandler.DeleteTurno())
+		turnos.POST(":idTurno/restore", auth, turnoHandler.RestoreTurno())
+		turnos.GET(":idTurno/historial", turnoHandler.GetHistorialTurno())
+		turnos.POST(":idTurno/confirmar", auth, turnoHandler.ConfirmarTurno())
+		turnos.POST(":idTurno/cancelar", auth, turnoHandler.CancelarTurno())
+		turnos.POST(":idTurno/en-sala", auth, turnoHandler.TurnoEnSala())
+		turnos.POST(":idTurno/atender", auth, turnoHandler.AtenderTurno())
+		turnos.POST(":idTurno/ausente", auth, turnoHandler.TurnoAusente())
 	}
 
-	engine.Run(":8080")
+	series := engine.Group("/api/v1/series")
+	{
+		series.POST("", auth, serieHandler.CreateSerie())
+		series.GET(":idSerie", serieHandler.GetSerie())
+		series.PATCH(":idSerie/turnos/:idTurno", auth, serieHandler.UpdateTurnosSerie())
+		series.POST(":idSerie/turnos/:idTurno/cancelar", auth, serieHandler.CancelarTurnosSerie())
+	}
+
+	audit := engine.Group("/api/v1/audit")
+	{
+		audit.GET("", auth, auditoriaHandler.GetAuditoria())
+		audit.GET("verify", auth, auditoriaHandler.VerificarAuditoria())
+	}
+
+	if cached != nil {
+		engine.GET("/api/v1/cache/stats", auth, handler.NewCacheHandler(cached).GetStats())
+	}
+
+	if err := engine.Run(cfg.Server.Addr); err != nil {
+		log.Fatal(err)
+	}
+
+}
 
+// newStorage construye el StoreInterface indicado por cfg.Store.Backend:
+// "mysql", "sqlite", "json" o "memory". cfg.Store.Path es el archivo de la
+// base SQLite, del documento JSON o de los datos iniciales del store en
+// memoria. La base SQLite se migra automáticamente; una base MySQL sólo con
+// cfg.Store.AutoMigrate, y si su esquema está atrasado el servidor no
+// arranca.
+func newStorage(cfg config.Config) (store.StoreInterface, error) {
+	path := cfg.Store.Path
+	switch cfg.Store.Backend {
+	case config.BackendJSON:
+		if path == "" {
+			path = "odontologos.json"
+		}
+		return store.NewJsonStore(path), nil
+	case config.BackendMemory:
+		if path == "" {
+			return store.NewMemoryStore(), nil
+		}
+		return store.NewMemoryStoreFromFile(path)
+	}
+	opts := []store.SqlOption{store.WithQueryTimeout(cfg.Store.QueryTimeout.Duration)}
+	db, dialect, err := openDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == migrations.SQLite || cfg.Store.AutoMigrate {
+		if _, err = migrator.Up(); err != nil {
+			return nil, err
+		}
+	}
+	if err = migrator.Check(); err != nil {
+		return nil, err
+	}
+	if dialect == migrations.SQLite {
+		return store.NewSqliteStore(db, opts...), nil
+	}
+	return store.NewSqlStore(db, opts...), nil
+}
+
+// openDB abre la base de datos de los backends SQL y devuelve también el
+// dialecto de migraciones que le corresponde.
+func openDB(cfg config.Config) (*sql.DB, string, error) {
+	switch cfg.Store.Backend {
+	case config.BackendMySQL:
+		dsn := mysql.NewConfig()
+		dsn.User = cfg.DB.User
+		dsn.Passwd = cfg.DB.Pass
+		dsn.Net = "tcp"
+		dsn.Addr = net.JoinHostPort(cfg.DB.Host, strconv.Itoa(cfg.DB.Port))
+		dsn.DBName = cfg.DB.Name
+		dsn.ClientFoundRows = true
+		// Las columnas DATETIME se leen como time.Time en UTC.
+		dsn.ParseTime = true
+		db, err := sql.Open("mysql", dsn.FormatDSN())
+		if err != nil {
+			return nil, "", err
+		}
+		db.SetMaxOpenConns(cfg.DB.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.DB.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.DB.ConnMaxLifetime.Duration)
+		if err = db.Ping(); err != nil {
+			return nil, "", err
+		}
+		return db, migrations.MySQL, nil
+	case config.BackendSQLite:
+		path := cfg.Store.Path
+		if path == "" {
+			path = "turnos_odontologia.db"
+		}
+		db, err := store.OpenSqlite(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return db, migrations.SQLite, nil
+	}
+	return nil, "", fmt.Errorf("store desconocido o sin base de datos: %q", cfg.Store.Backend)
 }
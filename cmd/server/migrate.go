@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MechiBakker/BE3-FINAL/pkg/config"
+	"github.com/MechiBakker/BE3-FINAL/pkg/migrations"
+)
+
+// runMigrate implementa el subcomando "migrate up|down [pasos]|status".
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("uso: migrate up | down [pasos] | status")
+	}
+	db, dialect, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up()
+		for _, m := range done {
+			fmt.Printf("aplicada %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Println("el esquema ya está actualizado")
+		}
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("cantidad de pasos inválida: %q", args[1])
+			}
+		}
+		done, err := migrator.Down(steps)
+		for _, m := range done {
+			fmt.Printf("revertida %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pendiente"
+			if s.Applied {
+				state = "aplicada " + s.AppliedAt
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	}
+	return fmt.Errorf("subcomando de migrate desconocido: %q", args[0])
+}
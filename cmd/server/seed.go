@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/pkg/config"
+	"github.com/MechiBakker/BE3-FINAL/pkg/seed"
+)
+
+// runSeed implementa el subcomando "seed", que carga datos de prueba en el
+// store configurado. Sin -desde los turnos arrancan el día de hoy y las
+// altas no pasan de hoy, así que para repetir exactamente una carga hay que
+// fijar también -desde, -hasta y -hoy.
+func runSeed(cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	semilla := fs.Int64("seed", 1, "semilla del generador aleatorio")
+	odontologos := fs.Int("odontologos", 10, "cantidad de odontólogos")
+	pacientes := fs.Int("pacientes", 200, "cantidad de pacientes")
+	turnos := fs.Int("turnos", 500, "cantidad de turnos")
+	desde := fs.String("desde", time.Now().Format("2006-01-02"), "primer día de turnos (AAAA-MM-DD)")
+	dias := fs.Int("dias", 30, "cantidad de días con turnos a partir de -desde")
+	hasta := fs.String("hasta", "", "último día de turnos (AAAA-MM-DD); reemplaza a -dias")
+	hoy := fs.String("hoy", time.Now().Format("2006-01-02"), "día máximo de alta de los pacientes (AAAA-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("uso: seed [-seed n] [-odontologos n] [-pacientes n] [-turnos n] [-desde AAAA-MM-DD] [-dias n | -hasta AAAA-MM-DD] [-hoy AAAA-MM-DD]: %w", err)
+	}
+
+	opts := seed.Options{Semilla: *semilla, Odontologos: *odontologos, Pacientes: *pacientes, Turnos: *turnos}
+	var err error
+	if opts.Desde, err = time.Parse("2006-01-02", *desde); err != nil {
+		return fmt.Errorf("seed: -desde inválido: %q", *desde)
+	}
+	opts.Hasta = opts.Desde.AddDate(0, 0, *dias-1)
+	if *hasta != "" {
+		if opts.Hasta, err = time.Parse("2006-01-02", *hasta); err != nil {
+			return fmt.Errorf("seed: -hasta inválido: %q", *hasta)
+		}
+	}
+	if opts.Hoy, err = time.Parse("2006-01-02", *hoy); err != nil {
+		return fmt.Errorf("seed: -hoy inválido: %q", *hoy)
+	}
+
+	storage, err := newStorage(cfg)
+	if err != nil {
+		return err
+	}
+	res, err := seed.Run(context.Background(), storage, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "seed: %d odontólogos, %d pacientes y %d turnos cargados en %s\n",
+		res.Odontologos, res.Pacientes, res.Turnos, cfg.Store.Backend)
+	return nil
+}
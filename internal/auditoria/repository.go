@@ -0,0 +1,56 @@
+package auditoria
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+type Repository interface {
+	// Registrar agrega una entrada con el actor del contexto y los snapshots
+	// antes y despues, que pueden ser nil en altas y bajas.
+	Registrar(ctx context.Context, entidad string, id int, accion string, antes, despues interface{}) error
+
+	List(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error)
+}
+
+type repository struct {
+	storage store.StoreInterface
+}
+
+func NewRepository(storage store.StoreInterface) Repository {
+	return &repository{storage}
+}
+
+func (r *repository) Registrar(ctx context.Context, entidad string, id int, accion string, antes, despues interface{}) error {
+	a := domain.Auditoria{
+		Fecha:     time.Now().UTC().Format(time.RFC3339),
+		Actor:     domain.ActorFrom(ctx),
+		Entidad:   entidad,
+		IdEntidad: id,
+		Accion:    accion,
+	}
+	var err error
+	if a.Antes, err = snapshot(antes); err != nil {
+		return err
+	}
+	if a.Despues, err = snapshot(despues); err != nil {
+		return err
+	}
+	_, err = r.storage.AppendAuditoria(ctx, a)
+	return err
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (r *repository) List(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error) {
+	return r.storage.ListAuditoria(ctx, filter)
+}
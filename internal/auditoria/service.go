@@ -0,0 +1,92 @@
+package auditoria
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+type Service interface {
+	// List devuelve las entradas con los cambios de cada modificación.
+	List(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error)
+
+	// Verificar recorre toda la cadena y controla que ninguna entrada haya
+	// sido modificada, quitada o reordenada.
+	Verificar(ctx context.Context) (domain.VerificacionAuditoria, error)
+}
+
+type service struct {
+	r Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{r}
+}
+
+func (s *service) List(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error) {
+	filter.Normalize()
+	entradas, total, err := s.r.List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range entradas {
+		if entradas[i].Accion == domain.AccionModificacion {
+			entradas[i].Cambios = cambios(entradas[i].Antes, entradas[i].Despues)
+		}
+	}
+	return entradas, total, nil
+}
+
+// cambios compara dos snapshots campo por campo.
+func cambios(antes, despues json.RawMessage) map[string]domain.Cambio {
+	var a, d map[string]interface{}
+	json.Unmarshal(antes, &a)
+	json.Unmarshal(despues, &d)
+	result := map[string]domain.Cambio{}
+	for campo, valor := range d {
+		if !reflect.DeepEqual(a[campo], valor) {
+			result[campo] = domain.Cambio{Antes: a[campo], Despues: valor}
+		}
+	}
+	for campo, valor := range a {
+		if _, ok := d[campo]; !ok {
+			result[campo] = domain.Cambio{Antes: valor}
+		}
+	}
+	return result
+}
+
+func (s *service) Verificar(ctx context.Context) (domain.VerificacionAuditoria, error) {
+	var v domain.VerificacionAuditoria
+	filter := domain.AuditoriaFilter{}
+	filter.Limit = domain.MaxLimit
+	hashAnterior := ""
+	for filter.Page = 1; ; filter.Page++ {
+		entradas, total, err := s.r.List(ctx, filter)
+		if err != nil {
+			return v, err
+		}
+		for _, a := range entradas {
+			v.Entradas++
+			switch {
+			case a.IdAuditoria != v.Entradas:
+				v.Detalle = "Falta la entrada anterior o la numeración fue alterada"
+			case a.HashAnterior != hashAnterior:
+				v.Detalle = "La entrada no está encadenada a la anterior"
+			case a.Hash != a.CalcularHash():
+				v.Detalle = "El contenido de la entrada fue modificado"
+			}
+			if v.Detalle != "" {
+				v.IdAuditoria = a.IdAuditoria
+				return v, nil
+			}
+			hashAnterior = a.Hash
+		}
+		if len(entradas) == 0 || v.Entradas >= total {
+			v.Valida = true
+			return v, nil
+		}
+	}
+}
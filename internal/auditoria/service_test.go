@@ -0,0 +1,151 @@
+package auditoria
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+// registrar agrega tres entradas a un store en memoria y las devuelve en
+// orden.
+func registrar(t *testing.T) []domain.Auditoria {
+	t.Helper()
+	ctx := context.Background()
+	storage := store.NewMemoryStore()
+	r := NewRepository(storage)
+	odontologo := domain.Odontologo{IdOdontologo: 1, NombreOdontologo: "Ana", ApellidoOdontologo: "Pérez", MatriculaOdontologo: "M-1"}
+	modificado := odontologo
+	modificado.ApellidoOdontologo = "López"
+	pasos := []struct {
+		accion         string
+		antes, despues interface{}
+	}{
+		{domain.AccionAlta, nil, odontologo},
+		{domain.AccionModificacion, odontologo, modificado},
+		{domain.AccionBaja, modificado, nil},
+	}
+	for _, p := range pasos {
+		if err := r.Registrar(ctx, "odontologo", 1, p.accion, p.antes, p.despues); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entradas, _, err := r.List(ctx, domain.AuditoriaFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entradas
+}
+
+func TestRegistrarEncadenaEntradas(t *testing.T) {
+	entradas := registrar(t)
+	if len(entradas) != 3 {
+		t.Fatalf("se obtuvieron %d entradas, se esperaban 3", len(entradas))
+	}
+	hashAnterior := ""
+	for i, a := range entradas {
+		if a.IdAuditoria != i+1 {
+			t.Errorf("entrada %d: IdAuditoria = %d", i, a.IdAuditoria)
+		}
+		if a.HashAnterior != hashAnterior {
+			t.Errorf("entrada %d: HashAnterior = %q, se esperaba %q", i, a.HashAnterior, hashAnterior)
+		}
+		if a.Hash == "" || a.Hash != a.CalcularHash() {
+			t.Errorf("entrada %d: Hash = %q no coincide con el contenido", i, a.Hash)
+		}
+		hashAnterior = a.Hash
+	}
+}
+
+func TestVerificar(t *testing.T) {
+	casos := []struct {
+		nombre      string
+		alterar     func(entradas []domain.Auditoria) []domain.Auditoria
+		idAuditoria int
+		detalle     string
+	}{
+		{"cadena intacta", func(e []domain.Auditoria) []domain.Auditoria { return e }, 0, ""},
+		{"contenido modificado", func(e []domain.Auditoria) []domain.Auditoria {
+			e[1].Actor = "otro"
+			return e
+		}, 2, "El contenido de la entrada fue modificado"},
+		{"snapshot modificado", func(e []domain.Auditoria) []domain.Auditoria {
+			e[0].Despues = []byte(`{"idOdontologo":1}`)
+			return e
+		}, 1, "El contenido de la entrada fue modificado"},
+		{"hash recalculado sin encadenar", func(e []domain.Auditoria) []domain.Auditoria {
+			e[0].Actor = "otro"
+			e[0].Hash = e[0].CalcularHash()
+			return e
+		}, 2, "La entrada no está encadenada a la anterior"},
+		{"entrada quitada", func(e []domain.Auditoria) []domain.Auditoria {
+			return append(e[:1], e[2:]...)
+		}, 3, "Falta la entrada anterior o la numeración fue alterada"},
+		{"entradas reordenadas", func(e []domain.Auditoria) []domain.Auditoria {
+			e[1].IdAuditoria, e[2].IdAuditoria = 3, 2
+			return e
+		}, 2, "La entrada no está encadenada a la anterior"},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			ctx := context.Background()
+			storage := store.NewMemoryStore()
+			if err := storage.Import(ctx, domain.Respaldo{Auditoria: c.alterar(registrar(t))}); err != nil {
+				t.Fatal(err)
+			}
+			v, err := NewService(NewRepository(storage)).Verificar(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Valida != (c.detalle == "") || v.IdAuditoria != c.idAuditoria || v.Detalle != c.detalle {
+				t.Fatalf("se obtuvo %+v, se esperaba la entrada %d con %q", v, c.idAuditoria, c.detalle)
+			}
+		})
+	}
+}
+
+func TestListFiltraPorFecha(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStore()
+	var entradas []domain.Auditoria
+	for i, f := range []string{"2030-01-01T09:59:59Z", "2030-01-01T10:00:00Z", "2030-01-01T23:59:59Z", "2030-01-02T00:00:00Z"} {
+		entradas = append(entradas, domain.Auditoria{IdAuditoria: i + 1, Fecha: f, Actor: "ana", Entidad: domain.EntidadTurno, IdEntidad: 1, Accion: domain.AccionAlta})
+	}
+	if err := storage.Import(ctx, domain.Respaldo{Auditoria: entradas}); err != nil {
+		t.Fatal(err)
+	}
+	fecha := func(s string) domain.Fecha {
+		f, err := domain.ParseFecha(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	casos := []struct {
+		nombre    string
+		desde     domain.Fecha
+		hasta     domain.Fecha
+		esperados []int
+	}{
+		{"desde con otro huso horario", fecha("2030-01-01T07:00:00-03:00"), domain.Fecha{}, []int{2, 3, 4}},
+		{"hasta con fracción de segundo", domain.Fecha{}, fecha("2030-01-01T10:00:00.5Z"), []int{1, 2}},
+		{"hasta el último instante del día", fecha("2030-01-01"), fecha("2030-01-01T23:59:59.999999999Z"), []int{1, 2, 3}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			result, _, err := NewService(NewRepository(storage)).List(ctx, domain.AuditoriaFilter{Desde: c.desde, Hasta: c.hasta})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, a := range result {
+				ids = append(ids, a.IdAuditoria)
+			}
+			if !reflect.DeepEqual(ids, c.esperados) {
+				t.Fatalf("se obtuvieron las entradas %v, se esperaban %v", ids, c.esperados)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// dias son los nombres de los días de la semana, en el orden de
+// time.Weekday.
+var dias = [...]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}
+
+// Horario es una franja semanal en la que el odontólogo atiende. Dia va de
+// 0 (domingo) a 6 (sábado), como time.Weekday; Desde y Hasta son horas
+// "15:04" del mismo día, en UTC como el resto de las fechas.
+type Horario struct {
+	Dia   int    `json:"dia"`
+	Desde string `json:"desde"`
+	Hasta string `json:"hasta"`
+}
+
+// Excepcion es un período en el que el odontólogo no atiende aunque su
+// horario semanal lo indique, por ejemplo vacaciones. Desde y Hasta son días
+// "2006-01-02" y ambos se incluyen.
+type Excepcion struct {
+	IdExcepcion  int    `json:"idExcepcion"`
+	IdOdontologo int    `json:"idOdontologo"`
+	Desde        string `json:"desde"`
+	Hasta        string `json:"hasta"`
+	Motivo       string `json:"motivo"`
+}
+
+// Agenda reúne el horario semanal y las excepciones de un odontólogo. Sin
+// horarios la agenda no está configurada y el odontólogo atiende a
+// cualquier hora, salvo durante sus excepciones.
+type Agenda struct {
+	IdOdontologo int         `json:"idOdontologo"`
+	Horarios     []Horario   `json:"horarios"`
+	Excepciones  []Excepcion `json:"excepciones"`
+}
+
+// ValidarHorarios verifica cada horario y que no haya dos del mismo día que
+// se superpongan.
+func ValidarHorarios(horarios []Horario) error {
+	ordenados := append([]Horario(nil), horarios...)
+	sort.Slice(ordenados, func(i, j int) bool {
+		if ordenados[i].Dia != ordenados[j].Dia {
+			return ordenados[i].Dia < ordenados[j].Dia
+		}
+		return ordenados[i].Desde < ordenados[j].Desde
+	})
+	for i, h := range ordenados {
+		if h.Dia < 0 || h.Dia > 6 {
+			return NewError(ErrValidation, "Día inválido %d: debe ir de 0 (domingo) a 6 (sábado)", h.Dia)
+		}
+		desde, errDesde := hora(h.Desde)
+		hasta, errHasta := hora(h.Hasta)
+		if errDesde != nil || errHasta != nil {
+			return NewError(ErrValidation, "Horario inválido %q a %q: las horas deben tener el formato 15:04", h.Desde, h.Hasta)
+		}
+		if hasta <= desde {
+			return NewError(ErrValidation, "Horario inválido del %s: %s no es anterior a %s", dias[h.Dia], h.Desde, h.Hasta)
+		}
+		if i > 0 && ordenados[i-1].Dia == h.Dia && ordenados[i-1].Hasta > h.Desde {
+			return NewError(ErrValidation, "Los horarios del %s se superponen: %s a %s y %s a %s",
+				dias[h.Dia], ordenados[i-1].Desde, ordenados[i-1].Hasta, h.Desde, h.Hasta)
+		}
+	}
+	return nil
+}
+
+// Validar verifica las fechas y el motivo de la excepción.
+func (e Excepcion) Validar() error {
+	desde, errDesde := time.Parse("2006-01-02", e.Desde)
+	hasta, errHasta := time.Parse("2006-01-02", e.Hasta)
+	switch {
+	case errDesde != nil || errHasta != nil:
+		return NewError(ErrValidation, "Excepción inválida %q a %q: las fechas deben tener el formato 2006-01-02", e.Desde, e.Hasta)
+	case hasta.Before(desde):
+		return NewError(ErrValidation, "Excepción inválida: %s es posterior a %s", e.Desde, e.Hasta)
+	case e.Motivo == "":
+		return NewError(ErrValidation, "La excepción debe indicar un motivo")
+	}
+	return nil
+}
+
+// Admite indica si el odontólogo atiende durante todo el turno t. Si no,
+// devuelve un error ErrValidation que explica por qué.
+func (a Agenda) Admite(t Turno) error {
+	inicio, fin := t.FechaTurno.Time(), t.Fin().Time()
+	for _, e := range a.Excepciones {
+		desde, errDesde := time.Parse("2006-01-02", e.Desde)
+		hasta, errHasta := time.Parse("2006-01-02", e.Hasta)
+		if errDesde != nil || errHasta != nil {
+			continue
+		}
+		if inicio.Before(hasta.AddDate(0, 0, 1)) && desde.Before(fin) {
+			return NewError(ErrValidation, "El odontólogo %d no atiende del %s al %s: %s", a.IdOdontologo, e.Desde, e.Hasta, e.Motivo)
+		}
+	}
+	if len(a.Horarios) == 0 {
+		return nil
+	}
+	dia := time.Date(inicio.Year(), inicio.Month(), inicio.Day(), 0, 0, 0, 0, time.UTC)
+	desde, hasta := inicio.Sub(dia), fin.Sub(dia)
+	for _, franja := range a.franjas(inicio.Weekday()) {
+		if franja[0] <= desde && hasta <= franja[1] {
+			return nil
+		}
+	}
+	return NewError(ErrValidation, "El odontólogo %d no atiende el %s %s de %s a %s", a.IdOdontologo,
+		dias[inicio.Weekday()], inicio.Format("2006-01-02"), inicio.Format("15:04"), fin.Format("15:04"))
+}
+
+// franjas devuelve los horarios del día como desplazamientos desde la
+// medianoche, ordenados y con los contiguos unidos, para que un turno pueda
+// ocupar dos horarios seguidos.
+func (a Agenda) franjas(dia time.Weekday) [][2]time.Duration {
+	var franjas [][2]time.Duration
+	for _, h := range a.Horarios {
+		desde, errDesde := hora(h.Desde)
+		hasta, errHasta := hora(h.Hasta)
+		if h.Dia == int(dia) && errDesde == nil && errHasta == nil {
+			franjas = append(franjas, [2]time.Duration{desde, hasta})
+		}
+	}
+	sort.Slice(franjas, func(i, j int) bool { return franjas[i][0] < franjas[j][0] })
+	var unidas [][2]time.Duration
+	for _, f := range franjas {
+		if n := len(unidas); n > 0 && f[0] <= unidas[n-1][1] {
+			unidas[n-1][1] = max(unidas[n-1][1], f[1])
+			continue
+		}
+		unidas = append(unidas, f)
+	}
+	return unidas
+}
+
+// hora lee una hora "15:04", con dos dígitos en la hora, como
+// desplazamiento desde la medianoche. Admite "24:00" como fin del día.
+func hora(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	if t.Format("15:04") != s {
+		return 0, fmt.Errorf("hora inválida %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Entidades que registra la auditoría.
+const (
+	EntidadOdontologo = "odontologo"
+	EntidadPaciente   = "paciente"
+	EntidadTurno      = "turno"
+	// EntidadAgenda registra los cambios de horarios y excepciones con el
+	// ID del odontólogo.
+	EntidadAgenda = "agenda"
+	EntidadSerie  = "serie"
+)
+
+// Acciones que registra la auditoría.
+const (
+	AccionAlta         = "alta"
+	AccionModificacion = "modificacion"
+	AccionBaja         = "baja"
+	AccionRestauracion = "restauracion"
+	AccionCambioEstado = "cambio_estado"
+)
+
+// Auditoria es una entrada del registro de cambios. Las entradas forman una
+// cadena: Hash cubre todos los campos y el Hash de la entrada anterior, por
+// lo que modificar o quitar una entrada invalida las siguientes.
+type Auditoria struct {
+	IdAuditoria  int               `json:"idAuditoria"`
+	Fecha        string            `json:"fecha"`
+	Actor        string            `json:"actor"`
+	Entidad      string            `json:"entidad"`
+	IdEntidad    int               `json:"idEntidad"`
+	Accion       string            `json:"accion"`
+	Antes        json.RawMessage   `json:"antes,omitempty"`
+	Despues      json.RawMessage   `json:"despues,omitempty"`
+	Cambios      map[string]Cambio `json:"cambios,omitempty"`
+	HashAnterior string            `json:"hashAnterior"`
+	Hash         string            `json:"hash"`
+}
+
+// Cambio es el valor de un campo antes y después de una modificación.
+type Cambio struct {
+	Antes   interface{} `json:"antes"`
+	Despues interface{} `json:"despues"`
+}
+
+// CalcularHash devuelve el hash SHA-256 de la entrada. Cambios se deriva de
+// Antes y Despues y no forma parte del hash; los snapshots se compactan
+// para que el resultado no dependa de cómo se guardó el JSON. Cada campo va
+// precedido de su longitud, para que mover texto de un campo al siguiente
+// cambie el hash.
+func (a Auditoria) CalcularHash() string {
+	h := sha256.New()
+	for _, campo := range [][]byte{
+		[]byte(strconv.Itoa(a.IdAuditoria)), []byte(a.Fecha), []byte(a.Actor), []byte(a.Entidad),
+		[]byte(strconv.Itoa(a.IdEntidad)), []byte(a.Accion), compactJSON(a.Antes), compactJSON(a.Despues),
+		[]byte(a.HashAnterior),
+	} {
+		fmt.Fprintf(h, "%d:", len(campo))
+		h.Write(campo)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func compactJSON(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// VerificacionAuditoria es el resultado de recorrer la cadena de
+// auditoría. Si no es válida, IdAuditoria es la primera entrada alterada.
+type VerificacionAuditoria struct {
+	Valida      bool   `json:"valida"`
+	Entradas    int    `json:"entradas"`
+	IdAuditoria int    `json:"idAuditoria,omitempty"`
+	Detalle     string `json:"detalle,omitempty"`
+}
+
+// AuditoriaFilter filtra el registro de auditoría por entidad, registro,
+// actor y un rango inclusivo de fechas. Las fechas se comparan en UTC con
+// precisión de segundos, la misma con la que se registran las entradas.
+type AuditoriaFilter struct {
+	ListOptions
+	Entidad   string
+	IdEntidad int
+	Actor     string
+	Desde     Fecha
+	Hasta     Fecha
+}
+
+// ActorAnonimo identifica los cambios hechos sin autenticación.
+const ActorAnonimo = "anónimo"
+
+type actorKey struct{}
+
+// WithActor devuelve un contexto que identifica a quien hace el pedido.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom devuelve el actor guardado con WithActor, o ActorAnonimo.
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorAnonimo
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "testing"
+
+func TestAuditoriaCalcularHash(t *testing.T) {
+	base := Auditoria{IdAuditoria: 2, Fecha: "2030-01-01T10:00:00Z", Actor: "ana|x", Entidad: EntidadTurno, IdEntidad: 1,
+		Accion: AccionModificacion, Antes: []byte(`{"descripcionTurno":"a|b"}`), Despues: []byte(`{"descripcionTurno":"c"}`), HashAnterior: "x"}
+	if base.CalcularHash() != base.CalcularHash() {
+		t.Fatal("el hash debería ser determinista")
+	}
+	reformateado := base
+	reformateado.Antes = []byte(`{ "descripcionTurno": "a|b" }`)
+	if reformateado.CalcularHash() != base.CalcularHash() {
+		t.Error("el hash no debería depender del formato del JSON")
+	}
+
+	casos := []struct {
+		nombre  string
+		cambiar func(a *Auditoria)
+	}{
+		{"texto movido del actor a la entidad", func(a *Auditoria) { a.Actor, a.Entidad = "ana", "x|"+EntidadTurno }},
+		{"texto movido entre la fecha y el actor", func(a *Auditoria) { a.Fecha, a.Actor = "2030-01-01T10:00:00Z|ana", "x" }},
+		{"snapshot vacío", func(a *Auditoria) { a.Despues = nil }},
+		{"otro hash anterior", func(a *Auditoria) { a.HashAnterior = "y" }},
+		{"otro id", func(a *Auditoria) { a.IdAuditoria = 3 }},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			otra := base
+			c.cambiar(&otra)
+			if otra.CalcularHash() == base.CalcularHash() {
+				t.Fatal("dos entradas distintas no deberían tener el mismo hash")
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+// Límites de una búsqueda de turnos libres.
+const (
+	MaxDiasDisponibilidad = 31
+	MaxTurnosLibres       = 1000
+	MaxPasoMinutos        = 24 * 60
+)
+
+// Calendario es la configuración de la clínica que usa la búsqueda de
+// turnos libres. Granularidad separa los comienzos posibles de los turnos;
+// Feriados son días "2006-01-02" en los que la búsqueda no propone turnos.
+// Los feriados no se controlan al dar de alta o modificar un turno, que
+// sólo se valida contra la Agenda del odontólogo.
+type Calendario struct {
+	Granularidad time.Duration
+	Feriados     []string
+}
+
+// ConsultaDisponibilidad describe una búsqueda de turnos libres que
+// comiencen entre Desde y Hasta, inclusive. PasoMinutos en 0 usa la
+// granularidad del Calendario y DuracionMinutos en 0 la duración por
+// defecto de los turnos.
+type ConsultaDisponibilidad struct {
+	Desde           Fecha
+	Hasta           Fecha
+	DuracionMinutos int
+	PasoMinutos     int
+	Limite          int
+}
+
+// TurnoLibre es un horario en el que el odontólogo puede recibir un turno.
+type TurnoLibre struct {
+	IdOdontologo int   `json:"idOdontologo"`
+	Desde        Fecha `json:"desde" swaggertype:"string" format:"date-time"`
+	Hasta        Fecha `json:"hasta" swaggertype:"string" format:"date-time"`
+}
+
+// Validar verifica el rango, la duración, el paso y el límite de la
+// consulta, que ya debe tener completos sus valores por defecto.
+func (c ConsultaDisponibilidad) Validar() error {
+	switch {
+	case c.Desde.IsZero() || c.Hasta.IsZero():
+		return NewError(ErrValidation, "La búsqueda debe indicar desde y hasta")
+	case c.Hasta.Before(c.Desde):
+		return NewError(ErrValidation, "Rango inválido: %s es posterior a %s", c.Desde, c.Hasta)
+	case c.Hasta.Time().Sub(c.Desde.Time()) > MaxDiasDisponibilidad*24*time.Hour:
+		return NewError(ErrValidation, "El rango de búsqueda no puede superar los %d días", MaxDiasDisponibilidad)
+	case c.DuracionMinutos < 1 || c.DuracionMinutos > DuracionTurnoMaxima:
+		return NewError(ErrValidation, "La duración del turno debe estar entre 1 y %d minutos", DuracionTurnoMaxima)
+	case c.PasoMinutos < 1 || c.PasoMinutos > MaxPasoMinutos:
+		return NewError(ErrValidation, "El paso debe estar entre 1 y %d minutos", MaxPasoMinutos)
+	case c.Limite < 1 || c.Limite > MaxTurnosLibres:
+		return NewError(ErrValidation, "El límite debe estar entre 1 y %d", MaxTurnosLibres)
+	}
+	return nil
+}
+
+// Libres devuelve, en orden, hasta c.Limite turnos libres del odontólogo
+// que comienzan entre c.Desde y c.Hasta. Cada uno dura c.DuracionMinutos,
+// cabe entero en una franja de su horario, no cae en una excepción ni en un
+// feriado y no se superpone con ninguno de ocupados que siga reservando su
+// horario. Los comienzos se alinean cada c.PasoMinutos desde el inicio de
+// la franja; sin horarios la franja es el día completo.
+func (a Agenda) Libres(c ConsultaDisponibilidad, feriados []string, ocupados []Turno) []TurnoLibre {
+	sinAtencion := make(map[string]bool, len(feriados))
+	for _, f := range feriados {
+		sinAtencion[f] = true
+	}
+	ocupados = append([]Turno(nil), ocupados...)
+	sort.Slice(ocupados, func(i, j int) bool { return ocupados[i].FechaTurno.Before(ocupados[j].FechaTurno) })
+
+	desde, hasta := c.Desde.Time(), c.Hasta.Time()
+	duracion, paso := time.Duration(c.DuracionMinutos)*time.Minute, time.Duration(c.PasoMinutos)*time.Minute
+	var libres []TurnoLibre
+	inicio := time.Date(desde.Year(), desde.Month(), desde.Day(), 0, 0, 0, 0, time.UTC)
+	for dia := inicio; !dia.After(hasta); dia = dia.AddDate(0, 0, 1) {
+		if sinAtencion[dia.Format("2006-01-02")] {
+			continue
+		}
+		franjas := [][2]time.Duration{{0, 24 * time.Hour}}
+		if len(a.Horarios) > 0 {
+			franjas = a.franjas(dia.Weekday())
+		}
+		for _, franja := range franjas {
+			comienzo := dia.Add(franja[0])
+			if comienzo.Before(desde) {
+				// Primer comienzo de la grilla que no es anterior a desde.
+				comienzo = comienzo.Add((desde.Sub(comienzo) + paso - 1) / paso * paso)
+			}
+			for ; !comienzo.After(hasta) && !comienzo.Add(duracion).After(dia.Add(franja[1])); comienzo = comienzo.Add(paso) {
+				t := Turno{IdOdontologo: a.IdOdontologo, FechaTurno: Fecha(comienzo), DuracionMinutos: c.DuracionMinutos}
+				if a.Admite(t) != nil || ocupado(t, ocupados) {
+					continue
+				}
+				libres = append(libres, TurnoLibre{IdOdontologo: a.IdOdontologo, Desde: t.FechaTurno, Hasta: t.Fin()})
+				if len(libres) == c.Limite {
+					return libres
+				}
+			}
+		}
+	}
+	return libres
+}
+
+// ocupado indica si t se superpone con alguno de los turnos, que deben
+// estar ordenados por fecha.
+func ocupado(t Turno, turnos []Turno) bool {
+	fin := t.Fin()
+	for _, u := range turnos {
+		if !u.FechaTurno.Before(fin) {
+			return false
+		}
+		if u.Ocupa() && t.SeSuperpone(u) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConsultaDisponibilidadValidar(t *testing.T) {
+	valida := func() ConsultaDisponibilidad {
+		return ConsultaDisponibilidad{
+			Desde:           fecha(t, "2030-01-07 09:00"),
+			Hasta:           fecha(t, "2030-01-08 09:00"),
+			DuracionMinutos: 30,
+			PasoMinutos:     15,
+			Limite:          10,
+		}
+	}
+	casos := []struct {
+		nombre  string
+		cambiar func(c *ConsultaDisponibilidad)
+		valida  bool
+	}{
+		{"válida", func(c *ConsultaDisponibilidad) {}, true},
+		{"rango de un instante", func(c *ConsultaDisponibilidad) { c.Hasta = c.Desde }, true},
+		{"sin desde", func(c *ConsultaDisponibilidad) { c.Desde = Fecha{} }, false},
+		{"hasta anterior a desde", func(c *ConsultaDisponibilidad) { c.Hasta = fecha(t, "2030-01-06") }, false},
+		{"rango mayor al máximo", func(c *ConsultaDisponibilidad) { c.Hasta = fecha(t, "2030-02-08 09:01") }, false},
+		{"duración cero", func(c *ConsultaDisponibilidad) { c.DuracionMinutos = 0 }, false},
+		{"duración mayor a la máxima", func(c *ConsultaDisponibilidad) { c.DuracionMinutos = DuracionTurnoMaxima + 1 }, false},
+		{"paso cero", func(c *ConsultaDisponibilidad) { c.PasoMinutos = 0 }, false},
+		{"límite mayor al máximo", func(c *ConsultaDisponibilidad) { c.Limite = MaxTurnosLibres + 1 }, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			consulta := valida()
+			c.cambiar(&consulta)
+			err := consulta.Validar()
+			if c.valida && err != nil {
+				t.Fatalf("se esperaba válida: %v", err)
+			}
+			if !c.valida && !errors.Is(err, ErrValidation) {
+				t.Fatalf("se esperaba ErrValidation y se obtuvo %v", err)
+			}
+		})
+	}
+}
+
+func TestAgendaLibres(t *testing.T) {
+	// Lunes de 9 a 11; de vacaciones el lunes 14.
+	agenda := Agenda{
+		IdOdontologo: 1,
+		Horarios:     []Horario{{Dia: 1, Desde: "09:00", Hasta: "11:00"}},
+		Excepciones:  []Excepcion{{Desde: "2030-01-14", Hasta: "2030-01-14", Motivo: "Vacaciones"}},
+	}
+	ocupados := []Turno{
+		{FechaTurno: fecha(t, "2030-01-07 09:30"), DuracionMinutos: 30},
+		{FechaTurno: fecha(t, "2030-01-07 10:00"), DuracionMinutos: 30, Estado: EstadoCancelado},
+	}
+	casos := []struct {
+		nombre    string
+		agenda    Agenda
+		desde     string
+		hasta     string
+		duracion  int
+		paso      int
+		limite    int
+		feriados  []string
+		esperados []string
+	}{
+		{"saltea turnos ocupados y excepciones", agenda, "2030-01-07", "2030-01-14 23:59", 30, 30, 100, nil,
+			[]string{"2030-01-07 09:00", "2030-01-07 10:00", "2030-01-07 10:30"}},
+		{"el turno debe caber entero en la franja", agenda, "2030-01-07", "2030-01-07 23:59", 60, 30, 100, nil,
+			[]string{"2030-01-07 10:00"}},
+		{"los comienzos se alinean a la franja", agenda, "2030-01-07 10:10", "2030-01-07 23:59", 15, 15, 100, nil,
+			[]string{"2030-01-07 10:15", "2030-01-07 10:30", "2030-01-07 10:45"}},
+		{"corta en el límite", agenda, "2030-01-07", "2030-01-14", 30, 30, 2, nil,
+			[]string{"2030-01-07 09:00", "2030-01-07 10:00"}},
+		{"los feriados no tienen turnos", agenda, "2030-01-07", "2030-01-07 23:59", 30, 30, 100, []string{"2030-01-07"},
+			nil},
+		{"fuera del horario no hay turnos", agenda, "2030-01-08", "2030-01-12 23:59", 30, 30, 100, nil,
+			nil},
+		{"sin horarios atiende todo el día", Agenda{IdOdontologo: 1}, "2030-01-08 23:00", "2030-01-09 00:00", 30, 30, 100, nil,
+			[]string{"2030-01-08 23:00", "2030-01-08 23:30", "2030-01-09 00:00"}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			consulta := ConsultaDisponibilidad{Desde: fecha(t, c.desde), Hasta: fecha(t, c.hasta), DuracionMinutos: c.duracion, PasoMinutos: c.paso, Limite: c.limite}
+			libres := c.agenda.Libres(consulta, c.feriados, ocupados)
+			if len(libres) != len(c.esperados) {
+				t.Fatalf("se obtuvieron %d turnos libres %v, se esperaban %v", len(libres), libres, c.esperados)
+			}
+			for i, l := range libres {
+				esperado := fecha(t, c.esperados[i])
+				if !l.Desde.Time().Equal(esperado.Time()) || l.Hasta.Time().Sub(l.Desde.Time()).Minutes() != float64(c.duracion) {
+					t.Errorf("turno libre %d: %s a %s, se esperaba desde %s por %d minutos", i, l.Desde, l.Hasta, esperado, c.duracion)
+				}
+				if l.IdOdontologo != 1 {
+					t.Errorf("turno libre %d: odontólogo %d", i, l.IdOdontologo)
+				}
+			}
+		})
+	}
+}
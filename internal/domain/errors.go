@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errores tipados que atraviesan las capas store → repository → service y
+// que web.Failure traduce al código HTTP correspondiente.
+var (
+	ErrNotFound   = errors.New("no encontrado")
+	ErrDuplicate  = errors.New("registro duplicado")
+	ErrForeignKey = errors.New("referencia inválida")
+	ErrValidation = errors.New("datos inválidos")
+	// ErrPrecondition indica que el registro cambió desde la versión que el
+	// cliente leyó (If-Match desactualizado).
+	ErrPrecondition = errors.New("versión desactualizada")
+	// ErrConflict indica que la operación choca con otro registro, por
+	// ejemplo un turno que se superpone con otro.
+	ErrConflict = errors.New("conflicto")
+)
+
+// Error asocia un mensaje legible a uno de los errores tipados. errors.Is
+// sobre un *Error compara contra Kind.
+// Detalle, si no es nil, acompaña al mensaje en la respuesta; por ejemplo,
+// el registro con el que hubo un conflicto.
+type Error struct {
+	Kind    error
+	Message string
+	Detalle interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// NewError crea un *Error de tipo kind con el mensaje formateado.
+func NewError(kind error, format string, args ...interface{}) error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
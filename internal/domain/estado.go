@@ -0,0 +1,57 @@
+package domain
+
+// Estados del ciclo de vida de un turno.
+const (
+	EstadoSolicitado = "solicitado"
+	EstadoConfirmado = "confirmado"
+	EstadoEnSala     = "en_sala"
+	EstadoAtendido   = "atendido"
+	EstadoCancelado  = "cancelado"
+	EstadoAusente    = "ausente"
+)
+
+// MaxMotivoCancelacion es el largo máximo del motivo de una cancelación.
+const MaxMotivoCancelacion = 150
+
+// EstadosSiguientes indica a qué estados puede pasar un turno desde cada
+// estado. Atendido, cancelado y ausente son finales.
+var EstadosSiguientes = map[string][]string{
+	EstadoSolicitado: {EstadoConfirmado, EstadoEnSala, EstadoCancelado, EstadoAusente},
+	EstadoConfirmado: {EstadoEnSala, EstadoCancelado, EstadoAusente},
+	EstadoEnSala:     {EstadoAtendido},
+}
+
+// TransicionTurno es un cambio de estado del historial de un turno. El alta
+// es la primera transición y no tiene estado Desde.
+type TransicionTurno struct {
+	Fecha  string `json:"fecha"`
+	Actor  string `json:"actor"`
+	Desde  string `json:"desde,omitempty"`
+	Hasta  string `json:"hasta"`
+	Motivo string `json:"motivo,omitempty"`
+}
+
+// EstadoActual devuelve el estado del turno. Los turnos guardados antes de
+// que existieran los estados están solicitados.
+func (t Turno) EstadoActual() string {
+	if t.Estado == "" {
+		return EstadoSolicitado
+	}
+	return t.Estado
+}
+
+// PuedePasarA indica si el turno puede pasar de su estado actual a estado.
+func (t Turno) PuedePasarA(estado string) bool {
+	for _, siguiente := range EstadosSiguientes[t.EstadoActual()] {
+		if siguiente == estado {
+			return true
+		}
+	}
+	return false
+}
+
+// Finalizado indica si el turno llegó a un estado final y ya no puede
+// cambiar.
+func (t Turno) Finalizado() bool {
+	return len(EstadosSiguientes[t.EstadoActual()]) == 0
+}
@@ -0,0 +1,59 @@
+package domain
+
+import "testing"
+
+func TestTurnoPuedePasarA(t *testing.T) {
+	estados := []string{EstadoSolicitado, EstadoConfirmado, EstadoEnSala, EstadoAtendido, EstadoCancelado, EstadoAusente}
+	permitidas := map[[2]string]bool{
+		{EstadoSolicitado, EstadoConfirmado}: true,
+		{EstadoSolicitado, EstadoEnSala}:     true,
+		{EstadoSolicitado, EstadoCancelado}:  true,
+		{EstadoSolicitado, EstadoAusente}:    true,
+		{EstadoConfirmado, EstadoEnSala}:     true,
+		{EstadoConfirmado, EstadoCancelado}:  true,
+		{EstadoConfirmado, EstadoAusente}:    true,
+		{EstadoEnSala, EstadoAtendido}:       true,
+	}
+	for _, desde := range estados {
+		for _, hasta := range estados {
+			esperado := permitidas[[2]string{desde, hasta}]
+			if got := (Turno{Estado: desde}).PuedePasarA(hasta); got != esperado {
+				t.Errorf("%s → %s: PuedePasarA = %v, se esperaba %v", desde, hasta, got, esperado)
+			}
+		}
+	}
+}
+
+func TestTurnoFinalizado(t *testing.T) {
+	casos := []struct {
+		estado   string
+		esperado bool
+	}{
+		{"", false},
+		{EstadoSolicitado, false},
+		{EstadoConfirmado, false},
+		{EstadoEnSala, false},
+		{EstadoAtendido, true},
+		{EstadoCancelado, true},
+		{EstadoAusente, true},
+	}
+	for _, c := range casos {
+		turno := Turno{Estado: c.estado}
+		if got := turno.Finalizado(); got != c.esperado {
+			t.Errorf("%q: Finalizado = %v, se esperaba %v", c.estado, got, c.esperado)
+		}
+		if c.esperado && len(EstadosSiguientes[turno.EstadoActual()]) != 0 {
+			t.Errorf("%q es final pero tiene estados siguientes", c.estado)
+		}
+	}
+}
+
+func TestTurnoSinEstadoEstaSolicitado(t *testing.T) {
+	turno := Turno{}
+	if got := turno.EstadoActual(); got != EstadoSolicitado {
+		t.Fatalf("EstadoActual = %q, se esperaba %q", got, EstadoSolicitado)
+	}
+	if !turno.PuedePasarA(EstadoConfirmado) {
+		t.Fatal("un turno sin estado debería poder confirmarse")
+	}
+}
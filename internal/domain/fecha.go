@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatosFecha son los formatos que acepta Fecha, en orden de prueba. Los
+// que no indican zona horaria se interpretan en UTC.
+var FormatosFecha = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"}
+
+// formatosGuardados son los formatos adicionales con los que una fecha puede
+// venir de la base de datos.
+var formatosGuardados = []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// Fecha es un instante que en JSON se lee en cualquiera de FormatosFecha y se
+// escribe en RFC 3339 y UTC. Implementa sql.Scanner y driver.Valuer para
+// guardarse en columnas DATETIME.
+type Fecha time.Time
+
+// ParseFecha lee s en alguno de FormatosFecha. Si no puede, devuelve un
+// error de tipo ErrValidation.
+func ParseFecha(s string) (Fecha, error) {
+	if f, ok := parseFecha(strings.TrimSpace(s), FormatosFecha); ok {
+		return f, nil
+	}
+	return Fecha{}, NewError(ErrValidation, "Fecha inválida %q: se espera RFC 3339 (2006-01-02T15:04:05Z), \"2006-01-02 15:04\" o \"2006-01-02\"", s)
+}
+
+// SoloDia indica si s es una fecha sin hora, para que los filtros "hasta"
+// puedan incluir el día completo.
+func SoloDia(s string) bool {
+	_, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	return err == nil
+}
+
+func parseFecha(s string, formatos []string) (Fecha, bool) {
+	for _, formato := range formatos {
+		if t, err := time.ParseInLocation(formato, s, time.UTC); err == nil {
+			return Fecha(t.UTC()), true
+		}
+	}
+	return Fecha{}, false
+}
+
+func (f Fecha) Time() time.Time {
+	return time.Time(f)
+}
+
+func (f Fecha) IsZero() bool {
+	return time.Time(f).IsZero()
+}
+
+func (f Fecha) Before(g Fecha) bool {
+	return time.Time(f).Before(time.Time(g))
+}
+
+func (f Fecha) After(g Fecha) bool {
+	return time.Time(f).After(time.Time(g))
+}
+
+// String devuelve la fecha en RFC 3339, o "" si es la fecha cero.
+func (f Fecha) String() string {
+	if f.IsZero() {
+		return ""
+	}
+	return time.Time(f).UTC().Format(time.RFC3339)
+}
+
+func (f Fecha) MarshalJSON() ([]byte, error) {
+	if f.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.String())
+}
+
+func (f *Fecha) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = Fecha{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return NewError(ErrValidation, "Fecha inválida %s: debe ser un texto", data)
+	}
+	if strings.TrimSpace(s) == "" {
+		*f = Fecha{}
+		return nil
+	}
+	parsed, err := ParseFecha(s)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+func (f Fecha) Value() (driver.Value, error) {
+	return time.Time(f).UTC(), nil
+}
+
+func (f *Fecha) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*f = Fecha(v.UTC())
+		return nil
+	case []byte:
+		src = string(v)
+	}
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("no se puede leer una fecha de %T", src)
+	}
+	parsed, ok := parseFecha(s, append(FormatosFecha, formatosGuardados...))
+	if !ok {
+		return fmt.Errorf("fecha guardada inválida: %q", s)
+	}
+	*f = parsed
+	return nil
+}
@@ -0,0 +1,80 @@
+package domain
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ListOptions indica el orden y la página a devolver en un listado.
+// Sort es el nombre JSON del campo por el que se ordena. Con Deleted el
+// listado devuelve sólo los registros dados de baja (la papelera).
+type ListOptions struct {
+	Page    int
+	Limit   int
+	Sort    string
+	Desc    bool
+	Deleted bool
+}
+
+// Normalize completa los valores por defecto y acota el tamaño de página.
+func (o *ListOptions) Normalize() {
+	if o.Page < 1 {
+		o.Page = 1
+	}
+	if o.Limit < 1 {
+		o.Limit = DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		o.Limit = MaxLimit
+	}
+}
+
+// Offset devuelve la cantidad de registros a saltear para la página pedida.
+func (o ListOptions) Offset() int {
+	return (o.Page - 1) * o.Limit
+}
+
+// ListarTodo recorre todas las páginas de list con el orden de opts, de a
+// MaxLimit registros. Para no saltear ni repetir registros, list debe leer
+// de una vista que no cambie entre páginas.
+func ListarTodo[T any](opts ListOptions, list func(opts ListOptions) ([]T, int, error)) ([]T, error) {
+	var items []T
+	opts.Limit = MaxLimit
+	for opts.Page = 1; ; opts.Page++ {
+		page, total, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+		if len(page) == 0 || len(items) >= total {
+			return items, nil
+		}
+	}
+}
+
+// OdontologoFilter filtra el listado de odontólogos. Apellido busca por
+// coincidencia parcial; Matricula debe coincidir exactamente.
+type OdontologoFilter struct {
+	ListOptions
+	Apellido  string
+	Matricula string
+}
+
+// PacienteFilter filtra el listado de pacientes. Apellido busca por
+// coincidencia parcial; Dni debe coincidir exactamente.
+type PacienteFilter struct {
+	ListOptions
+	Apellido string
+	Dni      string
+}
+
+// TurnoFilter filtra el listado de turnos por odontólogo, paciente, serie y
+// un rango de fechas inclusivo. Los campos en cero no filtran.
+type TurnoFilter struct {
+	ListOptions
+	IdOdontologo int
+	IdPaciente   int
+	IdSerie      int
+	Desde        Fecha
+	Hasta        Fecha
+}
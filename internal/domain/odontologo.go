@@ -5,4 +5,9 @@ type Odontologo struct {
 	NombreOdontologo    string `json:"nombreOdontologo" binding:"required"`
 	ApellidoOdontologo  string `json:"apellidoOdontologo" binding:"required"`
 	MatriculaOdontologo string `json:"matriculaOdontologo" binding:"required"`
+	// Version aumenta con cada modificación y se expone como ETag.
+	Version int `json:"version"`
+	// DeletedAt es el momento de la baja lógica (RFC 3339); vacío mientras
+	// el registro está activo.
+	DeletedAt string `json:"deletedAt,omitempty"`
 }
@@ -6,5 +6,7 @@ type Paciente struct {
 	ApellidoPaciente    string `json:"apellidoPaciente" binding:"required"`
 	DomicilioPaciente   string `json:"domicilioPaciente" binding:"required"`
 	DniPaciente         string `json:"dniPaciente" binding:"required"`
-	FechaDeAltaPaciente string `json:"fechaDeAltaPaciente" binding:"required"`
+	FechaDeAltaPaciente Fecha  `json:"fechaDeAltaPaciente" binding:"required" swaggertype:"string" format:"date-time"`
+	Version             int    `json:"version"`
+	DeletedAt           string `json:"deletedAt,omitempty"`
 }
@@ -0,0 +1,31 @@
+package domain
+
+import "encoding/json"
+
+// Respaldo es el contenido completo de un store, incluidos los registros
+// dados de baja y la auditoría. Es lo que se guarda en un backup y se carga
+// al restaurarlo.
+type Respaldo struct {
+	Odontologos []Odontologo `json:"odontologos"`
+	Pacientes   []Paciente   `json:"pacientes"`
+	Turnos      []Turno      `json:"turnos"`
+	Auditoria   []Auditoria  `json:"auditoria"`
+	// Agendas tiene sólo las agendas con horarios o excepciones.
+	Agendas []Agenda `json:"agendas,omitempty"`
+	Series  []Serie  `json:"series,omitempty"`
+}
+
+// UnmarshalJSON lee los turnos como TurnosGuardados para aceptar backups
+// con los IDs en formato anterior.
+func (r *Respaldo) UnmarshalJSON(data []byte) error {
+	type plano Respaldo
+	aux := struct {
+		*plano
+		Turnos TurnosGuardados `json:"turnos"`
+	}{plano: (*plano)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Turnos = aux.Turnos
+	return nil
+}
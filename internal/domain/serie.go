@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Frecuencias con las que se repiten los turnos de una serie.
+const (
+	FrecuenciaDiaria  = "diaria"
+	FrecuenciaSemanal = "semanal"
+	FrecuenciaMensual = "mensual"
+)
+
+// MaxTurnosSerie es la cantidad máxima de turnos que puede generar una serie.
+const MaxTurnosSerie = 100
+
+// Alcances de un cambio o una cancelación sobre los turnos de una serie: sólo
+// el turno indicado, ése y los posteriores, o todos los de la serie.
+const (
+	AlcanceOcurrencia = "ocurrencia"
+	AlcanceSiguientes = "siguientes"
+	AlcanceSerie      = "serie"
+)
+
+// Serie es una regla de repetición de turnos del mismo paciente con el mismo
+// odontólogo: el primero en FechaInicio y los demás cada Intervalo días,
+// semanas o meses según Frecuencia, Cantidad veces o hasta el día Hasta
+// inclusive. Al crearla se indica uno de los dos límites; la serie guardada
+// tiene ambos, con la cantidad de turnos generados y el día del último.
+//
+// Con frecuencia mensual se omiten los meses que no tienen el día de
+// FechaInicio, por ejemplo el 31 en abril.
+type Serie struct {
+	IdSerie          int    `json:"idSerie"`
+	DescripcionTurno string `json:"descripcionTurno"`
+	IdOdontologo     int    `json:"idOdontologo"`
+	IdPaciente       int    `json:"idPaciente"`
+	FechaInicio      Fecha  `json:"fechaInicio" swaggertype:"string" format:"date-time"`
+	DuracionMinutos  int    `json:"duracionMinutos"`
+	Frecuencia       string `json:"frecuencia"`
+	Intervalo        int    `json:"intervalo"`
+	Cantidad         int    `json:"cantidad,omitempty"`
+	// Hasta es un día "2006-01-02".
+	Hasta string `json:"hasta,omitempty"`
+}
+
+// OcurrenciaRechazada es un turno de una serie que no se pudo reservar, con
+// el motivo.
+type OcurrenciaRechazada struct {
+	FechaTurno Fecha  `json:"fechaTurno" swaggertype:"string" format:"date-time"`
+	Motivo     string `json:"motivo"`
+}
+
+// Validar verifica los datos y la regla de repetición de una serie nueva,
+// que debe indicar exactamente uno de Cantidad y Hasta.
+func (s Serie) Validar() error {
+	switch {
+	case strings.TrimSpace(s.DescripcionTurno) == "":
+		return NewError(ErrValidation, "La serie debe indicar la descripción de sus turnos")
+	case s.FechaInicio.IsZero():
+		return NewError(ErrValidation, "La serie debe indicar la fecha de su primer turno")
+	case s.DuracionMinutos < 0 || s.DuracionMinutos > DuracionTurnoMaxima:
+		return NewError(ErrValidation, "La duración del turno debe estar entre 1 y %d minutos", DuracionTurnoMaxima)
+	case s.Frecuencia != FrecuenciaDiaria && s.Frecuencia != FrecuenciaSemanal && s.Frecuencia != FrecuenciaMensual:
+		return NewError(ErrValidation, "Frecuencia inválida %q: debe ser %s, %s o %s", s.Frecuencia, FrecuenciaDiaria, FrecuenciaSemanal, FrecuenciaMensual)
+	case s.Intervalo < 1:
+		return NewError(ErrValidation, "El intervalo de la serie debe ser al menos 1")
+	case (s.Cantidad == 0) == (s.Hasta == ""):
+		return NewError(ErrValidation, "La serie debe indicar la cantidad de turnos o la fecha hasta la que se repite, pero no ambas")
+	case s.Cantidad < 0 || s.Cantidad > MaxTurnosSerie:
+		return NewError(ErrValidation, "La cantidad de turnos de la serie debe estar entre 1 y %d", MaxTurnosSerie)
+	}
+	if s.Hasta != "" {
+		hasta, err := time.Parse("2006-01-02", s.Hasta)
+		if err != nil {
+			return NewError(ErrValidation, "Fecha hasta inválida %q: se espera 2006-01-02", s.Hasta)
+		}
+		if hasta.Before(medianoche(s.FechaInicio.Time())) {
+			return NewError(ErrValidation, "La serie termina el %s, antes de su primer turno", s.Hasta)
+		}
+	}
+	return nil
+}
+
+// Ocurrencias devuelve en orden las fechas de los turnos de la serie, que
+// debe ser válida. Si la regla genera más de MaxTurnosSerie devuelve un
+// error ErrValidation.
+func (s Serie) Ocurrencias() ([]Fecha, error) {
+	inicio := s.FechaInicio.Time()
+	var hasta time.Time
+	if s.Hasta != "" {
+		h, err := time.Parse("2006-01-02", s.Hasta)
+		if err != nil {
+			return nil, NewError(ErrValidation, "Fecha hasta inválida %q: se espera 2006-01-02", s.Hasta)
+		}
+		hasta = h
+	}
+	var fechas []Fecha
+	// Un 29 de febrero mensual puede tardar hasta 48 pasos en repetirse.
+	for i := 0; i < MaxTurnosSerie*48; i++ {
+		var f time.Time
+		switch s.Frecuencia {
+		case FrecuenciaDiaria:
+			f = inicio.AddDate(0, 0, i*s.Intervalo)
+		case FrecuenciaSemanal:
+			f = inicio.AddDate(0, 0, 7*i*s.Intervalo)
+		default:
+			if f = inicio.AddDate(0, i*s.Intervalo, 0); f.Day() != inicio.Day() {
+				continue
+			}
+		}
+		if s.Hasta != "" && medianoche(f).After(hasta) {
+			break
+		}
+		if len(fechas) == MaxTurnosSerie {
+			return nil, NewError(ErrValidation, "La serie no puede tener más de %d turnos", MaxTurnosSerie)
+		}
+		fechas = append(fechas, Fecha(f))
+		if len(fechas) == s.Cantidad {
+			break
+		}
+	}
+	return fechas, nil
+}
+
+// ValidarAlcance verifica que alcance sea uno de los alcances de serie.
+func ValidarAlcance(alcance string) error {
+	switch alcance {
+	case AlcanceOcurrencia, AlcanceSiguientes, AlcanceSerie:
+		return nil
+	}
+	return NewError(ErrValidation, "Alcance inválido %q: debe ser %s, %s o %s", alcance, AlcanceOcurrencia, AlcanceSiguientes, AlcanceSerie)
+}
+
+// medianoche devuelve la medianoche UTC del día de t.
+func medianoche(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
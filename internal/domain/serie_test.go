@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+// serieValida devuelve una serie semanal de cuatro turnos que se modifica en
+// cada caso.
+func serieValida(t *testing.T) Serie {
+	return Serie{
+		DescripcionTurno: "Ortodoncia",
+		IdOdontologo:     1,
+		IdPaciente:       1,
+		FechaInicio:      fecha(t, "2030-01-07 10:00"),
+		Frecuencia:       FrecuenciaSemanal,
+		Intervalo:        1,
+		Cantidad:         4,
+	}
+}
+
+func TestSerieValidar(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		cambiar func(s *Serie)
+		valida  bool
+	}{
+		{"válida con cantidad", func(s *Serie) {}, true},
+		{"válida con hasta", func(s *Serie) { s.Cantidad, s.Hasta = 0, "2030-03-01" }, true},
+		{"hasta el mismo día del primer turno", func(s *Serie) { s.Cantidad, s.Hasta = 0, "2030-01-07" }, true},
+		{"sin descripción", func(s *Serie) { s.DescripcionTurno = " " }, false},
+		{"sin fecha de inicio", func(s *Serie) { s.FechaInicio = Fecha{} }, false},
+		{"duración negativa", func(s *Serie) { s.DuracionMinutos = -1 }, false},
+		{"frecuencia desconocida", func(s *Serie) { s.Frecuencia = "anual" }, false},
+		{"intervalo cero", func(s *Serie) { s.Intervalo = 0 }, false},
+		{"cantidad y hasta", func(s *Serie) { s.Hasta = "2030-03-01" }, false},
+		{"ni cantidad ni hasta", func(s *Serie) { s.Cantidad = 0 }, false},
+		{"cantidad mayor al máximo", func(s *Serie) { s.Cantidad = MaxTurnosSerie + 1 }, false},
+		{"hasta mal formada", func(s *Serie) { s.Cantidad, s.Hasta = 0, "01/03/2030" }, false},
+		{"hasta anterior al primer turno", func(s *Serie) { s.Cantidad, s.Hasta = 0, "2030-01-06" }, false},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			s := serieValida(t)
+			c.cambiar(&s)
+			err := s.Validar()
+			if c.valida && err != nil {
+				t.Fatalf("se esperaba válida: %v", err)
+			}
+			if !c.valida && !errors.Is(err, ErrValidation) {
+				t.Fatalf("se esperaba ErrValidation y se obtuvo %v", err)
+			}
+		})
+	}
+}
+
+func TestSerieOcurrencias(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		inicio     string
+		frecuencia string
+		intervalo  int
+		cantidad   int
+		hasta      string
+		esperadas  []string
+	}{
+		{"diaria cada dos días", "2030-01-07 10:00", FrecuenciaDiaria, 2, 3, "", []string{"2030-01-07 10:00", "2030-01-09 10:00", "2030-01-11 10:00"}},
+		{"semanal hasta inclusive", "2030-01-07 10:00", FrecuenciaSemanal, 1, 0, "2030-01-21", []string{"2030-01-07 10:00", "2030-01-14 10:00", "2030-01-21 10:00"}},
+		{"semanal hasta antes de la hora del turno", "2030-01-07 18:00", FrecuenciaSemanal, 2, 0, "2030-01-21", []string{"2030-01-07 18:00", "2030-01-21 18:00"}},
+		{"mensual omite los meses sin el día", "2030-01-31 09:00", FrecuenciaMensual, 1, 3, "", []string{"2030-01-31 09:00", "2030-03-31 09:00", "2030-05-31 09:00"}},
+		{"anual desde un 29 de febrero", "2032-02-29 09:00", FrecuenciaMensual, 12, 2, "", []string{"2032-02-29 09:00", "2036-02-29 09:00"}},
+		{"hasta sin meses con el día", "2030-01-31 09:00", FrecuenciaMensual, 1, 0, "2030-03-30", []string{"2030-01-31 09:00"}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			s := Serie{FechaInicio: fecha(t, c.inicio), Frecuencia: c.frecuencia, Intervalo: c.intervalo, Cantidad: c.cantidad, Hasta: c.hasta}
+			fechas, err := s.Ocurrencias()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fechas) != len(c.esperadas) {
+				t.Fatalf("se obtuvieron %d turnos %v, se esperaban %v", len(fechas), fechas, c.esperadas)
+			}
+			for i, f := range fechas {
+				if esperada := fecha(t, c.esperadas[i]); !f.Time().Equal(esperada.Time()) {
+					t.Errorf("turno %d: %s, se esperaba %s", i, f, esperada)
+				}
+			}
+		})
+	}
+}
+
+func TestSerieOcurrenciasRechazaMasDelMaximo(t *testing.T) {
+	s := Serie{FechaInicio: fecha(t, "2030-01-01 10:00"), Frecuencia: FrecuenciaDiaria, Intervalo: 1, Hasta: "2030-04-11"}
+	if _, err := s.Ocurrencias(); !errors.Is(err, ErrValidation) {
+		t.Fatalf("101 turnos: se esperaba ErrValidation y se obtuvo %v", err)
+	}
+	s.Hasta = "2030-04-10"
+	fechas, err := s.Ocurrencias()
+	if err != nil || len(fechas) != MaxTurnosSerie {
+		t.Fatalf("100 turnos: se obtuvieron %d (%v)", len(fechas), err)
+	}
+}
+
+func TestValidarAlcance(t *testing.T) {
+	for _, alcance := range []string{AlcanceOcurrencia, AlcanceSiguientes, AlcanceSerie} {
+		if err := ValidarAlcance(alcance); err != nil {
+			t.Errorf("%q: %v", alcance, err)
+		}
+	}
+	for _, alcance := range []string{"", "todos", "Serie"} {
+		if err := ValidarAlcance(alcance); !errors.Is(err, ErrValidation) {
+			t.Errorf("%q: se esperaba ErrValidation y se obtuvo %v", alcance, err)
+		}
+	}
+}
@@ -1,9 +1,103 @@
 package domain
 
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Duraciones admitidas de un turno, en minutos.
+const (
+	DuracionTurnoPorDefecto = 30
+	DuracionTurnoMaxima     = 8 * 60
+)
+
 type Turno struct {
 	IdTurno          int    `json:"idTurno"`
 	DescripcionTurno string `json:"descripcionTurno" binding:"required"`
-	FechaTurno       string `json:"fechaTurno" binding:"required"`
-	IdOdontologo     string `json:"idOdontologo" binding:"required"`
-	IdPaciente       string `json:"idPaciente" binding:"required"`
+	FechaTurno       Fecha  `json:"fechaTurno" binding:"required" swaggertype:"string" format:"date-time"`
+	// DuracionMinutos es la duración del turno. Al crearlo, 0 equivale a
+	// DuracionTurnoPorDefecto.
+	DuracionMinutos int `json:"duracionMinutos"`
+	IdOdontologo    int `json:"idOdontologo" binding:"required"`
+	IdPaciente      int `json:"idPaciente" binding:"required"`
+	// IdSerie es la serie que generó el turno, o 0 si se creó suelto. No
+	// cambia al modificar el turno.
+	IdSerie int `json:"idSerie,omitempty"`
+	// Estado sólo cambia con las transiciones de EstadosSiguientes; los
+	// turnos nuevos empiezan en EstadoSolicitado.
+	Estado            string `json:"estado"`
+	MotivoCancelacion string `json:"motivoCancelacion,omitempty"`
+	Version           int    `json:"version"`
+	DeletedAt         string `json:"deletedAt,omitempty"`
+}
+
+// Duracion devuelve la duración del turno. Los turnos guardados antes de
+// que existiera DuracionMinutos duran DuracionTurnoPorDefecto minutos.
+func (t Turno) Duracion() time.Duration {
+	if t.DuracionMinutos <= 0 {
+		return DuracionTurnoPorDefecto * time.Minute
+	}
+	return time.Duration(t.DuracionMinutos) * time.Minute
+}
+
+// Fin devuelve el instante en que termina el turno.
+func (t Turno) Fin() Fecha {
+	return Fecha(t.FechaTurno.Time().Add(t.Duracion()))
+}
+
+// SeSuperpone indica si t y u ocupan algún instante en común. Un turno que
+// empieza cuando el otro termina no se superpone con él.
+func (t Turno) SeSuperpone(u Turno) bool {
+	return t.FechaTurno.Before(u.Fin()) && u.FechaTurno.Before(t.Fin())
+}
+
+// Ocupa indica si el turno reserva su horario. Los turnos cancelados lo
+// liberan para otros turnos.
+func (t Turno) Ocupa() bool {
+	return t.EstadoActual() != EstadoCancelado
+}
+
+// TurnosGuardados es una lista de turnos que al leerse de JSON acepta
+// IdOdontologo e IdPaciente como número o como texto. Los archivos y backups
+// anteriores a que esos campos fueran numéricos los guardaban como texto.
+type TurnosGuardados []Turno
+
+func (ts *TurnosGuardados) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	if items == nil {
+		*ts = nil
+		return nil
+	}
+	turnos := make([]Turno, len(items))
+	for i, item := range items {
+		type plano Turno
+		aux := struct {
+			*plano
+			IdOdontologo json.Number `json:"idOdontologo"`
+			IdPaciente   json.Number `json:"idPaciente"`
+		}{plano: (*plano)(&turnos[i])}
+		if err := json.Unmarshal(item, &aux); err != nil {
+			return err
+		}
+		var err error
+		if turnos[i].IdOdontologo, err = idGuardado(aux.IdOdontologo); err != nil {
+			return err
+		}
+		if turnos[i].IdPaciente, err = idGuardado(aux.IdPaciente); err != nil {
+			return err
+		}
+	}
+	*ts = turnos
+	return nil
+}
+
+func idGuardado(n json.Number) (int, error) {
+	if n == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(string(n))
 }
@@ -0,0 +1,43 @@
+package domain
+
+import "testing"
+
+// fecha interpreta s con ParseFecha y corta el test si no es válida.
+func fecha(t *testing.T, s string) Fecha {
+	t.Helper()
+	f, err := ParseFecha(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestTurnoSeSuperpone(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		fecha    string
+		duracion int
+		esperado bool
+	}{
+		{"misma hora", "2030-03-04 10:00", 30, true},
+		{"empieza durante", "2030-03-04 10:29", 30, true},
+		{"termina durante", "2030-03-04 09:31", 30, true},
+		{"lo contiene", "2030-03-04 09:00", 120, true},
+		{"sin duración dura la duración por defecto", "2030-03-04 09:31", 0, true},
+		{"empieza cuando termina", "2030-03-04 10:30", 30, false},
+		{"termina cuando empieza", "2030-03-04 09:30", 30, false},
+		{"otro día", "2030-03-05 10:00", 30, false},
+	}
+	base := Turno{FechaTurno: fecha(t, "2030-03-04 10:00"), DuracionMinutos: 30}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			otro := Turno{FechaTurno: fecha(t, c.fecha), DuracionMinutos: c.duracion}
+			if got := base.SeSuperpone(otro); got != c.esperado {
+				t.Errorf("SeSuperpone = %v, se esperaba %v", got, c.esperado)
+			}
+			if got := otro.SeSuperpone(base); got != c.esperado {
+				t.Errorf("SeSuperpone no es simétrico: %v", got)
+			}
+		})
+	}
+}
@@ -1,20 +1,47 @@
 package odontologo
 
 import (
-	"errors"
+	"context"
+	"fmt"
 
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 )
 
 type Repository interface {
-	GetByID(id int) (domain.Odontologo, error)
+	GetByID(ctx context.Context, id int) (domain.Odontologo, error)
 
-	Create(p domain.Odontologo) (domain.Odontologo, error)
+	// GetByMatricula busca al odontólogo activo con esa matrícula. Si no hay
+	// ninguno devuelve domain.ErrNotFound.
+	GetByMatricula(ctx context.Context, matricula string) (domain.Odontologo, error)
 
-	Update(id int, p domain.Odontologo) (domain.Odontologo, error)
+	Create(ctx context.Context, p domain.Odontologo) (domain.Odontologo, error)
 
-	Delete(id int) error
+	Update(ctx context.Context, id int, p domain.Odontologo) (domain.Odontologo, error)
+
+	Delete(ctx context.Context, id, version int) error
+
+	Restore(ctx context.Context, id int) (domain.Odontologo, error)
+
+	List(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error)
+
+	// GetAgenda devuelve la agenda del odontólogo id, vacía si no la
+	// configuró. No controla que el odontólogo exista.
+	GetAgenda(ctx context.Context, id int) (domain.Agenda, error)
+
+	UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) error
+
+	CreateExcepcion(ctx context.Context, e domain.Excepcion) (domain.Excepcion, error)
+
+	DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error
+
+	// WithinTx ejecuta fn en una transacción del store. Con tx se pueden
+	// construir repositorios de cualquier entidad que participen de ella.
+	WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error
+
+	// View ejecuta fn sobre una vista de sólo lectura del store, para
+	// consultas que leen varias entidades y no escriben.
+	View(ctx context.Context, fn func(v store.StoreInterface) error) error
 }
 
 type repository struct {
@@ -25,36 +52,87 @@ func NewRepository(storage store.StoreInterface) Repository {
 	return &repository{storage}
 }
 
-func (r *repository) Create(p domain.Odontologo) (domain.Odontologo, error) {
+func (r *repository) Create(ctx context.Context, p domain.Odontologo) (domain.Odontologo, error) {
 
-	err := r.storage.Create(p)
+	p, err := r.storage.Create(ctx, p)
 	if err != nil {
-		return domain.Odontologo{}, errors.New("Ha ocurrido un error al crear odontólogo")
+		return domain.Odontologo{}, fmt.Errorf("Ha ocurrido un error al crear odontólogo: %w", err)
 	}
 	return p, nil
 }
 
-func (r *repository) GetByID(id int) (domain.Odontologo, error) {
-	odontologo, err := r.storage.Read(id)
+func (r *repository) GetByID(ctx context.Context, id int) (domain.Odontologo, error) {
+	odontologo, err := r.storage.Read(ctx, id)
 	if err != nil {
-		return domain.Odontologo{}, errors.New("El odontólogo no existe")
+		return domain.Odontologo{}, err
 	}
 	return odontologo, nil
 }
 
-func (r *repository) Update(id int, p domain.Odontologo) (domain.Odontologo, error) {
+func (r *repository) GetByMatricula(ctx context.Context, matricula string) (domain.Odontologo, error) {
+	filter := domain.OdontologoFilter{Matricula: matricula, ListOptions: domain.ListOptions{Page: 1, Limit: 1}}
+	odontologos, _, err := r.storage.ListOdontologos(ctx, filter)
+	if err != nil {
+		return domain.Odontologo{}, err
+	}
+	if len(odontologos) == 0 {
+		return domain.Odontologo{}, domain.NewError(domain.ErrNotFound, "No existe un odontólogo con matrícula %s", matricula)
+	}
+	return odontologos[0], nil
+}
 
-	err := r.storage.Update(p)
+func (r *repository) Update(ctx context.Context, id int, p domain.Odontologo) (domain.Odontologo, error) {
+
+	p, err := r.storage.Update(ctx, p)
 	if err != nil {
-		return domain.Odontologo{}, errors.New("Ha ocurrido un error al actualizar odontólogo")
+		return domain.Odontologo{}, fmt.Errorf("Ha ocurrido un error al actualizar odontólogo: %w", err)
 	}
 	return p, nil
 }
 
-func (r *repository) Delete(id int) error {
-	err := r.storage.Delete(id)
+func (r *repository) Delete(ctx context.Context, id, version int) error {
+	err := r.storage.Delete(ctx, id, version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (r *repository) Restore(ctx context.Context, id int) (domain.Odontologo, error) {
+	return r.storage.Restore(ctx, id)
+}
+
+func (r *repository) List(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error) {
+	return r.storage.ListOdontologos(ctx, filter)
+}
+
+func (r *repository) GetAgenda(ctx context.Context, id int) (domain.Agenda, error) {
+	return r.storage.ReadAgenda(ctx, id)
+}
+
+func (r *repository) UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) error {
+	if err := r.storage.UpdateHorarios(ctx, id, horarios); err != nil {
+		return fmt.Errorf("Ha ocurrido un error al actualizar los horarios: %w", err)
+	}
+	return nil
+}
+
+func (r *repository) CreateExcepcion(ctx context.Context, e domain.Excepcion) (domain.Excepcion, error) {
+	e, err := r.storage.CreateExcepcion(ctx, e)
+	if err != nil {
+		return domain.Excepcion{}, fmt.Errorf("Ha ocurrido un error al crear la excepción: %w", err)
+	}
+	return e, nil
+}
+
+func (r *repository) DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error {
+	return r.storage.DeleteExcepcion(ctx, idOdontologo, idExcepcion)
+}
+
+func (r *repository) WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error {
+	return r.storage.WithinTx(ctx, fn)
+}
+
+func (r *repository) View(ctx context.Context, fn func(v store.StoreInterface) error) error {
+	return r.storage.View(ctx, fn)
+}
@@ -1,68 +1,461 @@
 package odontologo
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/auditoria"
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 )
 
 type Service interface {
-	GetByID(id int) (domain.Odontologo, error)
+	GetByID(ctx context.Context, id int) (domain.Odontologo, error)
+
+	Create(ctx context.Context, p domain.Odontologo) (domain.Odontologo, error)
+
+	// Delete da de baja al odontólogo. Si version no es 0 debe coincidir con la
+	// versión guardada; si no, devuelve domain.ErrPrecondition.
+	Delete(ctx context.Context, id, version int) error
+
+	// Restore restaura al odontólogo y a los turnos que se dieron de baja con
+	// él.
+	Restore(ctx context.Context, id int) (domain.Odontologo, error)
+
+	// Update aplica los campos no vacíos de p. Si p.Version no es 0 debe
+	// coincidir con la versión guardada; si no, devuelve
+	// domain.ErrPrecondition.
+	Update(ctx context.Context, id int, p domain.Odontologo) (domain.Odontologo, error)
+
+	List(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error)
+
+	// Export devuelve todos los odontólogos que cumplen filter, leídos de
+	// una misma vista; la página y el límite de filter se ignoran.
+	Export(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, error)
+
+	// Import da de alta los odontólogos en una única transacción y devuelve
+	// los errores de cada uno por posición. Si alguno falla o dryRun es true
+	// no se guarda ninguno.
+	Import(ctx context.Context, odontologos []domain.Odontologo, dryRun bool) (map[int]error, error)
+
+	// DeleteReasignando pasa los turnos no finalizados del odontólogo id al
+	// odontólogo idDestino y luego lo elimina, todo en una transacción. Los
+	// finalizados se dan de baja con él.
+	// Cada turno debe caber en la agenda de idDestino y no superponerse con
+	// sus turnos; ante el primero que no pueda pasar no se guarda nada y el
+	// error lleva ese turno en Detalle. version se controla igual que en
+	// Delete.
+	DeleteReasignando(ctx context.Context, id, idDestino, version int) error
+
+	// GetAgenda devuelve el horario semanal y las excepciones del
+	// odontólogo. Sin horarios el odontólogo atiende a cualquier hora.
+	GetAgenda(ctx context.Context, id int) (domain.Agenda, error)
+
+	// UpdateHorarios reemplaza el horario semanal del odontólogo. Con una
+	// lista vacía la agenda deja de restringir los turnos.
+	UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) (domain.Agenda, error)
 
-	Create(p domain.Odontologo) (domain.Odontologo, error)
+	CreateExcepcion(ctx context.Context, e domain.Excepcion) (domain.Excepcion, error)
 
-	Delete(id int) error
+	DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error
 
-	Update(id int, p domain.Odontologo) (domain.Odontologo, error)
+	// Disponibilidad devuelve los turnos libres del odontólogo según su
+	// agenda, sus turnos activos y los feriados. Los campos en cero de c
+	// toman los valores por defecto: desde ahora, por siete días, con la
+	// duración por defecto de los turnos y la granularidad del calendario.
+	Disponibilidad(ctx context.Context, id int, c domain.ConsultaDisponibilidad) ([]domain.TurnoLibre, error)
+
+	// PrimerTurnoLibre busca el turno libre más temprano entre todos los
+	// odontólogos activos; ante un empate elige el de menor ID. Si no hay
+	// ninguno devuelve domain.ErrNotFound.
+	PrimerTurnoLibre(ctx context.Context, c domain.ConsultaDisponibilidad) (domain.TurnoLibre, error)
 }
 
 type service struct {
-	r Repository
+	r          Repository
+	calendario domain.Calendario
+}
+
+// NewService crea el servicio de odontólogos. calendario configura la
+// búsqueda de turnos libres.
+func NewService(r Repository, calendario domain.Calendario) Service {
+	return &service{r, calendario}
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+func (s *service) Create(ctx context.Context, p domain.Odontologo) (domain.Odontologo, error) {
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		if p, err = NewRepository(tx).Create(ctx, p); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, p.IdOdontologo, domain.AccionAlta, nil, p)
+	})
+	if err != nil {
+		return domain.Odontologo{}, err
+	}
+	return p, nil
 }
 
-func (s *service) Create(p domain.Odontologo) (domain.Odontologo, error) {
-	p, err := s.r.Create(p)
+func (s *service) GetByID(ctx context.Context, id int) (domain.Odontologo, error) {
+	p, err := s.r.GetByID(ctx, id)
 	if err != nil {
 		return domain.Odontologo{}, err
 	}
 	return p, nil
 }
 
-func (s *service) GetByID(id int) (domain.Odontologo, error) {
-	p, err := s.r.GetByID(id)
+func (s *service) Update(ctx context.Context, id int, u domain.Odontologo) (domain.Odontologo, error) {
+	var p domain.Odontologo
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		r := NewRepository(tx)
+		antes, err := r.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		p = antes
+		if u.Version != 0 {
+			p.Version = u.Version
+		}
+		if u.NombreOdontologo != "" {
+			p.NombreOdontologo = u.NombreOdontologo
+		}
+		if u.ApellidoOdontologo != "" {
+			p.ApellidoOdontologo = u.ApellidoOdontologo
+		}
+		if u.MatriculaOdontologo != "" {
+			p.MatriculaOdontologo = u.MatriculaOdontologo
+		}
+		if p, err = r.Update(ctx, id, p); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionModificacion, antes, p)
+	})
 	if err != nil {
 		return domain.Odontologo{}, err
 	}
 	return p, nil
 }
 
-func (s *service) Update(id int, u domain.Odontologo) (domain.Odontologo, error) {
-	p, err := s.r.GetByID(id)
+func (s *service) Delete(ctx context.Context, id, version int) error {
+	return s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		return darDeBaja(ctx, tx, id, version)
+	})
+}
+
+func (s *service) Restore(ctx context.Context, id int) (domain.Odontologo, error) {
+	var p domain.Odontologo
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		if p, err = NewRepository(tx).Restore(ctx, id); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionRestauracion, nil, p)
+	})
 	if err != nil {
 		return domain.Odontologo{}, err
 	}
-	if u.NombreOdontologo != "" {
-		p.NombreOdontologo = u.NombreOdontologo
+	return p, nil
+}
+
+func (s *service) List(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error) {
+	filter.Normalize()
+	return s.r.List(ctx, filter)
+}
+
+func (s *service) Export(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, error) {
+	var odontologos []domain.Odontologo
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		var err error
+		odontologos, err = domain.ListarTodo(filter.ListOptions, func(opts domain.ListOptions) ([]domain.Odontologo, int, error) {
+			filter.ListOptions = opts
+			return NewRepository(v).List(ctx, filter)
+		})
+		return err
+	})
+	return odontologos, err
+}
+
+func (s *service) DeleteReasignando(ctx context.Context, id, idDestino, version int) error {
+	if id == idDestino {
+		return domain.NewError(domain.ErrValidation, "El odontólogo destino debe ser distinto del eliminado")
 	}
-	if u.ApellidoOdontologo != "" {
-		p.ApellidoOdontologo = u.ApellidoOdontologo
+	return s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		r := NewRepository(tx)
+		if _, err := r.GetByID(ctx, idDestino); err != nil {
+			return err
+		}
+		agenda, err := r.GetAgenda(ctx, idDestino)
+		if err != nil {
+			return err
+		}
+		turnos, err := turnosDe(ctx, tx, domain.TurnoFilter{IdOdontologo: id})
+		if err != nil {
+			return err
+		}
+		for _, antes := range turnos {
+			if antes.Finalizado() {
+				// Un turno finalizado queda con quien lo atendió y se da de
+				// baja junto con él.
+				continue
+			}
+			t := antes
+			t.IdOdontologo = idDestino
+			err := agenda.Admite(t)
+			if err == nil {
+				t, err = tx.UpdateTurno(ctx, t)
+			}
+			if err != nil {
+				return rechazarReasignacion(antes, idDestino, err)
+			}
+			if err := auditoria.NewRepository(tx).Registrar(ctx, domain.EntidadTurno, t.IdTurno, domain.AccionModificacion, antes, t); err != nil {
+				return err
+			}
+		}
+		return darDeBaja(ctx, tx, id, version)
+	})
+}
+
+func (s *service) GetAgenda(ctx context.Context, id int) (domain.Agenda, error) {
+	if _, err := s.r.GetByID(ctx, id); err != nil {
+		return domain.Agenda{}, err
 	}
-	if u.MatriculaOdontologo != "" {
-		p.MatriculaOdontologo = u.MatriculaOdontologo
+	return s.r.GetAgenda(ctx, id)
+}
+
+func (s *service) UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) (domain.Agenda, error) {
+	if err := domain.ValidarHorarios(horarios); err != nil {
+		return domain.Agenda{}, err
 	}
-	p, err = s.r.Update(id, p)
+	horarios = append([]domain.Horario{}, horarios...)
+	sort.Slice(horarios, func(i, j int) bool {
+		if horarios[i].Dia != horarios[j].Dia {
+			return horarios[i].Dia < horarios[j].Dia
+		}
+		return horarios[i].Desde < horarios[j].Desde
+	})
+	var agenda domain.Agenda
+	err := modificarAgenda(ctx, s.r, id, func(r Repository) error {
+		return r.UpdateHorarios(ctx, id, horarios)
+	}, &agenda)
 	if err != nil {
-		return domain.Odontologo{}, err
+		return domain.Agenda{}, err
 	}
-	return p, nil
+	return agenda, nil
 }
 
-func (s *service) Delete(id int) error {
-	err := s.r.Delete(id)
+func (s *service) CreateExcepcion(ctx context.Context, e domain.Excepcion) (domain.Excepcion, error) {
+	if err := e.Validar(); err != nil {
+		return domain.Excepcion{}, err
+	}
+	e.IdExcepcion = 0
+	err := modificarAgenda(ctx, s.r, e.IdOdontologo, func(r Repository) error {
+		var err error
+		e, err = r.CreateExcepcion(ctx, e)
+		return err
+	}, nil)
 	if err != nil {
+		return domain.Excepcion{}, err
+	}
+	return e, nil
+}
+
+func (s *service) DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error {
+	return modificarAgenda(ctx, s.r, idOdontologo, func(r Repository) error {
+		return r.DeleteExcepcion(ctx, idOdontologo, idExcepcion)
+	}, nil)
+}
+
+func (s *service) Disponibilidad(ctx context.Context, id int, c domain.ConsultaDisponibilidad) ([]domain.TurnoLibre, error) {
+	if c.Limite == 0 {
+		c.Limite = domain.MaxLimit
+	}
+	c = s.completar(c)
+	if err := c.Validar(); err != nil {
+		return nil, err
+	}
+	var libres []domain.TurnoLibre
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		if _, err := NewRepository(v).GetByID(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		libres, err = s.libres(ctx, v, id, c)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return libres, nil
+}
+
+func (s *service) PrimerTurnoLibre(ctx context.Context, c domain.ConsultaDisponibilidad) (domain.TurnoLibre, error) {
+	c.Limite = 1
+	c = s.completar(c)
+	if err := c.Validar(); err != nil {
+		return domain.TurnoLibre{}, err
+	}
+	var primero domain.TurnoLibre
+	encontrado := false
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		consulta := c
+		filter := domain.OdontologoFilter{ListOptions: domain.ListOptions{Limit: domain.MaxLimit}}
+		for filter.Page = 1; ; filter.Page++ {
+			page, total, err := NewRepository(v).List(ctx, filter)
+			if err != nil {
+				return err
+			}
+			for _, o := range page {
+				libres, err := s.libres(ctx, v, o.IdOdontologo, consulta)
+				if err != nil {
+					return err
+				}
+				if len(libres) == 0 {
+					continue
+				}
+				primero, encontrado = libres[0], true
+				// Los siguientes odontólogos sólo mejoran el resultado con
+				// un turno estrictamente anterior.
+				consulta.Hasta = domain.Fecha(primero.Desde.Time().Add(-time.Nanosecond))
+				if consulta.Hasta.Before(consulta.Desde) {
+					return nil
+				}
+			}
+			if len(page) == 0 || filter.Page*filter.Limit >= total {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return domain.TurnoLibre{}, err
+	}
+	if !encontrado {
+		return domain.TurnoLibre{}, domain.NewError(domain.ErrNotFound, "No hay turnos libres de %d minutos entre %s y %s", c.DuracionMinutos, c.Desde, c.Hasta)
+	}
+	return primero, nil
+}
+
+// completar asigna los valores por defecto a los campos en cero de c.
+func (s *service) completar(c domain.ConsultaDisponibilidad) domain.ConsultaDisponibilidad {
+	if c.Desde.IsZero() {
+		c.Desde = domain.Fecha(time.Now().UTC().Truncate(time.Minute).Add(time.Minute))
 	}
-	return nil
+	if c.Hasta.IsZero() {
+		c.Hasta = domain.Fecha(c.Desde.Time().AddDate(0, 0, 7))
+	}
+	if c.DuracionMinutos == 0 {
+		c.DuracionMinutos = domain.DuracionTurnoPorDefecto
+	}
+	if c.PasoMinutos == 0 {
+		c.PasoMinutos = max(int(s.calendario.Granularidad/time.Minute), 1)
+	}
+	return c
+}
+
+// libres calcula sobre la vista v los turnos libres del odontólogo id.
+func (s *service) libres(ctx context.Context, v store.StoreInterface, id int, c domain.ConsultaDisponibilidad) ([]domain.TurnoLibre, error) {
+	agenda, err := NewRepository(v).GetAgenda(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Un turno que empezó antes del rango puede ocupar sus primeros minutos.
+	turnos, err := turnosDe(ctx, v, domain.TurnoFilter{
+		IdOdontologo: id,
+		Desde:        domain.Fecha(c.Desde.Time().Add(-domain.DuracionTurnoMaxima * time.Minute)),
+		Hasta:        domain.Fecha(c.Hasta.Time().Add(time.Duration(c.DuracionMinutos) * time.Minute)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return agenda.Libres(c, s.calendario.Feriados, turnos), nil
+}
+
+// modificarAgenda aplica fn a la agenda del odontólogo id en una
+// transacción y registra en la auditoría la agenda completa antes y después
+// del cambio. Si despues no es nil recibe la agenda resultante.
+func modificarAgenda(ctx context.Context, repo Repository, id int, fn func(r Repository) error, despues *domain.Agenda) error {
+	return repo.WithinTx(ctx, func(tx store.StoreInterface) error {
+		r := NewRepository(tx)
+		if _, err := r.GetByID(ctx, id); err != nil {
+			return err
+		}
+		antes, err := r.GetAgenda(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+		agenda, err := r.GetAgenda(ctx, id)
+		if err != nil {
+			return err
+		}
+		if despues != nil {
+			*despues = agenda
+		}
+		return auditoria.NewRepository(tx).Registrar(ctx, domain.EntidadAgenda, id, domain.AccionModificacion, antes, agenda)
+	})
+}
+
+// rechazarReasignacion explica por qué el turno t no puede pasar al
+// odontólogo idDestino. Los errores de dominio conservan su tipo y llevan
+// el turno rechazado en Detalle; los demás se devuelven sin cambios.
+func rechazarReasignacion(t domain.Turno, idDestino int, err error) error {
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		return err
+	}
+	return &domain.Error{
+		Kind:    domainErr.Kind,
+		Message: fmt.Sprintf("No se puede pasar el turno %d al odontólogo %d: %s", t.IdTurno, idDestino, domainErr.Message),
+		Detalle: []domain.Turno{t},
+	}
+}
+
+// darDeBaja elimina al odontólogo dentro de la transacción tx y registra la
+// baja con el estado previo.
+func darDeBaja(ctx context.Context, tx store.StoreInterface, id, version int) error {
+	r := NewRepository(tx)
+	antes, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	return registrar(ctx, tx, id, domain.AccionBaja, antes, nil)
+}
+
+// registrar agrega a la auditoría un movimiento sobre el odontólogo id.
+func registrar(ctx context.Context, tx store.StoreInterface, id int, accion string, antes, despues interface{}) error {
+	return auditoria.NewRepository(tx).Registrar(ctx, domain.EntidadOdontologo, id, accion, antes, despues)
+}
+
+// turnosDe devuelve todos los turnos que cumplen filter, recorriendo todas
+// las páginas del listado.
+func turnosDe(ctx context.Context, storage store.StoreInterface, filter domain.TurnoFilter) ([]domain.Turno, error) {
+	filter.Limit = domain.MaxLimit
+	var turnos []domain.Turno
+	for filter.Page = 1; ; filter.Page++ {
+		page, total, err := storage.ListTurnos(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		turnos = append(turnos, page...)
+		if len(page) == 0 || len(turnos) >= total {
+			return turnos, nil
+		}
+	}
+}
+
+func (s *service) Import(ctx context.Context, odontologos []domain.Odontologo, dryRun bool) (map[int]error, error) {
+	return store.Batch(ctx, s.r, odontologos, dryRun, func(tx store.StoreInterface, p domain.Odontologo) error {
+		p, err := NewRepository(tx).Create(ctx, p)
+		if err != nil {
+			return err
+		}
+		return registrar(ctx, tx, p.IdOdontologo, domain.AccionAlta, nil, p)
+	})
 }
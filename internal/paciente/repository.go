@@ -1,20 +1,37 @@
 package paciente
 
 import (
-	"errors"
+	"context"
+	"fmt"
 
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 )
 
 type Repository interface {
-	GetPacienteByID(id int) (domain.Paciente, error)
+	GetPacienteByID(ctx context.Context, id int) (domain.Paciente, error)
 
-	CreatePaciente(p domain.Paciente) (domain.Paciente, error)
+	// GetPacienteByDni busca al paciente activo con ese DNI. Si no hay
+	// ninguno devuelve domain.ErrNotFound.
+	GetPacienteByDni(ctx context.Context, dni string) (domain.Paciente, error)
 
-	UpdatePaciente(id int, p domain.Paciente) (domain.Paciente, error)
+	CreatePaciente(ctx context.Context, p domain.Paciente) (domain.Paciente, error)
 
-	DeletePaciente(id int) error
+	UpdatePaciente(ctx context.Context, id int, p domain.Paciente) (domain.Paciente, error)
+
+	DeletePaciente(ctx context.Context, id, version int) error
+
+	RestorePaciente(ctx context.Context, id int) (domain.Paciente, error)
+
+	ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error)
+
+	// View ejecuta fn sobre una vista de sólo lectura del store, para
+	// que varias lecturas vean el mismo estado.
+	View(ctx context.Context, fn func(v store.StoreInterface) error) error
+
+	// WithinTx ejecuta fn en una transacción del store. Con tx se pueden
+	// construir repositorios de cualquier entidad que participen de ella.
+	WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error
 }
 
 type repository struct {
@@ -25,36 +42,64 @@ func NewRepository(storage store.StoreInterface) Repository {
 	return &repository{storage}
 }
 
-func (r *repository) CreatePaciente(p domain.Paciente) (domain.Paciente, error) {
+func (r *repository) CreatePaciente(ctx context.Context, p domain.Paciente) (domain.Paciente, error) {
 
-	err := r.storage.CreatePaciente(p)
+	p, err := r.storage.CreatePaciente(ctx, p)
 	if err != nil {
-		return domain.Paciente{}, errors.New("Ha ocurrido un error al crear paciente")
+		return domain.Paciente{}, fmt.Errorf("Ha ocurrido un error al crear paciente: %w", err)
 	}
 	return p, nil
 }
 
-func (r *repository) GetPacienteByID(id int) (domain.Paciente, error) {
-	paciente, err := r.storage.ReadPaciente(id)
+func (r *repository) GetPacienteByID(ctx context.Context, id int) (domain.Paciente, error) {
+	paciente, err := r.storage.ReadPaciente(ctx, id)
 	if err != nil {
-		return domain.Paciente{}, errors.New("El paciente no existe")
+		return domain.Paciente{}, err
 	}
 	return paciente, nil
 }
 
-func (r *repository) UpdatePaciente(id int, p domain.Paciente) (domain.Paciente, error) {
+func (r *repository) GetPacienteByDni(ctx context.Context, dni string) (domain.Paciente, error) {
+	filter := domain.PacienteFilter{Dni: dni, ListOptions: domain.ListOptions{Page: 1, Limit: 1}}
+	pacientes, _, err := r.storage.ListPacientes(ctx, filter)
+	if err != nil {
+		return domain.Paciente{}, err
+	}
+	if len(pacientes) == 0 {
+		return domain.Paciente{}, domain.NewError(domain.ErrNotFound, "No existe un paciente con DNI %s", dni)
+	}
+	return pacientes[0], nil
+}
+
+func (r *repository) UpdatePaciente(ctx context.Context, id int, p domain.Paciente) (domain.Paciente, error) {
 
-	err := r.storage.UpdatePaciente(p)
+	p, err := r.storage.UpdatePaciente(ctx, p)
 	if err != nil {
-		return domain.Paciente{}, errors.New("Ha ocurrido un error al actualizar paciente")
+		return domain.Paciente{}, fmt.Errorf("Ha ocurrido un error al actualizar paciente: %w", err)
 	}
 	return p, nil
 }
 
-func (r *repository) DeletePaciente(id int) error {
-	err := r.storage.DeletePaciente(id)
+func (r *repository) DeletePaciente(ctx context.Context, id, version int) error {
+	err := r.storage.DeletePaciente(ctx, id, version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (r *repository) RestorePaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	return r.storage.RestorePaciente(ctx, id)
+}
+
+func (r *repository) ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error) {
+	return r.storage.ListPacientes(ctx, filter)
+}
+
+func (r *repository) View(ctx context.Context, fn func(v store.StoreInterface) error) error {
+	return r.storage.View(ctx, fn)
+}
+
+func (r *repository) WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error {
+	return r.storage.WithinTx(ctx, fn)
+}
@@ -1,17 +1,42 @@
 package paciente
 
 import (
+	"context"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/auditoria"
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 )
 
 type Service interface {
-	GetPacienteByID(id int) (domain.Paciente, error)
+	GetPacienteByID(ctx context.Context, id int) (domain.Paciente, error)
+
+	CreatePaciente(ctx context.Context, p domain.Paciente) (domain.Paciente, error)
+
+	// DeletePaciente da de baja al paciente. Si version no es 0 debe coincidir
+	// con la versión guardada; si no, devuelve domain.ErrPrecondition.
+	DeletePaciente(ctx context.Context, id, version int) error
+
+	// RestorePaciente restaura al paciente y a los turnos que se dieron de baja
+	// con él.
+	RestorePaciente(ctx context.Context, id int) (domain.Paciente, error)
+
+	// UpdatePaciente aplica los campos no vacíos de p. Si p.Version no es 0 debe
+	// coincidir con la versión guardada; si no, devuelve
+	// domain.ErrPrecondition.
+	UpdatePaciente(ctx context.Context, id int, p domain.Paciente) (domain.Paciente, error)
 
-	CreatePaciente(p domain.Paciente) (domain.Paciente, error)
+	// ImportPacientes da de alta los pacientes en una única transacción y
+	// devuelve los errores de cada uno por posición. Si alguno falla o dryRun
+	// es true no se guarda ninguno.
+	ImportPacientes(ctx context.Context, pacientes []domain.Paciente, dryRun bool) (map[int]error, error)
 
-	DeletePaciente(id int) error
+	ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error)
 
-	UpdatePaciente(id int, p domain.Paciente) (domain.Paciente, error)
+	// ExportPacientes devuelve todos los pacientes que cumplen filter, leídos
+	// de una misma vista; la página y el límite de filter se ignoran.
+	ExportPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, error)
 }
 
 type service struct {
@@ -22,53 +47,143 @@ func NewService(r Repository) Service {
 	return &service{r}
 }
 
-func (s *service) CreatePaciente(p domain.Paciente) (domain.Paciente, error) {
-	p, err := s.r.CreatePaciente(p)
+func (s *service) CreatePaciente(ctx context.Context, p domain.Paciente) (domain.Paciente, error) {
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		p, err = CreateAuditado(ctx, tx, p)
+		return err
+	})
 	if err != nil {
 		return domain.Paciente{}, err
 	}
 	return p, nil
 }
 
-func (s *service) GetPacienteByID(id int) (domain.Paciente, error) {
-	p, err := s.r.GetPacienteByID(id)
+func (s *service) GetPacienteByID(ctx context.Context, id int) (domain.Paciente, error) {
+	p, err := s.r.GetPacienteByID(ctx, id)
 	if err != nil {
 		return domain.Paciente{}, err
 	}
 	return p, nil
 }
 
-func (s *service) UpdatePaciente(id int, u domain.Paciente) (domain.Paciente, error) {
-	p, err := s.r.GetPacienteByID(id)
+func (s *service) UpdatePaciente(ctx context.Context, id int, u domain.Paciente) (domain.Paciente, error) {
+	var p domain.Paciente
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		r := NewRepository(tx)
+		antes, err := r.GetPacienteByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		p = antes
+		if u.Version != 0 {
+			p.Version = u.Version
+		}
+		if u.NombrePaciente != "" {
+			p.NombrePaciente = u.NombrePaciente
+		}
+		if u.ApellidoPaciente != "" {
+			p.ApellidoPaciente = u.ApellidoPaciente
+		}
+		if u.DomicilioPaciente != "" {
+			p.DomicilioPaciente = u.DomicilioPaciente
+		}
+		if u.DniPaciente != "" {
+			p.DniPaciente = u.DniPaciente
+		}
+		if !u.FechaDeAltaPaciente.IsZero() {
+			p.FechaDeAltaPaciente = u.FechaDeAltaPaciente
+		}
+		if err := validarAlta(p); err != nil {
+			return err
+		}
+		if p, err = r.UpdatePaciente(ctx, id, p); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionModificacion, antes, p)
+	})
 	if err != nil {
 		return domain.Paciente{}, err
 	}
-	if u.NombrePaciente != "" {
-		p.NombrePaciente = u.NombrePaciente
-	}
-	if u.ApellidoPaciente != "" {
-		p.ApellidoPaciente = u.ApellidoPaciente
-	}
-	if u.DomicilioPaciente != "" {
-		p.DomicilioPaciente = u.DomicilioPaciente
-	}
-	if u.DniPaciente != "" {
-		p.DniPaciente = u.DniPaciente
-	}
-	if u.FechaDeAltaPaciente != "" {
-		p.FechaDeAltaPaciente = u.FechaDeAltaPaciente
-	}
-	p, err = s.r.UpdatePaciente(id, p)
+	return p, nil
+}
+
+func (s *service) DeletePaciente(ctx context.Context, id, version int) error {
+	return s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		r := NewRepository(tx)
+		antes, err := r.GetPacienteByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := r.DeletePaciente(ctx, id, version); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionBaja, antes, nil)
+	})
+}
+
+func (s *service) RestorePaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	var p domain.Paciente
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		if p, err = NewRepository(tx).RestorePaciente(ctx, id); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionRestauracion, nil, p)
+	})
 	if err != nil {
 		return domain.Paciente{}, err
 	}
 	return p, nil
 }
 
-func (s *service) DeletePaciente(id int) error {
-	err := s.r.DeletePaciente(id)
-	if err != nil {
+func (s *service) ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error) {
+	filter.Normalize()
+	return s.r.ListPacientes(ctx, filter)
+}
+
+func (s *service) ExportPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, error) {
+	var pacientes []domain.Paciente
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		var err error
+		pacientes, err = domain.ListarTodo(filter.ListOptions, func(opts domain.ListOptions) ([]domain.Paciente, int, error) {
+			filter.ListOptions = opts
+			return NewRepository(v).ListPacientes(ctx, filter)
+		})
 		return err
+	})
+	return pacientes, err
+}
+
+// CreateAuditado da de alta al paciente dentro de la transacción tx y
+// registra el alta en la auditoría.
+func CreateAuditado(ctx context.Context, tx store.StoreInterface, p domain.Paciente) (domain.Paciente, error) {
+	if err := validarAlta(p); err != nil {
+		return domain.Paciente{}, err
+	}
+	p, err := NewRepository(tx).CreatePaciente(ctx, p)
+	if err != nil {
+		return domain.Paciente{}, err
+	}
+	return p, registrar(ctx, tx, p.IdPaciente, domain.AccionAlta, nil, p)
+}
+
+// validarAlta rechaza las fechas de alta posteriores al momento actual.
+func validarAlta(p domain.Paciente) error {
+	if p.FechaDeAltaPaciente.Time().After(time.Now()) {
+		return domain.NewError(domain.ErrValidation, "La fecha de alta %s es posterior a la actual", p.FechaDeAltaPaciente)
 	}
 	return nil
 }
+
+// registrar agrega a la auditoría un movimiento sobre el paciente id.
+func registrar(ctx context.Context, tx store.StoreInterface, id int, accion string, antes, despues interface{}) error {
+	return auditoria.NewRepository(tx).Registrar(ctx, domain.EntidadPaciente, id, accion, antes, despues)
+}
+
+func (s *service) ImportPacientes(ctx context.Context, pacientes []domain.Paciente, dryRun bool) (map[int]error, error) {
+	return store.Batch(ctx, s.r, pacientes, dryRun, func(tx store.StoreInterface, p domain.Paciente) error {
+		_, err := CreateAuditado(ctx, tx, p)
+		return err
+	})
+}
@@ -0,0 +1,82 @@
+package serie
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+type Repository interface {
+	GetSerieByID(ctx context.Context, id int) (domain.Serie, error)
+
+	CreateSerie(ctx context.Context, s domain.Serie) (domain.Serie, error)
+
+	UpdateSerie(ctx context.Context, s domain.Serie) (domain.Serie, error)
+
+	// ListTurnos devuelve todos los turnos activos de la serie id, ordenados
+	// por fecha.
+	ListTurnos(ctx context.Context, id int) ([]domain.Turno, error)
+
+	// WithinTx ejecuta fn en una transacción del store. Con tx se pueden
+	// construir repositorios de cualquier entidad que participen de ella.
+	WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error
+
+	// View ejecuta fn sobre una vista de sólo lectura del store, para
+	// consultas que leen varias entidades y no escriben.
+	View(ctx context.Context, fn func(v store.StoreInterface) error) error
+}
+
+type repository struct {
+	storage store.StoreInterface
+}
+
+func NewRepository(storage store.StoreInterface) Repository {
+	return &repository{storage}
+}
+
+func (r *repository) GetSerieByID(ctx context.Context, id int) (domain.Serie, error) {
+	return r.storage.ReadSerie(ctx, id)
+}
+
+func (r *repository) CreateSerie(ctx context.Context, s domain.Serie) (domain.Serie, error) {
+	s, err := r.storage.CreateSerie(ctx, s)
+	if err != nil {
+		return domain.Serie{}, fmt.Errorf("Ha ocurrido un error al crear la serie: %w", err)
+	}
+	return s, nil
+}
+
+func (r *repository) UpdateSerie(ctx context.Context, s domain.Serie) (domain.Serie, error) {
+	s, err := r.storage.UpdateSerie(ctx, s)
+	if err != nil {
+		return domain.Serie{}, fmt.Errorf("Ha ocurrido un error al actualizar la serie: %w", err)
+	}
+	return s, nil
+}
+
+func (r *repository) ListTurnos(ctx context.Context, id int) ([]domain.Turno, error) {
+	filter := domain.TurnoFilter{IdSerie: id}
+	filter.Sort = "fechaTurno"
+	filter.Limit = domain.MaxLimit
+	var turnos []domain.Turno
+	for filter.Page = 1; ; filter.Page++ {
+		page, total, err := r.storage.ListTurnos(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		turnos = append(turnos, page...)
+		if len(page) == 0 || len(turnos) >= total {
+			return turnos, nil
+		}
+	}
+}
+
+func (r *repository) WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error {
+	return r.storage.WithinTx(ctx, fn)
+}
+
+func (r *repository) View(ctx context.Context, fn func(v store.StoreInterface) error) error {
+	return r.storage.View(ctx, fn)
+}
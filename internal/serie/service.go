@@ -0,0 +1,325 @@
+package serie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/auditoria"
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/internal/turno"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+// errDescartar hace que CreateSerie descarte su transacción después de
+// verificar todos los turnos.
+var errDescartar = errors.New("transacción descartada")
+
+// Service administra las series de turnos. Cada turno de una serie es un
+// turno común: se reserva con las mismas verificaciones de superposición y
+// agenda, y se puede modificar o cambiar de estado por separado. Los cambios
+// y las cancelaciones de la serie parten de uno de sus turnos y llegan a los
+// que indica el alcance: domain.AlcanceOcurrencia, domain.AlcanceSiguientes
+// o domain.AlcanceSerie. Si alguno de esos turnos falla no se aplica ninguno.
+type Service interface {
+	// CreateSerie guarda la serie y reserva todos sus turnos en una única
+	// transacción. Sin Intervalo se repite en cada período y sin
+	// DuracionMinutos sus turnos duran domain.DuracionTurnoPorDefecto. Si
+	// algún turno no se puede reservar no se guarda nada y devuelve un
+	// domain.Error del tipo del primer rechazo cuyo Detalle lista todas las
+	// ocurrencias rechazadas. Con dryRun hace las mismas verificaciones sin
+	// guardar y devuelve la serie y sus turnos sin IDs.
+	CreateSerie(ctx context.Context, s domain.Serie, dryRun bool) (domain.Serie, []domain.Turno, error)
+
+	// GetSerie devuelve la serie con sus turnos activos, ordenados por fecha.
+	GetSerie(ctx context.Context, id int) (domain.Serie, []domain.Turno, error)
+
+	// UpdateTurnos aplica los campos no vacíos de u, salvo el paciente, a los
+	// turnos de la serie idSerie que indica alcance a partir del turno
+	// idTurno. Un cambio de fecha corre a todos esos turnos lo mismo que al
+	// turno idTurno. Los alcances de varios turnos saltean los que ya no
+	// pueden modificarse y AlcanceSerie actualiza también la regla de la
+	// serie. u.Version se controla sólo en el turno idTurno.
+	UpdateTurnos(ctx context.Context, idSerie, idTurno int, alcance string, u domain.Turno) ([]domain.Turno, error)
+
+	// CancelarTurnos cancela con motivo los turnos de la serie idSerie que
+	// indica alcance a partir del turno idTurno. Los alcances de varios
+	// turnos saltean los que ya no pueden cancelarse. version se controla
+	// sólo en el turno idTurno.
+	CancelarTurnos(ctx context.Context, idSerie, idTurno int, alcance, motivo string, version int) ([]domain.Turno, error)
+}
+
+type service struct {
+	r Repository
+}
+
+func NewService(r Repository) Service {
+	return &service{r}
+}
+
+func (s *service) CreateSerie(ctx context.Context, serie domain.Serie, dryRun bool) (domain.Serie, []domain.Turno, error) {
+	if serie.Intervalo == 0 {
+		serie.Intervalo = 1
+	}
+	if serie.DuracionMinutos == 0 {
+		serie.DuracionMinutos = domain.DuracionTurnoPorDefecto
+	}
+	if err := serie.Validar(); err != nil {
+		return domain.Serie{}, nil, err
+	}
+	fechas, err := serie.Ocurrencias()
+	if err != nil {
+		return domain.Serie{}, nil, err
+	}
+	serie.Cantidad, serie.Hasta = len(fechas), fechas[len(fechas)-1].Time().Format("2006-01-02")
+
+	var turnos []domain.Turno
+	var rechazadas []domain.OcurrenciaRechazada
+	var primero *domain.Error
+	err = s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		if serie, err = NewRepository(tx).CreateSerie(ctx, serie); err != nil {
+			return err
+		}
+		if err := registrar(ctx, tx, serie.IdSerie, domain.AccionAlta, nil, serie); err != nil {
+			return err
+		}
+		for _, fecha := range fechas {
+			t, err := turno.CreateAuditado(ctx, tx, domain.Turno{
+				DescripcionTurno: serie.DescripcionTurno,
+				FechaTurno:       fecha,
+				DuracionMinutos:  serie.DuracionMinutos,
+				IdOdontologo:     serie.IdOdontologo,
+				IdPaciente:       serie.IdPaciente,
+				IdSerie:          serie.IdSerie,
+			})
+			var domainErr *domain.Error
+			if errors.As(err, &domainErr) {
+				if primero == nil {
+					primero = domainErr
+				}
+				rechazadas = append(rechazadas, domain.OcurrenciaRechazada{FechaTurno: fecha, Motivo: domainErr.Message})
+				continue
+			} else if err != nil {
+				return err
+			}
+			turnos = append(turnos, t)
+		}
+		if dryRun || len(rechazadas) > 0 {
+			return errDescartar
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDescartar) {
+		return domain.Serie{}, nil, err
+	}
+	if len(rechazadas) > 0 {
+		return domain.Serie{}, nil, &domain.Error{
+			Kind:    primero.Kind,
+			Message: fmt.Sprintf("No se pueden reservar %d de los %d turnos de la serie", len(rechazadas), len(fechas)),
+			Detalle: rechazadas,
+		}
+	}
+	if dryRun {
+		serie.IdSerie = 0
+		for i := range turnos {
+			turnos[i].IdTurno, turnos[i].IdSerie, turnos[i].Version = 0, 0, 0
+		}
+	}
+	return serie, turnos, nil
+}
+
+func (s *service) GetSerie(ctx context.Context, id int) (domain.Serie, []domain.Turno, error) {
+	var serie domain.Serie
+	var turnos []domain.Turno
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		r := NewRepository(v)
+		var err error
+		if serie, err = r.GetSerieByID(ctx, id); err != nil {
+			return err
+		}
+		turnos, err = r.ListTurnos(ctx, id)
+		return err
+	})
+	if err != nil {
+		return domain.Serie{}, nil, err
+	}
+	if turnos == nil {
+		turnos = []domain.Turno{}
+	}
+	return serie, turnos, nil
+}
+
+func (s *service) UpdateTurnos(ctx context.Context, idSerie, idTurno int, alcance string, u domain.Turno) ([]domain.Turno, error) {
+	if err := domain.ValidarAlcance(alcance); err != nil {
+		return nil, err
+	}
+	var turnos []domain.Turno
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		serie, ancla, objetivo, err := alcanzados(ctx, tx, idSerie, idTurno, alcance, "modificar", func(t domain.Turno) bool {
+			return !t.Finalizado()
+		})
+		if err != nil {
+			return err
+		}
+		var corrimiento time.Duration
+		if !u.FechaTurno.IsZero() {
+			corrimiento = u.FechaTurno.Time().Sub(ancla.FechaTurno.Time())
+		}
+		if corrimiento > 0 {
+			// Los turnos se corren empezando por el último para que ninguno
+			// choque con la fecha anterior de otro de la serie.
+			sort.Slice(objetivo, func(i, j int) bool { return objetivo[j].FechaTurno.Before(objetivo[i].FechaTurno) })
+		}
+		for _, t := range objetivo {
+			cambio := u
+			if t.IdTurno != ancla.IdTurno {
+				cambio.Version = 0
+			}
+			if corrimiento != 0 {
+				cambio.FechaTurno = domain.Fecha(t.FechaTurno.Time().Add(corrimiento))
+			}
+			p, err := turno.UpdateAuditado(ctx, tx, t.IdTurno, cambio)
+			if err != nil {
+				return enTurno(t, alcance, err)
+			}
+			turnos = append(turnos, p)
+		}
+		if alcance != domain.AlcanceSerie {
+			return nil
+		}
+		return actualizarRegla(ctx, tx, serie, u, corrimiento)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(turnos, func(i, j int) bool { return turnos[i].FechaTurno.Before(turnos[j].FechaTurno) })
+	return turnos, nil
+}
+
+func (s *service) CancelarTurnos(ctx context.Context, idSerie, idTurno int, alcance, motivo string, version int) ([]domain.Turno, error) {
+	if err := domain.ValidarAlcance(alcance); err != nil {
+		return nil, err
+	}
+	// Se controla antes para no repetir el error en cada turno.
+	if strings.TrimSpace(motivo) == "" {
+		return nil, domain.NewError(domain.ErrValidation, "Para cancelar un turno hay que indicar el motivo")
+	}
+	var turnos []domain.Turno
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		_, ancla, objetivo, err := alcanzados(ctx, tx, idSerie, idTurno, alcance, "cancelar", func(t domain.Turno) bool {
+			return t.PuedePasarA(domain.EstadoCancelado)
+		})
+		if err != nil {
+			return err
+		}
+		for _, t := range objetivo {
+			v := 0
+			if t.IdTurno == ancla.IdTurno {
+				v = version
+			}
+			p, err := turno.CambiarEstadoAuditado(ctx, tx, t.IdTurno, domain.EstadoCancelado, motivo, v)
+			if err != nil {
+				return enTurno(t, alcance, err)
+			}
+			turnos = append(turnos, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return turnos, nil
+}
+
+// alcanzados devuelve la serie idSerie, su turno idTurno y los turnos de la
+// serie a los que llega alcance a partir de él, ordenados por fecha. Los
+// alcances de varios turnos omiten los que no cumplen admite y, si no queda
+// ninguno, devuelven un domain.ErrConflict; accion completa su mensaje.
+func alcanzados(ctx context.Context, tx store.StoreInterface, idSerie, idTurno int, alcance, accion string,
+	admite func(t domain.Turno) bool) (domain.Serie, domain.Turno, []domain.Turno, error) {
+	r := NewRepository(tx)
+	serie, err := r.GetSerieByID(ctx, idSerie)
+	if err != nil {
+		return domain.Serie{}, domain.Turno{}, nil, err
+	}
+	ancla, err := turno.NewRepository(tx).GetTurnoByID(ctx, idTurno)
+	if err != nil {
+		return domain.Serie{}, domain.Turno{}, nil, err
+	}
+	if ancla.IdSerie != idSerie {
+		return domain.Serie{}, domain.Turno{}, nil, domain.NewError(domain.ErrNotFound, "El turno %d no pertenece a la serie %d", idTurno, idSerie)
+	}
+	if alcance == domain.AlcanceOcurrencia {
+		return serie, ancla, []domain.Turno{ancla}, nil
+	}
+	turnos, err := r.ListTurnos(ctx, idSerie)
+	if err != nil {
+		return domain.Serie{}, domain.Turno{}, nil, err
+	}
+	var elegidos []domain.Turno
+	for _, t := range turnos {
+		if alcance == domain.AlcanceSiguientes && t.FechaTurno.Before(ancla.FechaTurno) {
+			continue
+		}
+		if admite(t) {
+			elegidos = append(elegidos, t)
+		}
+	}
+	if len(elegidos) == 0 {
+		return domain.Serie{}, domain.Turno{}, nil, domain.NewError(domain.ErrConflict, "La serie %d no tiene turnos que se puedan %s con alcance %s", idSerie, accion, alcance)
+	}
+	return serie, ancla, elegidos, nil
+}
+
+// actualizarRegla aplica a la regla de la serie el cambio u que se hizo a
+// todos sus turnos: la descripción, la duración, el odontólogo y el
+// corrimiento de las fechas.
+func actualizarRegla(ctx context.Context, tx store.StoreInterface, serie domain.Serie, u domain.Turno, corrimiento time.Duration) error {
+	antes := serie
+	if u.DescripcionTurno != "" {
+		serie.DescripcionTurno = u.DescripcionTurno
+	}
+	if u.DuracionMinutos != 0 {
+		serie.DuracionMinutos = u.DuracionMinutos
+	}
+	if u.IdOdontologo != 0 {
+		serie.IdOdontologo = u.IdOdontologo
+	}
+	if corrimiento != 0 {
+		serie.FechaInicio = domain.Fecha(serie.FechaInicio.Time().Add(corrimiento))
+		serie.Hasta = ""
+		fechas, err := serie.Ocurrencias()
+		if err != nil {
+			return err
+		}
+		serie.Hasta = fechas[len(fechas)-1].Time().Format("2006-01-02")
+	}
+	serie, err := NewRepository(tx).UpdateSerie(ctx, serie)
+	if err != nil {
+		return err
+	}
+	return registrar(ctx, tx, serie.IdSerie, domain.AccionModificacion, antes, serie)
+}
+
+// enTurno agrega al error del turno t de una serie cuál fue, salvo que el
+// cambio alcanzara sólo a ese turno.
+func enTurno(t domain.Turno, alcance string, err error) error {
+	var domainErr *domain.Error
+	if alcance == domain.AlcanceOcurrencia || !errors.As(err, &domainErr) {
+		return err
+	}
+	return &domain.Error{
+		Kind:    domainErr.Kind,
+		Message: fmt.Sprintf("Turno %d del %s: %s", t.IdTurno, t.FechaTurno, domainErr.Message),
+		Detalle: domainErr.Detalle,
+	}
+}
+
+// registrar agrega a la auditoría un movimiento sobre la serie id.
+func registrar(ctx context.Context, tx store.StoreInterface, id int, accion string, antes, despues interface{}) error {
+	return auditoria.NewRepository(tx).Registrar(ctx, domain.EntidadSerie, id, accion, antes, despues)
+}
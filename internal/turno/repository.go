@@ -1,20 +1,33 @@
 package turno
 
 import (
-	"errors"
+	"context"
+	"fmt"
 
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 )
 
 type Repository interface {
-	GetTurnoByID(id int) (domain.Turno, error)
+	GetTurnoByID(ctx context.Context, id int) (domain.Turno, error)
 
-	CreateTurno(p domain.Turno) (domain.Turno, error)
+	CreateTurno(ctx context.Context, p domain.Turno) (domain.Turno, error)
 
-	UpdateTurno(id int, p domain.Turno) (domain.Turno, error)
+	UpdateTurno(ctx context.Context, id int, p domain.Turno) (domain.Turno, error)
 
-	DeleteTurno(id int) error
+	DeleteTurno(ctx context.Context, id, version int) error
+
+	RestoreTurno(ctx context.Context, id int) (domain.Turno, error)
+
+	ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error)
+
+	// WithinTx ejecuta fn en una transacción del store. Con tx se pueden
+	// construir repositorios de cualquier entidad que participen de ella.
+	WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error
+
+	// View ejecuta fn sobre una vista de sólo lectura del store, para
+	// consultas que leen varias entidades y no escriben.
+	View(ctx context.Context, fn func(v store.StoreInterface) error) error
 }
 
 type repository struct {
@@ -25,35 +38,51 @@ func NewRepository(storage store.StoreInterface) Repository {
 	return &repository{storage}
 }
 
-func (r *repository) CreateTurno(p domain.Turno) (domain.Turno, error) {
+func (r *repository) CreateTurno(ctx context.Context, p domain.Turno) (domain.Turno, error) {
 
-	err := r.storage.CreateTurno(p)
+	p, err := r.storage.CreateTurno(ctx, p)
 	if err != nil {
-		return domain.Turno{}, errors.New("Ha ocurrido un error al crear turno")
+		return domain.Turno{}, fmt.Errorf("Ha ocurrido un error al crear turno: %w", err)
 	}
 	return p, nil
 }
 
-func (r *repository) GetTurnoByID(id int) (domain.Turno, error) {
-	turno, err := r.storage.ReadTurno(id)
+func (r *repository) GetTurnoByID(ctx context.Context, id int) (domain.Turno, error) {
+	turno, err := r.storage.ReadTurno(ctx, id)
 	if err != nil {
-		return domain.Turno{}, errors.New("El turno no existe")
+		return domain.Turno{}, err
 	}
 	return turno, nil
 }
 
-func (r *repository) UpdateTurno(id int, p domain.Turno) (domain.Turno, error) {
-	err := r.storage.UpdateTurno(p)
+func (r *repository) UpdateTurno(ctx context.Context, id int, p domain.Turno) (domain.Turno, error) {
+	p, err := r.storage.UpdateTurno(ctx, p)
 	if err != nil {
-		return domain.Turno{}, errors.New("Ha ocurrido un error al actualizar turno")
+		return domain.Turno{}, fmt.Errorf("Ha ocurrido un error al actualizar turno: %w", err)
 	}
 	return p, nil
 }
 
-func (r *repository) DeleteTurno(id int) error {
-	err := r.storage.DeleteTurno(id)
+func (r *repository) DeleteTurno(ctx context.Context, id, version int) error {
+	err := r.storage.DeleteTurno(ctx, id, version)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func (r *repository) RestoreTurno(ctx context.Context, id int) (domain.Turno, error) {
+	return r.storage.RestoreTurno(ctx, id)
+}
+
+func (r *repository) ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error) {
+	return r.storage.ListTurnos(ctx, filter)
+}
+
+func (r *repository) WithinTx(ctx context.Context, fn func(tx store.StoreInterface) error) error {
+	return r.storage.WithinTx(ctx, fn)
+}
+
+func (r *repository) View(ctx context.Context, fn func(v store.StoreInterface) error) error {
+	return r.storage.View(ctx, fn)
+}
@@ -1,71 +1,495 @@
 package turno
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/auditoria"
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/internal/odontologo"
+	"github.com/MechiBakker/BE3-FINAL/internal/paciente"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
 )
 
+// Service administra los turnos. Un turno no puede superponerse con otro
+// activo del mismo odontólogo ni del mismo paciente: las altas, cambios y
+// restauraciones que lo harían devuelven un domain.ErrConflict cuyo Detalle
+// es el turno con el que chocan. Tampoco puede quedar fuera del horario del
+// odontólogo ni caer en una de sus excepciones: las altas y los cambios de
+// fecha, duración u odontólogo que lo harían devuelven un
+// domain.ErrValidation. Cambiar la agenda no afecta a los turnos existentes.
+//
+// El estado de un turno sólo cambia con CambiarEstado, siguiendo
+// domain.EstadosSiguientes. Los turnos cancelados liberan su horario y los
+// que llegaron a un estado final ya no pueden modificarse.
 type Service interface {
-	GetTurnoByID(id int) (domain.Turno, error)
+	GetTurnoByID(ctx context.Context, id int) (domain.Turno, error)
+
+	// CreateTurno da de alta el turno. Sin DuracionMinutos dura
+	// domain.DuracionTurnoPorDefecto minutos.
+	CreateTurno(ctx context.Context, p domain.Turno) (domain.Turno, error)
+
+	// DeleteTurno da de baja el turno. Si version no es 0 debe coincidir con
+	// la versión guardada; si no, devuelve domain.ErrPrecondition.
+	DeleteTurno(ctx context.Context, id, version int) error
+
+	// RestoreTurno deshace la baja del turno. Su odontólogo y su paciente
+	// deben estar activos.
+	RestoreTurno(ctx context.Context, id int) (domain.Turno, error)
+
+	// UpdateTurno aplica los campos no vacíos de p, salvo el estado. Si
+	// p.Version no es 0 debe coincidir con la versión guardada; si no,
+	// devuelve domain.ErrPrecondition. Un turno de una serie no puede
+	// cambiar de paciente.
+	UpdateTurno(ctx context.Context, id int, p domain.Turno) (domain.Turno, error)
+
+	// CambiarEstado pasa el turno a estado. Si la transición no está
+	// permitida devuelve domain.ErrConflict; para cancelar hace falta un
+	// motivo. version se controla igual que en DeleteTurno.
+	CambiarEstado(ctx context.Context, id int, estado, motivo string, version int) (domain.Turno, error)
+
+	// Historial devuelve los estados por los que pasó el turno, con quién y
+	// cuándo hizo cada cambio, según la auditoría.
+	Historial(ctx context.Context, id int) ([]domain.TransicionTurno, error)
+
+	// ImportTurnos da de alta los turnos en una única transacción y devuelve
+	// los errores de cada uno por posición. Si alguno falla o dryRun es true
+	// no se guarda ninguno.
+	ImportTurnos(ctx context.Context, turnos []domain.Turno, dryRun bool) (map[int]error, error)
 
-	CreateTurno(p domain.Turno) (domain.Turno, error)
+	ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error)
 
-	DeleteTurno(id int) error
+	// ExportTurnos devuelve todos los turnos que cumplen filter, leídos de
+	// una misma vista; la página y el límite de filter se ignoran.
+	ExportTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, error)
 
-	UpdateTurno(id int, p domain.Turno) (domain.Turno, error)
+	// CreateTurnoConPaciente da de alta al paciente y le asigna su primer
+	// turno en una única transacción.
+	CreateTurnoConPaciente(ctx context.Context, p domain.Paciente, t domain.Turno) (domain.Paciente, domain.Turno, error)
+
+	// CreateTurnoPorDniMatricula da de alta el turno del paciente con DNI dni
+	// con el odontólogo de matrícula matricula. Si alguno no existe devuelve
+	// un domain.ErrNotFound que lo nombra.
+	CreateTurnoPorDniMatricula(ctx context.Context, dni, matricula string, t domain.Turno) (domain.Turno, error)
 }
 
 type service struct {
-	r Repository
+	r           Repository
+	odontologos odontologo.Service
+	pacientes   paciente.Service
+}
+
+// NewService crea el servicio de turnos. Con odontologos y pacientes verifica
+// que existan las referencias de cada turno antes de guardarlo.
+func NewService(r Repository, odontologos odontologo.Service, pacientes paciente.Service) Service {
+	return &service{r, odontologos, pacientes}
+}
+
+func (s *service) CreateTurno(ctx context.Context, p domain.Turno) (domain.Turno, error) {
+	if err := s.validarReferencias(ctx, p); err != nil {
+		return domain.Turno{}, err
+	}
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		p, err = CreateAuditado(ctx, tx, p)
+		return err
+	})
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return p, nil
+}
+
+func (s *service) GetTurnoByID(ctx context.Context, id int) (domain.Turno, error) {
+	p, err := s.r.GetTurnoByID(ctx, id)
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return p, nil
+}
+
+func (s *service) UpdateTurno(ctx context.Context, id int, u domain.Turno) (domain.Turno, error) {
+	if u.IdOdontologo != 0 {
+		if err := s.validarOdontologo(ctx, u.IdOdontologo); err != nil {
+			return domain.Turno{}, err
+		}
+	}
+	if u.IdPaciente != 0 {
+		if err := s.validarPaciente(ctx, u.IdPaciente); err != nil {
+			return domain.Turno{}, err
+		}
+	}
+	var p domain.Turno
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		p, err = UpdateAuditado(ctx, tx, id, u)
+		return err
+	})
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return p, nil
 }
 
-func NewService(r Repository) Service {
-	return &service{r}
+func (s *service) DeleteTurno(ctx context.Context, id, version int) error {
+	return s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		r := NewRepository(tx)
+		antes, err := r.GetTurnoByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := r.DeleteTurno(ctx, id, version); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionBaja, antes, nil)
+	})
 }
 
-func (s *service) CreateTurno(p domain.Turno) (domain.Turno, error) {
-	p, err := s.r.CreateTurno(p)
+func (s *service) RestoreTurno(ctx context.Context, id int) (domain.Turno, error) {
+	var p domain.Turno
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		if p, err = NewRepository(tx).RestoreTurno(ctx, id); err != nil {
+			return err
+		}
+		return registrar(ctx, tx, id, domain.AccionRestauracion, nil, p)
+	})
 	if err != nil {
 		return domain.Turno{}, err
 	}
 	return p, nil
 }
 
-func (s *service) GetTurnoByID(id int) (domain.Turno, error) {
-	p, err := s.r.GetTurnoByID(id)
+func (s *service) ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error) {
+	filter.Normalize()
+	return s.r.ListTurnos(ctx, filter)
+}
+
+func (s *service) ExportTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, error) {
+	var turnos []domain.Turno
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		var err error
+		turnos, err = domain.ListarTodo(filter.ListOptions, func(opts domain.ListOptions) ([]domain.Turno, int, error) {
+			filter.ListOptions = opts
+			return NewRepository(v).ListTurnos(ctx, filter)
+		})
+		return err
+	})
+	return turnos, err
+}
+
+func (s *service) CreateTurnoConPaciente(ctx context.Context, p domain.Paciente, t domain.Turno) (domain.Paciente, domain.Turno, error) {
+	if err := s.validarOdontologo(ctx, t.IdOdontologo); err != nil {
+		return domain.Paciente{}, domain.Turno{}, err
+	}
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		p, err = paciente.CreateAuditado(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+		t.IdPaciente = p.IdPaciente
+		t, err = CreateAuditado(ctx, tx, t)
+		return err
+	})
+	if err != nil {
+		return domain.Paciente{}, domain.Turno{}, err
+	}
+	return p, t, nil
+}
+
+func (s *service) CreateTurnoPorDniMatricula(ctx context.Context, dni, matricula string, t domain.Turno) (domain.Turno, error) {
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		o, err := odontologo.NewRepository(tx).GetByMatricula(ctx, matricula)
+		if err != nil {
+			return err
+		}
+		p, err := paciente.NewRepository(tx).GetPacienteByDni(ctx, dni)
+		if err != nil {
+			return err
+		}
+		t.IdOdontologo, t.IdPaciente = o.IdOdontologo, p.IdPaciente
+		t, err = CreateAuditado(ctx, tx, t)
+		return err
+	})
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return t, nil
+}
+
+func (s *service) CambiarEstado(ctx context.Context, id int, estado, motivo string, version int) (domain.Turno, error) {
+	var p domain.Turno
+	err := s.r.WithinTx(ctx, func(tx store.StoreInterface) error {
+		var err error
+		p, err = CambiarEstadoAuditado(ctx, tx, id, estado, motivo, version)
+		return err
+	})
 	if err != nil {
 		return domain.Turno{}, err
 	}
 	return p, nil
 }
 
-func (s *service) UpdateTurno(id int, u domain.Turno) (domain.Turno, error) {
-	p, err := s.r.GetTurnoByID(id)
+func (s *service) Historial(ctx context.Context, id int) ([]domain.TransicionTurno, error) {
+	historial := []domain.TransicionTurno{}
+	err := s.r.View(ctx, func(v store.StoreInterface) error {
+		if _, err := NewRepository(v).GetTurnoByID(ctx, id); err != nil {
+			return err
+		}
+		filter := domain.AuditoriaFilter{Entidad: domain.EntidadTurno, IdEntidad: id}
+		filter.Limit = domain.MaxLimit
+		var leidas int
+		for filter.Page = 1; ; filter.Page++ {
+			page, total, err := auditoria.NewRepository(v).List(ctx, filter)
+			if err != nil {
+				return err
+			}
+			for _, a := range page {
+				if t, ok := transicion(a); ok {
+					historial = append(historial, t)
+				}
+			}
+			leidas += len(page)
+			if len(page) == 0 || leidas >= total {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return historial, nil
+}
+
+// transicion interpreta una entrada de auditoría del turno. Sólo el alta y
+// los cambios de estado forman parte del historial. De los snapshots se leen
+// sólo el estado y el motivo, porque los más viejos guardaban otros campos
+// con otros tipos.
+func transicion(a domain.Auditoria) (domain.TransicionTurno, bool) {
+	var antes, despues struct {
+		Estado            string `json:"estado"`
+		MotivoCancelacion string `json:"motivoCancelacion"`
+	}
+	switch a.Accion {
+	case domain.AccionAlta:
+	case domain.AccionCambioEstado:
+		if json.Unmarshal(a.Antes, &antes) != nil {
+			return domain.TransicionTurno{}, false
+		}
+	default:
+		return domain.TransicionTurno{}, false
+	}
+	if json.Unmarshal(a.Despues, &despues) != nil {
+		return domain.TransicionTurno{}, false
+	}
+	t := domain.TransicionTurno{Fecha: a.Fecha, Actor: a.Actor, Hasta: domain.Turno{Estado: despues.Estado}.EstadoActual()}
+	if a.Accion == domain.AccionCambioEstado {
+		t.Desde = domain.Turno{Estado: antes.Estado}.EstadoActual()
+		if t.Hasta == domain.EstadoCancelado {
+			t.Motivo = despues.MotivoCancelacion
+		}
+	}
+	return t, true
+}
+
+// CreateAuditado da de alta el turno dentro de la transacción tx y registra
+// el alta en la auditoría.
+func CreateAuditado(ctx context.Context, tx store.StoreInterface, t domain.Turno) (domain.Turno, error) {
+	if err := validarDuracion(t); err != nil {
+		return domain.Turno{}, err
+	}
+	if err := validarSerie(ctx, tx, t); err != nil {
+		return domain.Turno{}, err
+	}
+	if t.DuracionMinutos == 0 {
+		t.DuracionMinutos = domain.DuracionTurnoPorDefecto
+	}
+	t.Estado, t.MotivoCancelacion = domain.EstadoSolicitado, ""
+	if err := validarAgenda(ctx, tx, t); err != nil {
+		return domain.Turno{}, err
+	}
+	t, err := NewRepository(tx).CreateTurno(ctx, t)
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return t, registrar(ctx, tx, t.IdTurno, domain.AccionAlta, nil, t)
+}
+
+// UpdateAuditado aplica u al turno id dentro de la transacción tx, como
+// Service.UpdateTurno, y registra la modificación en la auditoría. Un turno
+// de una serie no puede cambiar de paciente.
+func UpdateAuditado(ctx context.Context, tx store.StoreInterface, id int, u domain.Turno) (domain.Turno, error) {
+	if err := validarDuracion(u); err != nil {
+		return domain.Turno{}, err
+	}
+	r := NewRepository(tx)
+	antes, err := r.GetTurnoByID(ctx, id)
 	if err != nil {
 		return domain.Turno{}, err
 	}
+	if antes.Finalizado() {
+		return domain.Turno{}, domain.NewError(domain.ErrConflict, "El turno %d está %s y ya no puede modificarse", id, antes.EstadoActual())
+	}
+	if antes.IdSerie != 0 && u.IdPaciente != 0 && u.IdPaciente != antes.IdPaciente {
+		return domain.Turno{}, domain.NewError(domain.ErrValidation, "El turno %d es de la serie %d y no puede cambiar de paciente", id, antes.IdSerie)
+	}
+	p := antes
+	if u.Version != 0 {
+		// La actualización se condiciona a la versión que leyó el cliente
+		// y no a la recién leída.
+		p.Version = u.Version
+	}
 	if u.DescripcionTurno != "" {
 		p.DescripcionTurno = u.DescripcionTurno
 	}
-	if u.FechaTurno != "" {
+	if !u.FechaTurno.IsZero() {
 		p.FechaTurno = u.FechaTurno
 	}
-	if u.IdOdontologo != "" {
+	if u.DuracionMinutos != 0 {
+		p.DuracionMinutos = u.DuracionMinutos
+	}
+	if u.IdOdontologo != 0 {
 		p.IdOdontologo = u.IdOdontologo
 	}
-	if u.IdPaciente != "" {
+	if u.IdPaciente != 0 {
 		p.IdPaciente = u.IdPaciente
 	}
-	p, err = s.r.UpdateTurno(id, p)
+	if !p.FechaTurno.Time().Equal(antes.FechaTurno.Time()) || p.Duracion() != antes.Duracion() || p.IdOdontologo != antes.IdOdontologo {
+		if err := validarAgenda(ctx, tx, p); err != nil {
+			return domain.Turno{}, err
+		}
+	}
+	if p, err = r.UpdateTurno(ctx, id, p); err != nil {
+		return domain.Turno{}, err
+	}
+	return p, registrar(ctx, tx, id, domain.AccionModificacion, antes, p)
+}
+
+// CambiarEstadoAuditado pasa el turno id a estado dentro de la transacción
+// tx, como Service.CambiarEstado, y registra el cambio en la auditoría.
+func CambiarEstadoAuditado(ctx context.Context, tx store.StoreInterface, id int, estado, motivo string, version int) (domain.Turno, error) {
+	motivo = strings.TrimSpace(motivo)
+	if estado == domain.EstadoCancelado && motivo == "" {
+		return domain.Turno{}, domain.NewError(domain.ErrValidation, "Para cancelar un turno hay que indicar el motivo")
+	}
+	if utf8.RuneCountInString(motivo) > domain.MaxMotivoCancelacion {
+		return domain.Turno{}, domain.NewError(domain.ErrValidation, "El motivo no puede superar los %d caracteres", domain.MaxMotivoCancelacion)
+	}
+	r := NewRepository(tx)
+	antes, err := r.GetTurnoByID(ctx, id)
 	if err != nil {
 		return domain.Turno{}, err
 	}
-	return p, nil
+	if !antes.PuedePasarA(estado) {
+		return domain.Turno{}, domain.NewError(domain.ErrConflict, "El turno %d está %s y no puede pasar a %s", id, antes.EstadoActual(), estado)
+	}
+	p := antes
+	p.Version = version
+	p.Estado = estado
+	if estado == domain.EstadoCancelado {
+		p.MotivoCancelacion = motivo
+	}
+	if p, err = r.UpdateTurno(ctx, id, p); err != nil {
+		return domain.Turno{}, err
+	}
+	return p, registrar(ctx, tx, id, domain.AccionCambioEstado, antes, p)
 }
 
-func (s *service) DeleteTurno(id int) error {
-	err := s.r.DeleteTurno(id)
+// registrar agrega a la auditoría un movimiento sobre el turno id.
+func registrar(ctx context.Context, tx store.StoreInterface, id int, accion string, antes, despues interface{}) error {
+	return auditoria.NewRepository(tx).Registrar(ctx, domain.EntidadTurno, id, accion, antes, despues)
+}
+
+func (s *service) ImportTurnos(ctx context.Context, turnos []domain.Turno, dryRun bool) (map[int]error, error) {
+	// Las referencias se verifican antes de abrir la transacción, porque los
+	// servicios de odontólogos y pacientes leen fuera de ella.
+	errores := make(map[int]error)
+	for i, t := range turnos {
+		var domainErr *domain.Error
+		if err := s.validarReferencias(ctx, t); errors.As(err, &domainErr) {
+			errores[i] = err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	batch, err := store.Batch(ctx, s.r, turnos, dryRun || len(errores) > 0, func(tx store.StoreInterface, t domain.Turno) error {
+		_, err := CreateAuditado(ctx, tx, t)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, err := range batch {
+		if _, ok := errores[i]; !ok {
+			errores[i] = err
+		}
+	}
+	return errores, nil
+}
+
+// validarDuracion verifica que la duración del turno, si la indica, esté en
+// el rango admitido.
+func validarDuracion(t domain.Turno) error {
+	if t.DuracionMinutos < 0 || t.DuracionMinutos > domain.DuracionTurnoMaxima {
+		return domain.NewError(domain.ErrValidation, "La duración del turno debe estar entre 1 y %d minutos", domain.DuracionTurnoMaxima)
+	}
+	return nil
+}
+
+// validarAgenda verifica dentro de la transacción tx que el odontólogo
+// atienda durante todo el turno.
+func validarAgenda(ctx context.Context, tx store.StoreInterface, t domain.Turno) error {
+	agenda, err := odontologo.NewRepository(tx).GetAgenda(ctx, t.IdOdontologo)
 	if err != nil {
 		return err
 	}
+	return agenda.Admite(t)
+}
+
+// validarSerie verifica dentro de la transacción tx que la serie del turno,
+// si indica una, exista y sea del mismo paciente.
+func validarSerie(ctx context.Context, tx store.StoreInterface, t domain.Turno) error {
+	if t.IdSerie == 0 {
+		return nil
+	}
+	serie, err := tx.ReadSerie(ctx, t.IdSerie)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.NewError(domain.ErrForeignKey, "La serie %d no existe", t.IdSerie)
+	}
+	if err != nil {
+		return err
+	}
+	if serie.IdPaciente != t.IdPaciente {
+		return domain.NewError(domain.ErrValidation, "La serie %d es del paciente %d y no puede incluir turnos de otro paciente", t.IdSerie, serie.IdPaciente)
+	}
 	return nil
 }
+
+// validarReferencias verifica que existan el odontólogo y el paciente del
+// turno. Si falta alguno devuelve un domain.ErrForeignKey que lo nombra.
+func (s *service) validarReferencias(ctx context.Context, t domain.Turno) error {
+	if err := s.validarOdontologo(ctx, t.IdOdontologo); err != nil {
+		return err
+	}
+	return s.validarPaciente(ctx, t.IdPaciente)
+}
+
+func (s *service) validarOdontologo(ctx context.Context, id int) error {
+	_, err := s.odontologos.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.NewError(domain.ErrForeignKey, "El odontólogo %d no existe", id)
+	}
+	return err
+}
+
+func (s *service) validarPaciente(ctx context.Context, id int) error {
+	_, err := s.pacientes.GetPacienteByID(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.NewError(domain.ErrForeignKey, "El paciente %d no existe", id)
+	}
+	return err
+}
@@ -0,0 +1,270 @@
+// Package backup vuelca el contenido completo de un store en un archivo
+// versionado y con checksum, y lo carga en cualquier otro store
+// conservando IDs, versiones, bajas y la auditoría.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+// Formato identifica los archivos de backup y Version su estructura. Un
+// cambio incompatible en Archivo o en domain.Respaldo debe aumentar Version.
+const (
+	Formato = "turnos-odontologicos-backup"
+	Version = 1
+)
+
+// Archivo es el contenido de un backup. Checksum es el SHA-256 de Datos en
+// su forma compacta, de modo que el archivo puede reformatearse sin
+// invalidarlo.
+type Archivo struct {
+	Formato  string          `json:"formato"`
+	Version  int             `json:"version"`
+	Fecha    string          `json:"fecha"`
+	Origen   string          `json:"origen"`
+	Totales  Totales         `json:"totales"`
+	Checksum string          `json:"checksum"`
+	Datos    json.RawMessage `json:"datos"`
+}
+
+type Totales struct {
+	Odontologos int `json:"odontologos"`
+	Pacientes   int `json:"pacientes"`
+	Turnos      int `json:"turnos"`
+	Agendas     int `json:"agendas"`
+	Series      int `json:"series"`
+	Auditoria   int `json:"auditoria"`
+}
+
+func totales(r domain.Respaldo) Totales {
+	return Totales{
+		Odontologos: len(r.Odontologos),
+		Pacientes:   len(r.Pacientes),
+		Turnos:      len(r.Turnos),
+		Agendas:     len(r.Agendas),
+		Series:      len(r.Series),
+		Auditoria:   len(r.Auditoria),
+	}
+}
+
+// Dump lee todos los registros de s, activos y dados de baja, sobre una
+// vista de sólo lectura para obtener una foto consistente.
+func Dump(ctx context.Context, s store.StoreInterface) (domain.Respaldo, error) {
+	var r domain.Respaldo
+	err := s.View(ctx, func(v store.StoreInterface) error {
+		r = domain.Respaldo{}
+		for _, deleted := range []bool{false, true} {
+			odontologos, err := paginar(func(opts domain.ListOptions) ([]domain.Odontologo, int, error) {
+				opts.Deleted = deleted
+				return v.ListOdontologos(ctx, domain.OdontologoFilter{ListOptions: opts})
+			})
+			if err != nil {
+				return err
+			}
+			pacientes, err := paginar(func(opts domain.ListOptions) ([]domain.Paciente, int, error) {
+				opts.Deleted = deleted
+				return v.ListPacientes(ctx, domain.PacienteFilter{ListOptions: opts})
+			})
+			if err != nil {
+				return err
+			}
+			turnos, err := paginar(func(opts domain.ListOptions) ([]domain.Turno, int, error) {
+				opts.Deleted = deleted
+				return v.ListTurnos(ctx, domain.TurnoFilter{ListOptions: opts})
+			})
+			if err != nil {
+				return err
+			}
+			r.Odontologos = append(r.Odontologos, odontologos...)
+			r.Pacientes = append(r.Pacientes, pacientes...)
+			r.Turnos = append(r.Turnos, turnos...)
+		}
+		// Sólo se guardan las agendas configuradas.
+		for _, o := range r.Odontologos {
+			agenda, err := v.ReadAgenda(ctx, o.IdOdontologo)
+			if err != nil {
+				return err
+			}
+			if len(agenda.Horarios) > 0 || len(agenda.Excepciones) > 0 {
+				r.Agendas = append(r.Agendas, agenda)
+			}
+		}
+		var err error
+		r.Series, err = paginar(func(opts domain.ListOptions) ([]domain.Serie, int, error) {
+			return v.ListSeries(ctx, opts)
+		})
+		if err != nil {
+			return err
+		}
+		r.Auditoria, err = paginar(func(opts domain.ListOptions) ([]domain.Auditoria, int, error) {
+			return v.ListAuditoria(ctx, domain.AuditoriaFilter{ListOptions: opts})
+		})
+		return err
+	})
+	if err != nil {
+		return domain.Respaldo{}, err
+	}
+	sort.Slice(r.Odontologos, func(i, j int) bool { return r.Odontologos[i].IdOdontologo < r.Odontologos[j].IdOdontologo })
+	sort.Slice(r.Pacientes, func(i, j int) bool { return r.Pacientes[i].IdPaciente < r.Pacientes[j].IdPaciente })
+	sort.Slice(r.Turnos, func(i, j int) bool { return r.Turnos[i].IdTurno < r.Turnos[j].IdTurno })
+	sort.Slice(r.Agendas, func(i, j int) bool { return r.Agendas[i].IdOdontologo < r.Agendas[j].IdOdontologo })
+	return r, nil
+}
+
+// paginar recorre todas las páginas de un listado ordenado por ID.
+func paginar[T any](list func(opts domain.ListOptions) ([]T, int, error)) ([]T, error) {
+	return domain.ListarTodo(domain.ListOptions{}, list)
+}
+
+// Write escribe r en w como un Archivo. origen describe el store del que se
+// obtuvieron los datos.
+func Write(w io.Writer, r domain.Respaldo, origen string) error {
+	datos, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	archivo := Archivo{
+		Formato:  Formato,
+		Version:  Version,
+		Fecha:    time.Now().UTC().Format(time.RFC3339),
+		Origen:   origen,
+		Totales:  totales(r),
+		Checksum: checksum(datos),
+		Datos:    datos,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(archivo)
+}
+
+// Read lee un Archivo de rd, controla su formato, versión, checksum y
+// consistencia, y devuelve los datos que contiene.
+func Read(rd io.Reader) (Archivo, domain.Respaldo, error) {
+	var archivo Archivo
+	if err := json.NewDecoder(rd).Decode(&archivo); err != nil {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("el archivo no es un backup válido: %w", err)
+	}
+	if archivo.Formato != Formato {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("el archivo no es un backup de turnos (formato %q)", archivo.Formato)
+	}
+	if archivo.Version != Version {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("versión de backup %d no soportada (se esperaba %d)", archivo.Version, Version)
+	}
+	var compacto bytes.Buffer
+	if err := json.Compact(&compacto, archivo.Datos); err != nil {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("los datos del backup están dañados: %w", err)
+	}
+	if sum := checksum(compacto.Bytes()); sum != archivo.Checksum {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("el checksum no coincide: el archivo dice %s y los datos dan %s", archivo.Checksum, sum)
+	}
+	var r domain.Respaldo
+	if err := json.Unmarshal(compacto.Bytes(), &r); err != nil {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("los datos del backup están dañados: %w", err)
+	}
+	if t := totales(r); t != archivo.Totales {
+		return Archivo{}, domain.Respaldo{}, fmt.Errorf("los totales del backup no coinciden con sus datos")
+	}
+	if err := validar(r); err != nil {
+		return Archivo{}, domain.Respaldo{}, err
+	}
+	return archivo, r, nil
+}
+
+// Load carga r en s, que debe estar vacío.
+func Load(ctx context.Context, s store.StoreInterface, r domain.Respaldo) error {
+	if err := validar(r); err != nil {
+		return err
+	}
+	return s.Import(ctx, r)
+}
+
+func checksum(datos []byte) string {
+	sum := sha256.Sum256(datos)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// validar controla que los IDs sean únicos, que cada turno y cada serie
+// apunten a un odontólogo y un paciente del respaldo, que los turnos de una
+// serie la encuentren en el respaldo, que cada agenda sea válida y de un
+// odontólogo del respaldo y que la cadena de auditoría esté intacta.
+func validar(r domain.Respaldo) error {
+	odontologos := make(map[int]bool)
+	for _, o := range r.Odontologos {
+		if o.IdOdontologo < 1 || odontologos[o.IdOdontologo] {
+			return fmt.Errorf("backup inconsistente: ID de odontólogo %d inválido o repetido", o.IdOdontologo)
+		}
+		odontologos[o.IdOdontologo] = true
+	}
+	pacientes := make(map[int]bool)
+	for _, p := range r.Pacientes {
+		if p.IdPaciente < 1 || pacientes[p.IdPaciente] {
+			return fmt.Errorf("backup inconsistente: ID de paciente %d inválido o repetido", p.IdPaciente)
+		}
+		pacientes[p.IdPaciente] = true
+	}
+	series := make(map[int]bool)
+	for _, serie := range r.Series {
+		if serie.IdSerie < 1 || series[serie.IdSerie] {
+			return fmt.Errorf("backup inconsistente: ID de serie %d inválido o repetido", serie.IdSerie)
+		}
+		series[serie.IdSerie] = true
+		if !odontologos[serie.IdOdontologo] || !pacientes[serie.IdPaciente] {
+			return fmt.Errorf("backup inconsistente: la serie %d referencia a un odontólogo o paciente que no está en el backup", serie.IdSerie)
+		}
+	}
+	turnos := make(map[int]bool)
+	for _, t := range r.Turnos {
+		if t.IdTurno < 1 || turnos[t.IdTurno] {
+			return fmt.Errorf("backup inconsistente: ID de turno %d inválido o repetido", t.IdTurno)
+		}
+		turnos[t.IdTurno] = true
+		if !odontologos[t.IdOdontologo] {
+			return fmt.Errorf("backup inconsistente: el turno %d referencia al odontólogo %d, que no está en el backup", t.IdTurno, t.IdOdontologo)
+		}
+		if !pacientes[t.IdPaciente] {
+			return fmt.Errorf("backup inconsistente: el turno %d referencia al paciente %d, que no está en el backup", t.IdTurno, t.IdPaciente)
+		}
+		if t.IdSerie != 0 && !series[t.IdSerie] {
+			return fmt.Errorf("backup inconsistente: el turno %d referencia a la serie %d, que no está en el backup", t.IdTurno, t.IdSerie)
+		}
+	}
+	agendas := make(map[int]bool)
+	excepciones := make(map[int]bool)
+	for _, agenda := range r.Agendas {
+		if !odontologos[agenda.IdOdontologo] || agendas[agenda.IdOdontologo] {
+			return fmt.Errorf("backup inconsistente: la agenda del odontólogo %d está repetida o el odontólogo no está en el backup", agenda.IdOdontologo)
+		}
+		agendas[agenda.IdOdontologo] = true
+		if err := domain.ValidarHorarios(agenda.Horarios); err != nil {
+			return fmt.Errorf("backup inconsistente: agenda del odontólogo %d: %w", agenda.IdOdontologo, err)
+		}
+		for _, e := range agenda.Excepciones {
+			if e.IdExcepcion < 1 || excepciones[e.IdExcepcion] {
+				return fmt.Errorf("backup inconsistente: ID de excepción %d inválido o repetido", e.IdExcepcion)
+			}
+			excepciones[e.IdExcepcion] = true
+			if err := e.Validar(); err != nil {
+				return fmt.Errorf("backup inconsistente: excepción %d: %w", e.IdExcepcion, err)
+			}
+		}
+	}
+	anterior := ""
+	for i, a := range r.Auditoria {
+		if a.IdAuditoria != i+1 || a.HashAnterior != anterior || a.CalcularHash() != a.Hash {
+			return fmt.Errorf("backup inconsistente: la cadena de auditoría está rota en la entrada %d", a.IdAuditoria)
+		}
+		anterior = a.Hash
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Package config reúne la configuración del servidor. Load la arma en capas,
+// de menor a mayor prioridad: valores por defecto, archivo JSON, archivo
+// .env, variables de entorno y flags de la línea de comandos.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backends disponibles para Store.Backend.
+const (
+	BackendMySQL  = "mysql"
+	BackendSQLite = "sqlite"
+	BackendJSON   = "json"
+	BackendMemory = "memory"
+)
+
+// Niveles de log admitidos por Log.Level.
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+type Config struct {
+	Store  StoreConfig  `json:"store"`
+	DB     DBConfig     `json:"db"`
+	Server ServerConfig `json:"server"`
+	Auth   AuthConfig   `json:"auth"`
+	Cache  CacheConfig  `json:"cache"`
+	Log    LogConfig    `json:"log"`
+	Agenda AgendaConfig `json:"agenda"`
+
+	// Args son los argumentos que quedan después de los flags, por ejemplo
+	// el subcomando migrate.
+	Args []string `json:"-"`
+	// Warnings son avisos de Load que no impiden arrancar, como el uso de
+	// una variable de entorno obsoleta.
+	Warnings []string `json:"-"`
+}
+
+type StoreConfig struct {
+	// Backend es mysql, sqlite, json o memory.
+	Backend string `json:"backend"`
+	// Path es el archivo de la base SQLite, del documento JSON o de los
+	// datos iniciales del store en memoria. Vacío usa el de cada backend.
+	Path         string   `json:"path"`
+	AutoMigrate  bool     `json:"autoMigrate"`
+	QueryTimeout Duration `json:"queryTimeout"`
+}
+
+type DBConfig struct {
+	Host            string   `json:"host"`
+	Port            int      `json:"port"`
+	User            string   `json:"user"`
+	Pass            string   `json:"pass"`
+	Name            string   `json:"name"`
+	MaxOpenConns    int      `json:"maxOpenConns"`
+	MaxIdleConns    int      `json:"maxIdleConns"`
+	ConnMaxLifetime Duration `json:"connMaxLifetime"`
+}
+
+type ServerConfig struct {
+	// Addr es la dirección en la que escucha el servidor, como ":8080".
+	Addr string `json:"addr"`
+	// Host es el host:puerto con el que los clientes llegan al servidor. Se
+	// usa en la documentación y en el log; vacío lo deduce de Addr.
+	Host           string   `json:"host"`
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+type AuthConfig struct {
+	// Token es el token único heredado; corresponde al actor "admin".
+	Token string `json:"token"`
+	// Tokens asocia cada actor con su token.
+	Tokens map[string]string `json:"tokens"`
+}
+
+type CacheConfig struct {
+	// TTL en 0 deshabilita el caché de lecturas.
+	TTL  Duration `json:"ttl"`
+	Size int      `json:"size"`
+}
+
+type LogConfig struct {
+	Level string `json:"level"`
+}
+
+type AgendaConfig struct {
+	// Granularidad separa los comienzos de los turnos libres que propone la
+	// búsqueda de disponibilidad cuando la consulta no indica otro paso.
+	Granularidad Duration `json:"granularidad"`
+	// Feriados son días AAAA-MM-DD en los que la búsqueda no propone turnos.
+	Feriados []string `json:"feriados"`
+}
+
+// Duration es un time.Duration que en el archivo de configuración se
+// escribe como texto, por ejemplo "5s".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duración inválida: %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("duración inválida: %q", s)
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Default devuelve la configuración que se usa si ninguna fuente indica
+// otra cosa.
+func Default() Config {
+	return Config{
+		Store: StoreConfig{
+			Backend:      BackendMySQL,
+			QueryTimeout: Duration{5 * time.Second},
+		},
+		DB: DBConfig{
+			Host:            "localhost",
+			Port:            3306,
+			User:            "root",
+			Pass:            "root",
+			Name:            "turnos_odontologia",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration{5 * time.Minute},
+		},
+		Server: ServerConfig{
+			Addr:           ":8080",
+			TrustedProxies: []string{"127.0.0.1"},
+		},
+		Cache: CacheConfig{Size: 1000},
+		Log:   LogConfig{Level: LevelInfo},
+		Agenda: AgendaConfig{
+			Granularidad: Duration{15 * time.Minute},
+		},
+	}
+}
+
+// PublicHost devuelve Server.Host o, si está vacío, lo deduce de
+// Server.Addr usando localhost cuando la dirección no indica un host.
+func (c Config) PublicHost() string {
+	if c.Server.Host != "" {
+		return c.Server.Host
+	}
+	host, port, err := net.SplitHostPort(c.Server.Addr)
+	if err != nil {
+		return c.Server.Addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Actors devuelve el actor que corresponde a cada token aceptado.
+func (c Config) Actors() map[string]string {
+	actors := make(map[string]string, len(c.Auth.Tokens)+1)
+	for actor, token := range c.Auth.Tokens {
+		actors[token] = actor
+	}
+	if c.Auth.Token != "" {
+		if _, ok := actors[c.Auth.Token]; !ok {
+			actors[c.Auth.Token] = "admin"
+		}
+	}
+	return actors
+}
+
+// Validate controla que la configuración sea utilizable y devuelve todos
+// los problemas encontrados juntos.
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Store.Backend {
+	case BackendMySQL, BackendSQLite, BackendJSON, BackendMemory:
+	default:
+		add("store.backend: %q no es un backend válido (mysql, sqlite, json o memory)", c.Store.Backend)
+	}
+	if c.Store.QueryTimeout.Duration < 0 {
+		add("store.queryTimeout: no puede ser negativo")
+	}
+
+	if c.Store.Backend == BackendMySQL {
+		if c.DB.Host == "" {
+			add("db.host: no puede estar vacío")
+		}
+		if c.DB.Port < 1 || c.DB.Port > 65535 {
+			add("db.port: %d está fuera de rango", c.DB.Port)
+		}
+		if c.DB.Name == "" {
+			add("db.name: no puede estar vacío")
+		}
+	}
+	if c.DB.MaxOpenConns < 0 {
+		add("db.maxOpenConns: no puede ser negativo")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		add("db.maxIdleConns: no puede ser negativo")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		add("db.maxIdleConns: %d supera a db.maxOpenConns (%d)", c.DB.MaxIdleConns, c.DB.MaxOpenConns)
+	}
+	if c.DB.ConnMaxLifetime.Duration < 0 {
+		add("db.connMaxLifetime: no puede ser negativo")
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		add("server.addr: %q no es una dirección host:puerto", c.Server.Addr)
+	} else if _, err := strconv.Atoi(port); err != nil {
+		add("server.addr: el puerto %q no es numérico", port)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				add("server.trustedProxies: %q no es una IP ni un rango CIDR", proxy)
+			}
+		}
+	}
+
+	seen := make(map[string]string)
+	for actor, token := range c.Auth.Tokens {
+		switch {
+		case strings.TrimSpace(actor) == "":
+			add("auth.tokens: hay un token sin actor")
+		case token == "":
+			add("auth.tokens: el actor %q no tiene token", actor)
+		case seen[token] != "":
+			add("auth.tokens: %q y %q comparten el mismo token", seen[token], actor)
+		default:
+			seen[token] = actor
+		}
+	}
+
+	if c.Cache.TTL.Duration < 0 {
+		add("cache.ttl: no puede ser negativo")
+	}
+	if c.Cache.Size < 1 {
+		add("cache.size: debe ser mayor que 0")
+	}
+
+	switch c.Log.Level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+	default:
+		add("log.level: %q no es un nivel válido (debug, info, warn o error)", c.Log.Level)
+	}
+
+	if g := c.Agenda.Granularidad.Duration; g < time.Minute || g > 24*time.Hour || g%time.Minute != 0 {
+		add("agenda.granularidad: debe ser una cantidad entera de minutos entre 1m y 24h")
+	}
+	for _, feriado := range c.Agenda.Feriados {
+		if _, err := time.Parse("2006-01-02", feriado); err != nil {
+			add("agenda.feriados: %q no es una fecha AAAA-MM-DD", feriado)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("configuración inválida:\n%w", errors.Join(errs...))
+}
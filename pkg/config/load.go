@@ -0,0 +1,277 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting es un valor que se puede indicar por .env, entorno o flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"STORE_BACKEND", "store", "backend de datos: mysql, sqlite, json o memory", stringVar(func(c *Config) *string { return &c.Store.Backend })},
+	{"STORE_PATH", "store-path", "archivo de datos del backend", stringVar(func(c *Config) *string { return &c.Store.Path })},
+	{"AUTO_MIGRATE", "auto-migrate", "aplica las migraciones pendientes al iniciar: true o false", boolVar(func(c *Config) *bool { return &c.Store.AutoMigrate })},
+	{"QUERY_TIMEOUT", "query-timeout", "tiempo máximo de cada consulta, por ejemplo 5s", durationVar(func(c *Config) *Duration { return &c.Store.QueryTimeout })},
+	{"DB_HOST", "db-host", "host de MySQL", stringVar(func(c *Config) *string { return &c.DB.Host })},
+	{"DB_PORT", "db-port", "puerto de MySQL", intVar(func(c *Config) *int { return &c.DB.Port })},
+	{"DB_USER", "db-user", "usuario de MySQL", stringVar(func(c *Config) *string { return &c.DB.User })},
+	{"DB_PASS", "db-pass", "contraseña de MySQL", stringVar(func(c *Config) *string { return &c.DB.Pass })},
+	{"DB_NAME", "db-name", "base de datos de MySQL", stringVar(func(c *Config) *string { return &c.DB.Name })},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "máximo de conexiones abiertas, 0 sin límite", intVar(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "máximo de conexiones ociosas", intVar(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "tiempo máximo de vida de una conexión", durationVar(func(c *Config) *Duration { return &c.DB.ConnMaxLifetime })},
+	{"SERVER_ADDR", "addr", "dirección en la que escucha el servidor", stringVar(func(c *Config) *string { return &c.Server.Addr })},
+	{"SERVER_HOST", "host", "host:puerto con el que los clientes llegan al servidor", stringVar(func(c *Config) *string { return &c.Server.Host })},
+	{"TRUSTED_PROXIES", "trusted-proxies", "IPs o rangos CIDR de los proxies confiables, separados por comas", listVar(func(c *Config) *[]string { return &c.Server.TrustedProxies })},
+	{"AUTH_TOKEN", "token", "token del actor admin", stringVar(func(c *Config) *string { return &c.Auth.Token })},
+	{"AUTH_TOKENS", "tokens", "pares actor:token separados por comas", tokensVar},
+	{"CACHE_TTL", "cache-ttl", "duración del caché de lecturas, 0 lo deshabilita", durationVar(func(c *Config) *Duration { return &c.Cache.TTL })},
+	{"CACHE_SIZE", "cache-size", "máximo de registros en el caché", intVar(func(c *Config) *int { return &c.Cache.Size })},
+	{"LOG_LEVEL", "log-level", "nivel de log: debug, info, warn o error", stringVar(func(c *Config) *string { return &c.Log.Level })},
+	{"AGENDA_GRANULARIDAD", "agenda-granularidad", "separación entre los turnos libres propuestos", durationVar(func(c *Config) *Duration { return &c.Agenda.Granularidad })},
+	{"FERIADOS", "feriados", "días AAAA-MM-DD en los que la búsqueda de turnos libres no propone turnos, separados por comas", listVar(func(c *Config) *[]string { return &c.Agenda.Feriados })},
+}
+
+// obsoletas mapea las variables de versiones anteriores a la que las
+// reemplaza. Se siguen leyendo y Load avisa de su uso en Config.Warnings.
+var obsoletas = map[string]string{
+	"TOKEN": "AUTH_TOKEN",
+}
+
+// obsoletaDe devuelve la variable obsoleta que reemplaza env, o "".
+func obsoletaDe(env string) string {
+	for vieja, nueva := range obsoletas {
+		if nueva == env {
+			return vieja
+		}
+	}
+	return ""
+}
+
+// Load arma la configuración a partir de args (sin el nombre del programa)
+// y de las variables de entorno. El archivo JSON se indica con -config o
+// CONFIG_FILE y el .env con -env-file o ENV_FILE (por defecto ".env"; si no
+// existe se ignora). Una variable o un flag presente pisa a las capas
+// anteriores aunque esté vacío: vacía los textos y las listas y devuelve los
+// números, booleanos y duraciones a su valor por defecto. La configuración
+// resultante ya está validada.
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "archivo de configuración JSON")
+	envFile := fs.String("env-file", os.Getenv("ENV_FILE"), "archivo .env")
+	demo := fs.Bool("demo", false, "usa un store en memoria cargado con los datos de -fixtures")
+	fixtures := fs.String("fixtures", "odontologos.json", "archivo de datos iniciales para el modo demo")
+	flags := make(map[string]*string)
+	for _, s := range settings {
+		if s.flag != "" {
+			flags[s.flag] = fs.String(s.flag, "", s.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return Config{}, err
+		}
+		return Config{}, fmt.Errorf("flags: %w", err)
+	}
+
+	c := Default()
+	if *configFile != "" {
+		if err := loadFile(&c, *configFile); err != nil {
+			return Config{}, err
+		}
+	}
+
+	dotenv := make(map[string]string)
+	if *envFile != "" {
+		var err error
+		if dotenv, err = readDotenv(*envFile); err != nil {
+			return Config{}, err
+		}
+	} else if values, err := readDotenv(".env"); err == nil {
+		dotenv = values
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Config{}, err
+	}
+
+	var errs []error
+	apply := func(s setting, value, origen string) {
+		if err := s.set(&c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", origen, err))
+		}
+	}
+	for _, s := range settings {
+		// Dentro de cada capa la variable obsoleta va primero para que la
+		// nueva la pise.
+		keys := []string{s.env}
+		vieja := obsoletaDe(s.env)
+		if vieja != "" {
+			keys = []string{vieja, s.env}
+		}
+		for _, key := range keys {
+			if value, ok := dotenv[key]; ok {
+				apply(s, value, key+" (.env)")
+			}
+		}
+		for _, key := range keys {
+			if value, ok := os.LookupEnv(key); ok {
+				apply(s, value, key)
+			}
+		}
+		if _, ok := dotenv[vieja]; vieja != "" && ok {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s (.env) está obsoleta y se toma como %s", vieja, s.env))
+		}
+		if _, ok := os.LookupEnv(vieja); vieja != "" && ok {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s está obsoleta y se toma como %s", vieja, s.env))
+		}
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if s.flag != "" && set[s.flag] {
+			apply(s, *flags[s.flag], "-"+s.flag)
+		}
+	}
+	if len(errs) > 0 {
+		return Config{}, fmt.Errorf("configuración inválida:\n%w", errors.Join(errs...))
+	}
+
+	if *demo {
+		c.Store.Backend, c.Store.Path = BackendMemory, *fixtures
+	}
+	c.Args = fs.Args()
+	return c, c.Validate()
+}
+
+// Usage describe los flags y variables de entorno admitidos.
+func Usage() string {
+	var b strings.Builder
+	b.WriteString("flags: -config archivo.json, -env-file archivo, -demo, -fixtures archivo")
+	for _, s := range settings {
+		if s.flag != "" {
+			fmt.Fprintf(&b, ", -%s", s.flag)
+		}
+	}
+	b.WriteString("\nvariables de entorno: CONFIG_FILE, ENV_FILE")
+	for _, s := range settings {
+		fmt.Fprintf(&b, ", %s", s.env)
+	}
+	return b.String()
+}
+
+func loadFile(c *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("archivo de configuración: %w", err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("archivo de configuración %s: %w", path, err)
+	}
+	return nil
+}
+
+// porDefecto devuelve el campo a su valor de Default.
+func porDefecto[T any](c *Config, field func(c *Config) *T) {
+	d := Default()
+	*field(c) = *field(&d)
+}
+
+func stringVar(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = strings.TrimSpace(value)
+		return nil
+	}
+}
+
+func intVar(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		if strings.TrimSpace(value) == "" {
+			porDefecto(c, field)
+			return nil
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q no es un número entero", value)
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+func boolVar(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		if strings.TrimSpace(value) == "" {
+			porDefecto(c, field)
+			return nil
+		}
+		v, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q no es true ni false", value)
+		}
+		*field(c) = v
+		return nil
+	}
+}
+
+func durationVar(field func(c *Config) *Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		if strings.TrimSpace(value) == "" {
+			porDefecto(c, field)
+			return nil
+		}
+		v, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q no es una duración (por ejemplo \"5s\")", value)
+		}
+		*field(c) = Duration{v}
+		return nil
+	}
+}
+
+// listVar lee una lista separada por comas. Un valor vacío deja la lista
+// vacía.
+func listVar(field func(c *Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+// tokensVar lee pares "actor:token" separados por comas.
+func tokensVar(c *Config, value string) error {
+	tokens := make(map[string]string)
+	for _, par := range strings.Split(value, ",") {
+		if par = strings.TrimSpace(par); par == "" {
+			continue
+		}
+		actor, token, ok := strings.Cut(par, ":")
+		if !ok {
+			return fmt.Errorf("%q no tiene la forma actor:token", par)
+		}
+		tokens[strings.TrimSpace(actor)] = strings.TrimSpace(token)
+	}
+	c.Auth.Tokens = tokens
+	return nil
+}
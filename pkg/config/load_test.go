@@ -0,0 +1,167 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cargar llama a Load con un entorno que sólo tiene las variables de env, un
+// archivo JSON con archivo (si no está vacío) y un .env con dotenv.
+func cargar(t *testing.T, archivo, dotenv string, env map[string]string, args ...string) (Config, error) {
+	t.Helper()
+	for _, key := range []string{"CONFIG_FILE", "ENV_FILE", "TOKEN"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+		os.Unsetenv(s.env)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envFile, []byte(dotenv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args = append([]string{"-env-file", envFile}, args...)
+	if archivo != "" {
+		configFile := filepath.Join(dir, "config.json")
+		if err := os.WriteFile(configFile, []byte(archivo), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", configFile}, args...)
+	}
+	return Load(args)
+}
+
+func TestLoadCapas(t *testing.T) {
+	archivo := `{"db": {"host": "archivo", "port": 1, "user": "archivo", "name": "archivo"}}`
+	dotenv := "DB_PORT=2\nDB_HOST=dotenv\nDB_USER=\"dotenv\"\n"
+	env := map[string]string{"DB_PORT": "3", "DB_HOST": "entorno"}
+	c, err := cargar(t, archivo, dotenv, env, "-db-port", "4", "migrate", "up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperado := DBConfig{Host: "entorno", Port: 4, User: "dotenv", Name: "archivo"}
+	if c.DB.Host != esperado.Host || c.DB.Port != esperado.Port || c.DB.User != esperado.User || c.DB.Name != esperado.Name {
+		t.Errorf("se obtuvo %+v, se esperaba %+v", c.DB, esperado)
+	}
+	if c.DB.Pass != Default().DB.Pass {
+		t.Errorf("un valor que no indica ninguna capa debería quedar por defecto: %q", c.DB.Pass)
+	}
+	if !reflect.DeepEqual(c.Args, []string{"migrate", "up"}) {
+		t.Errorf("Args = %v", c.Args)
+	}
+}
+
+func TestLoadValorVacio(t *testing.T) {
+	archivo := `{
+		"store": {"queryTimeout": "1s"},
+		"db": {"pass": "secreta"},
+		"server": {"trustedProxies": ["10.0.0.1"]},
+		"cache": {"size": 5},
+		"agenda": {"feriados": ["2030-01-01"]}
+	}`
+	env := map[string]string{"TRUSTED_PROXIES": "", "CACHE_SIZE": " "}
+	c, err := cargar(t, archivo, "DB_PASS=\n", env, "-query-timeout=", "-feriados", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Server.TrustedProxies) != 0 || len(c.Agenda.Feriados) != 0 {
+		t.Errorf("las listas vacías deberían pisar al archivo: %v %v", c.Server.TrustedProxies, c.Agenda.Feriados)
+	}
+	if c.DB.Pass != "" {
+		t.Errorf("un texto vacío debería pisar al archivo: %q", c.DB.Pass)
+	}
+	if c.Cache.Size != Default().Cache.Size || c.Store.QueryTimeout != Default().Store.QueryTimeout {
+		t.Errorf("los números y duraciones vacíos deberían volver al valor por defecto: %d %s", c.Cache.Size, c.Store.QueryTimeout)
+	}
+}
+
+func TestLoadVariableObsoleta(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		dotenv   string
+		env      map[string]string
+		token    string
+		warnings int
+	}{
+		{"sin la variable obsoleta", "", map[string]string{"AUTH_TOKEN": "nuevo"}, "nuevo", 0},
+		{"sólo la obsoleta", "", map[string]string{"TOKEN": "viejo"}, "viejo", 1},
+		{"la nueva pisa a la obsoleta", "", map[string]string{"TOKEN": "viejo", "AUTH_TOKEN": "nuevo"}, "nuevo", 1},
+		{"la obsoleta en el .env", "TOKEN=viejo\n", nil, "viejo", 1},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			cfg, err := cargar(t, "", c.dotenv, c.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Auth.Token != c.token || len(cfg.Warnings) != c.warnings {
+				t.Fatalf("token %q con avisos %v, se esperaba %q con %d avisos", cfg.Auth.Token, cfg.Warnings, c.token, c.warnings)
+			}
+			if actor := cfg.Actors()[c.token]; actor != "admin" {
+				t.Fatalf("el token debería corresponder al actor admin: %q", actor)
+			}
+		})
+	}
+}
+
+func TestLoadRechazaValoresInvalidos(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		archivo string
+		dotenv  string
+		env     map[string]string
+		args    []string
+		errores []string
+	}{
+		{"entero inválido", "", "", map[string]string{"DB_PORT": "tres"}, nil, []string{"DB_PORT: \"tres\" no es un número entero"}},
+		{"duración sin unidad en el .env", "", "CACHE_TTL=5\n", nil, nil, []string{"CACHE_TTL (.env)"}},
+		{"booleano inválido en un flag", "", "", nil, []string{"-auto-migrate", "quizás"}, []string{"-auto-migrate"}},
+		{"tokens sin actor", "", "", map[string]string{"AUTH_TOKENS": "abc"}, nil, []string{"actor:token"}},
+		{"reporta todos los errores juntos", "", "", map[string]string{"DB_PORT": "x", "CACHE_SIZE": "y"}, nil, []string{"DB_PORT", "CACHE_SIZE"}},
+		{"campo desconocido en el archivo", `{"db": {"puerto": 1}}`, "", nil, nil, []string{"archivo de configuración"}},
+		{"línea mal formada en el .env", "", "DB_PORT\n", nil, nil, []string{".env:1"}},
+		{"flag desconocido", "", "", nil, []string{"-puerto", "1"}, []string{"flags"}},
+		{"backend inválido", "", "", nil, []string{"-store", "oracle"}, []string{"store.backend"}},
+		{"el archivo no pasa la validación", `{"db": {"port": 70000}}`, "", nil, nil, []string{"db.port"}},
+		{"proxy inválido", "", "", map[string]string{"TRUSTED_PROXIES": "10.0.0.1, proxy"}, nil, []string{"\"proxy\" no es una IP"}},
+		{"tokens repetidos", "", "", map[string]string{"AUTH_TOKENS": "ana:x, juan:x"}, nil, []string{"comparten el mismo token"}},
+		{"granularidad sin minutos enteros", "", "", nil, []string{"-agenda-granularidad", "90s"}, []string{"agenda.granularidad"}},
+		{"feriado mal formado", "", "", map[string]string{"FERIADOS": "01/01/2030"}, nil, []string{"agenda.feriados"}},
+		{"conexiones ociosas de más", "", "", map[string]string{"DB_MAX_OPEN_CONNS": "5", "DB_MAX_IDLE_CONNS": "10"}, nil, []string{"db.maxIdleConns"}},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			_, err := cargar(t, c.archivo, c.dotenv, c.env, c.args...)
+			if err == nil {
+				t.Fatal("se esperaba un error")
+			}
+			for _, e := range c.errores {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("el error %q no menciona %q", err, e)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDemo(t *testing.T) {
+	c, err := cargar(t, "", "", map[string]string{"STORE_BACKEND": "sqlite"}, "-demo", "-fixtures", "datos.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Store.Backend != BackendMemory || c.Store.Path != "datos.json" {
+		t.Fatalf("-demo debería usar el store en memoria con los fixtures: %+v", c.Store)
+	}
+	if c.Agenda.Granularidad.Duration != 15*time.Minute {
+		t.Fatalf("Granularidad = %s", c.Agenda.Granularidad)
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"time"
 )
 
-func Logger() gin.HandlerFunc {
+// Logger imprime cada petición; host es el host:puerto público del servidor.
+func Logger(host string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		time := time.Now()
 		path := c.Request.URL.Path
@@ -18,6 +19,6 @@ func Logger() gin.HandlerFunc {
 		if c.Writer != nil {
 			size = c.Writer.Size()
 		}
-		fmt.Printf("time: %v\npath: localhost:8080%s\nverb: %s\nsize: %d\n", time, path, verb, size)
+		fmt.Printf("time: %v\npath: %s%s\nverb: %s\nsize: %d\n", time, host, path, verb, size)
 	}
 }
@@ -2,12 +2,16 @@ package middleware
 
 import (
 	"errors"
-	"github.com/gin-gonic/gin"
+	"strconv"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/MechiBakker/BE3-FINAL/pkg/web"
-	"os"
+	"github.com/gin-gonic/gin"
 )
 
-func Authentication() gin.HandlerFunc {
+// Authentication acepta los tokens de actors, que asocia cada token con el
+// actor que queda registrado en el contexto de la petición.
+func Authentication(actors map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("TOKEN")
 		if token == "" {
@@ -15,11 +19,27 @@ func Authentication() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		if token != os.Getenv("TOKEN") {
+		actor, ok := actors[token]
+		if !ok {
 			web.Failure(c, 401, errors.New("invalid token"))
 			c.Abort()
 			return
 		}
+		c.Request = c.Request.WithContext(domain.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
+
+// AuthenticationForDeleted exige los tokens de actors sólo a las peticiones
+// que piden los registros dados de baja (deleted=true). El resto del listado
+// sigue siendo público.
+func AuthenticationForDeleted(actors map[string]string) gin.HandlerFunc {
+	auth := Authentication(actors)
+	return func(c *gin.Context) {
+		if deleted, _ := strconv.ParseBool(c.Query("deleted")); deleted {
+			auth(c)
+			return
+		}
 		c.Next()
 	}
 }
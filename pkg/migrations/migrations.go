@@ -0,0 +1,289 @@
+// Package migrations aplica las migraciones de esquema embebidas en el
+// binario. Cada dialecto tiene su propio directorio con archivos
+// NNNN_nombre.up.sql y NNNN_nombre.down.sql que se aplican en orden de
+// versión y quedan registrados en la tabla schema_migrations.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// Dialectos soportados; coinciden con los nombres de driver de database/sql.
+const (
+	MySQL  = "mysql"
+	SQLite = "sqlite3"
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration es una versión del esquema con sus sentencias de ida y vuelta.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// Status describe el estado de una migración en la base.
+type Status struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"appliedAt,omitempty"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// New devuelve un Migrator para db con las migraciones del dialecto dado.
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+func load(dialect string) ([]Migration, error) {
+	var dir string
+	switch dialect {
+	case MySQL:
+		dir = "mysql"
+	case SQLite:
+		dir = "sqlite"
+	default:
+		return nil, fmt.Errorf("dialecto de migraciones desconocido: %q", dialect)
+	}
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("nombre de migración inválido: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		content, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if m[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("la migración %04d_%s debe tener archivos up y down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest devuelve la versión de esquema que espera el código.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INT NOT NULL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  applied_at VARCHAR(32) NOT NULL
+);`)
+	return err
+}
+
+// applied devuelve las versiones ya aplicadas con su fecha de aplicación.
+func (m *Migrator) applied() (map[int]string, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Current devuelve la mayor versión aplicada, o 0 si no hay ninguna.
+func (m *Migrator) Current() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for version := range applied {
+		current = max(current, version)
+	}
+	return current, nil
+}
+
+// Status lista todas las migraciones conocidas indicando si están aplicadas.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		status = append(status, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+// Up aplica, en orden, todas las migraciones pendientes y devuelve las que
+// aplicó.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.run(migration.up, func(tx *sql.Tx) error {
+			_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?);",
+				migration.Version, migration.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migración %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down revierte las últimas steps migraciones aplicadas y devuelve las que
+// revirtió.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.run(migration.down, func(tx *sql.Tx) error {
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?;", migration.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migración %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Check devuelve un error si la base no tiene aplicadas todas las
+// migraciones que el código espera.
+func (m *Migrator) Check() error {
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+	if current < m.Latest() {
+		return fmt.Errorf("el esquema de la base está en la versión %d y el código requiere la %d; ejecutá \"migrate up\"", current, m.Latest())
+	}
+	return nil
+}
+
+// run ejecuta las sentencias de script y luego record en una transacción.
+// MySQL confirma implícitamente las sentencias DDL, por lo que allí la
+// transacción sólo protege el registro en schema_migrations.
+func (m *Migrator) run(script string, record func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range statements(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements separa un script en sentencias terminadas en ";" al final de
+// una línea. Las migraciones no deben usar ";" dentro de literales.
+func statements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS turnos;
+DROP TABLE IF EXISTS pacientes;
+DROP TABLE IF EXISTS odontologos;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS odontologos (
+  idOdontologo INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  nombreOdontologo VARCHAR(50) NOT NULL,
+  apellidoOdontologo VARCHAR(50) NOT NULL,
+  matriculaOdontologo VARCHAR(50) NOT NULL,
+  PRIMARY KEY (idOdontologo)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS pacientes (
+  idPaciente INT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT "id de la tabla pacientes",
+  nombrePaciente VARCHAR(50) NOT NULL,
+  apellidoPaciente VARCHAR(50) NOT NULL,
+  domicilioPaciente VARCHAR(50) NOT NULL,
+  dniPaciente VARCHAR(50) NOT NULL,
+  fechaDeAltaPaciente DATETIME NOT NULL,
+  PRIMARY KEY (idPaciente)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS turnos (
+  idTurno INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  descripcionTurno VARCHAR(150) NOT NULL,
+  fechaTurno DATETIME NOT NULL,
+  idOdontologo INT UNSIGNED NOT NULL,
+  idPaciente INT UNSIGNED NOT NULL,
+  PRIMARY KEY (idTurno),
+  KEY fk_odontologos_turnos(idOdontologo),
+  CONSTRAINT fk_odontologos_turnos FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  KEY fk_pacientes_turnos(idPaciente),
+  CONSTRAINT fk_pacientes_turnos FOREIGN KEY (idPaciente) REFERENCES pacientes(idPaciente)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
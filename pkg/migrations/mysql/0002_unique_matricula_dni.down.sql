@@ -0,0 +1,2 @@
+ALTER TABLE pacientes DROP INDEX uq_pacientes_dni;
+ALTER TABLE odontologos DROP INDEX uq_odontologos_matricula;
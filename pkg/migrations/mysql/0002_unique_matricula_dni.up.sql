@@ -0,0 +1,2 @@
+ALTER TABLE odontologos ADD UNIQUE KEY uq_odontologos_matricula (matriculaOdontologo);
+ALTER TABLE pacientes ADD UNIQUE KEY uq_pacientes_dni (dniPaciente);
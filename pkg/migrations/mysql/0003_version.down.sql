@@ -0,0 +1,3 @@
+ALTER TABLE turnos DROP COLUMN version;
+ALTER TABLE pacientes DROP COLUMN version;
+ALTER TABLE odontologos DROP COLUMN version;
@@ -0,0 +1,6 @@
+DELETE FROM turnos WHERE deleted_at IS NOT NULL;
+DELETE FROM pacientes WHERE deleted_at IS NOT NULL;
+DELETE FROM odontologos WHERE deleted_at IS NOT NULL;
+ALTER TABLE turnos DROP COLUMN deleted_at;
+ALTER TABLE pacientes DROP COLUMN deleted_at;
+ALTER TABLE odontologos DROP COLUMN deleted_at;
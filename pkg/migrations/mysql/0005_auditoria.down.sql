@@ -0,0 +1,2 @@
+DROP TABLE auditoria_cadena;
+DROP TABLE auditoria;
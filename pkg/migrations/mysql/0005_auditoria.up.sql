@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS auditoria (
+  idAuditoria INT UNSIGNED NOT NULL,
+  fecha VARCHAR(32) NOT NULL,
+  actor VARCHAR(100) NOT NULL,
+  entidad VARCHAR(30) NOT NULL,
+  idEntidad INT UNSIGNED NOT NULL,
+  accion VARCHAR(20) NOT NULL,
+  antes TEXT NULL,
+  despues TEXT NULL,
+  hashAnterior CHAR(64) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (idAuditoria),
+  KEY idx_auditoria_entidad (entidad, idEntidad)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- auditoria_cadena guarda el último eslabón de la cadena. Actualizarla
+-- bloquea su única fila y serializa las altas concurrentes.
+CREATE TABLE IF NOT EXISTS auditoria_cadena (
+  id INT UNSIGNED NOT NULL,
+  idAuditoria INT UNSIGNED NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+INSERT INTO auditoria_cadena (id, idAuditoria, hash) VALUES (1, 0, '');
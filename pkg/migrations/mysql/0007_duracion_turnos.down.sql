@@ -0,0 +1,3 @@
+ALTER TABLE turnos DROP INDEX idx_turnos_paciente_fecha;
+ALTER TABLE turnos DROP INDEX idx_turnos_odontologo_fecha;
+ALTER TABLE turnos DROP COLUMN duracionMinutos;
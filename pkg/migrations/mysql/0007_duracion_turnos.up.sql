@@ -0,0 +1,5 @@
+-- Los turnos existentes toman la duración por defecto. Los índices por
+-- odontólogo y por paciente agilizan la búsqueda de turnos superpuestos.
+ALTER TABLE turnos ADD COLUMN duracionMinutos INT NOT NULL DEFAULT 30;
+ALTER TABLE turnos ADD KEY idx_turnos_odontologo_fecha (idOdontologo, fechaTurno);
+ALTER TABLE turnos ADD KEY idx_turnos_paciente_fecha (idPaciente, fechaTurno);
@@ -0,0 +1,25 @@
+-- horarios guarda el horario semanal de cada odontólogo y excepciones los
+-- períodos en los que no atiende. Los horarios se reemplazan completos.
+CREATE TABLE IF NOT EXISTS horarios (
+  idOdontologo INT UNSIGNED NOT NULL,
+  dia TINYINT UNSIGNED NOT NULL,
+  desde CHAR(5) NOT NULL,
+  hasta CHAR(5) NOT NULL,
+  PRIMARY KEY (idOdontologo, dia, desde),
+  CONSTRAINT fk_odontologos_horarios FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE IF NOT EXISTS excepciones (
+  idExcepcion INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  idOdontologo INT UNSIGNED NOT NULL,
+  desde CHAR(10) NOT NULL,
+  hasta CHAR(10) NOT NULL,
+  motivo VARCHAR(150) NOT NULL,
+  PRIMARY KEY (idExcepcion),
+  KEY idx_excepciones_odontologo (idOdontologo, desde),
+  CONSTRAINT fk_odontologos_excepciones FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
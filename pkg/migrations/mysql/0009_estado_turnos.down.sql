@@ -0,0 +1,2 @@
+ALTER TABLE turnos DROP COLUMN motivoCancelacion;
+ALTER TABLE turnos DROP COLUMN estado;
@@ -0,0 +1,4 @@
+-- Los turnos existentes quedan solicitados. Las cancelaciones guardan su
+-- motivo; el resto de las transiciones queda en la auditoría.
+ALTER TABLE turnos ADD COLUMN estado VARCHAR(20) NOT NULL DEFAULT 'solicitado';
+ALTER TABLE turnos ADD COLUMN motivoCancelacion VARCHAR(150) NOT NULL DEFAULT '';
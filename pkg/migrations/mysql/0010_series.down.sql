@@ -0,0 +1,4 @@
+ALTER TABLE turnos DROP FOREIGN KEY fk_series_turnos;
+ALTER TABLE turnos DROP INDEX idx_turnos_serie;
+ALTER TABLE turnos DROP COLUMN idSerie;
+DROP TABLE series;
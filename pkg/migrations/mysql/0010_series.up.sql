@@ -0,0 +1,27 @@
+-- series guarda la regla de repetición de cada serie de turnos; los turnos
+-- que generó la referencian con idSerie. Los turnos sueltos la tienen en NULL.
+CREATE TABLE IF NOT EXISTS series (
+  idSerie INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  descripcionTurno VARCHAR(150) NOT NULL,
+  idOdontologo INT UNSIGNED NOT NULL,
+  idPaciente INT UNSIGNED NOT NULL,
+  fechaInicio DATETIME NOT NULL,
+  duracionMinutos INT NOT NULL,
+  frecuencia VARCHAR(10) NOT NULL,
+  intervalo INT NOT NULL,
+  cantidad INT NOT NULL,
+  hasta CHAR(10) NOT NULL,
+  PRIMARY KEY (idSerie),
+  CONSTRAINT fk_odontologos_series FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_pacientes_series FOREIGN KEY (idPaciente) REFERENCES pacientes(idPaciente)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+ALTER TABLE turnos ADD COLUMN idSerie INT UNSIGNED NULL;
+ALTER TABLE turnos ADD KEY idx_turnos_serie (idSerie, fechaTurno);
+ALTER TABLE turnos ADD CONSTRAINT fk_series_turnos FOREIGN KEY (idSerie) REFERENCES series(idSerie)
+  ON DELETE SET NULL
+  ON UPDATE CASCADE;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS odontologos (
+  idOdontologo INTEGER PRIMARY KEY AUTOINCREMENT,
+  nombreOdontologo VARCHAR(50) NOT NULL,
+  apellidoOdontologo VARCHAR(50) NOT NULL,
+  matriculaOdontologo VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pacientes (
+  idPaciente INTEGER PRIMARY KEY AUTOINCREMENT,
+  nombrePaciente VARCHAR(50) NOT NULL,
+  apellidoPaciente VARCHAR(50) NOT NULL,
+  domicilioPaciente VARCHAR(50) NOT NULL,
+  dniPaciente VARCHAR(50) NOT NULL,
+  fechaDeAltaPaciente DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS turnos (
+  idTurno INTEGER PRIMARY KEY AUTOINCREMENT,
+  descripcionTurno VARCHAR(150) NOT NULL,
+  fechaTurno DATETIME NOT NULL,
+  idOdontologo INTEGER NOT NULL,
+  idPaciente INTEGER NOT NULL,
+  CONSTRAINT fk_odontologos_turnos FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_pacientes_turnos FOREIGN KEY (idPaciente) REFERENCES pacientes(idPaciente)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS fk_odontologos_turnos ON turnos(idOdontologo);
+CREATE INDEX IF NOT EXISTS fk_pacientes_turnos ON turnos(idPaciente);
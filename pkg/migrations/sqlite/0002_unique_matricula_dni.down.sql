@@ -0,0 +1,2 @@
+DROP INDEX uq_pacientes_dni;
+DROP INDEX uq_odontologos_matricula;
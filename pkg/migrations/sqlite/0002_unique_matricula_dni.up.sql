@@ -0,0 +1,2 @@
+CREATE UNIQUE INDEX uq_odontologos_matricula ON odontologos(matriculaOdontologo);
+CREATE UNIQUE INDEX uq_pacientes_dni ON pacientes(dniPaciente);
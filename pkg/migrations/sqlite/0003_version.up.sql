@@ -0,0 +1,3 @@
+ALTER TABLE odontologos ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE pacientes ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE turnos ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+ALTER TABLE odontologos ADD COLUMN deleted_at VARCHAR(32) NULL;
+ALTER TABLE pacientes ADD COLUMN deleted_at VARCHAR(32) NULL;
+ALTER TABLE turnos ADD COLUMN deleted_at VARCHAR(32) NULL;
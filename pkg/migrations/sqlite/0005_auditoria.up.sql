@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS auditoria (
+  idAuditoria INTEGER PRIMARY KEY,
+  fecha VARCHAR(32) NOT NULL,
+  actor VARCHAR(100) NOT NULL,
+  entidad VARCHAR(30) NOT NULL,
+  idEntidad INTEGER NOT NULL,
+  accion VARCHAR(20) NOT NULL,
+  antes TEXT NULL,
+  despues TEXT NULL,
+  hashAnterior CHAR(64) NOT NULL,
+  hash CHAR(64) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_auditoria_entidad ON auditoria(entidad, idEntidad);
+
+-- auditoria_cadena guarda el último eslabón de la cadena. Actualizarla
+-- bloquea su única fila y serializa las altas concurrentes.
+CREATE TABLE IF NOT EXISTS auditoria_cadena (
+  id INTEGER PRIMARY KEY,
+  idAuditoria INTEGER NOT NULL,
+  hash CHAR(64) NOT NULL
+);
+
+INSERT INTO auditoria_cadena (id, idAuditoria, hash) VALUES (1, 0, '');
@@ -0,0 +1,2 @@
+-- El formato anterior de cada fecha no se conserva; las fechas normalizadas
+-- siguen siendo válidas para el esquema 0005.
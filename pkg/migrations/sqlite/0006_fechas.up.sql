@@ -0,0 +1,6 @@
+-- Las fechas guardadas como texto en distintos formatos pasan al que usa el
+-- driver para time.Time, de modo que las comparaciones por rango funcionen.
+UPDATE pacientes SET fechaDeAltaPaciente = strftime('%Y-%m-%d %H:%M:%S+00:00', fechaDeAltaPaciente)
+  WHERE strftime('%Y-%m-%d %H:%M:%S+00:00', fechaDeAltaPaciente) IS NOT NULL;
+UPDATE turnos SET fechaTurno = strftime('%Y-%m-%d %H:%M:%S+00:00', fechaTurno)
+  WHERE strftime('%Y-%m-%d %H:%M:%S+00:00', fechaTurno) IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Los turnos existentes toman la duración por defecto. Los índices por
+-- odontólogo y por paciente agilizan la búsqueda de turnos superpuestos.
+ALTER TABLE turnos ADD COLUMN duracionMinutos INT NOT NULL DEFAULT 30;
+CREATE INDEX idx_turnos_odontologo_fecha ON turnos(idOdontologo, fechaTurno);
+CREATE INDEX idx_turnos_paciente_fecha ON turnos(idPaciente, fechaTurno);
@@ -0,0 +1,2 @@
+DROP TABLE excepciones;
+DROP TABLE horarios;
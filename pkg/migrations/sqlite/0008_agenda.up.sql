@@ -0,0 +1,25 @@
+-- horarios guarda el horario semanal de cada odontólogo y excepciones los
+-- períodos en los que no atiende. Los horarios se reemplazan completos.
+CREATE TABLE IF NOT EXISTS horarios (
+  idOdontologo INTEGER NOT NULL,
+  dia INTEGER NOT NULL,
+  desde CHAR(5) NOT NULL,
+  hasta CHAR(5) NOT NULL,
+  PRIMARY KEY (idOdontologo, dia, desde),
+  CONSTRAINT fk_odontologos_horarios FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS excepciones (
+  idExcepcion INTEGER PRIMARY KEY AUTOINCREMENT,
+  idOdontologo INTEGER NOT NULL,
+  desde CHAR(10) NOT NULL,
+  hasta CHAR(10) NOT NULL,
+  motivo VARCHAR(150) NOT NULL,
+  CONSTRAINT fk_odontologos_excepciones FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_excepciones_odontologo ON excepciones(idOdontologo, desde);
@@ -0,0 +1,3 @@
+DROP INDEX idx_turnos_serie;
+ALTER TABLE turnos DROP COLUMN idSerie;
+DROP TABLE series;
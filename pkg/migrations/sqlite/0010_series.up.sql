@@ -0,0 +1,25 @@
+-- series guarda la regla de repetición de cada serie de turnos; los turnos
+-- que generó la referencian con idSerie. Los turnos sueltos la tienen en NULL.
+-- idSerie no lleva clave foránea porque SQLite no podría quitar la columna
+-- al revertir la migración; el store controla la referencia.
+CREATE TABLE IF NOT EXISTS series (
+  idSerie INTEGER PRIMARY KEY AUTOINCREMENT,
+  descripcionTurno VARCHAR(150) NOT NULL,
+  idOdontologo INTEGER NOT NULL,
+  idPaciente INTEGER NOT NULL,
+  fechaInicio DATETIME NOT NULL,
+  duracionMinutos INTEGER NOT NULL,
+  frecuencia VARCHAR(10) NOT NULL,
+  intervalo INTEGER NOT NULL,
+  cantidad INTEGER NOT NULL,
+  hasta CHAR(10) NOT NULL,
+  CONSTRAINT fk_odontologos_series FOREIGN KEY (idOdontologo) REFERENCES odontologos(idOdontologo)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE,
+  CONSTRAINT fk_pacientes_series FOREIGN KEY (idPaciente) REFERENCES pacientes(idPaciente)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+ALTER TABLE turnos ADD COLUMN idSerie INTEGER NULL;
+CREATE INDEX idx_turnos_serie ON turnos(idSerie, fechaTurno);
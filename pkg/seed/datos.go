@@ -0,0 +1,40 @@
+package seed
+
+var nombres = []string{
+	"Agustín", "Alejandro", "Ana", "Andrea", "Bautista", "Benjamín", "Camila", "Carla",
+	"Carlos", "Carmen", "Catalina", "Claudia", "Daniel", "Diego", "Emilia", "Facundo",
+	"Federico", "Florencia", "Francisco", "Gabriela", "Gonzalo", "Graciela", "Guadalupe", "Hernán",
+	"Ignacio", "Isabella", "Javier", "Joaquín", "Jorge", "Josefina", "Juan", "Julieta",
+	"Laura", "Lautaro", "Lucas", "Lucía", "Luis", "Malena", "Marcela", "María",
+	"Mariano", "Martina", "Martín", "Mateo", "Micaela", "Milagros", "Nicolás", "Norma",
+	"Pablo", "Patricia", "Paula", "Rocío", "Rodrigo", "Santiago", "Silvia", "Sofía",
+	"Tomás", "Valentina", "Valentín", "Verónica", "Victoria", "Ximena",
+}
+
+var apellidos = []string{
+	"Acosta", "Aguirre", "Álvarez", "Benítez", "Cabrera", "Castro", "Cingolani", "Díaz",
+	"Domínguez", "Donatti", "Fernández", "Flores", "García", "Giménez", "Gómez", "González",
+	"Gutiérrez", "Herrera", "Ledesma", "López", "Martínez", "Medina", "Miguez", "Molina",
+	"Morales", "Navarro", "Nuñez", "Ojeda", "Pereyra", "Pérez", "Quiroga", "Ramírez",
+	"Ríos", "Rodríguez", "Romero", "Rossi", "Ruiz", "Sánchez", "Silva", "Sosa",
+	"Suárez", "Torres", "Vázquez", "Villalba", "Ferrari", "Russo", "Bianchi", "Colombo",
+}
+
+var calles = []string{
+	"Av. Corrientes", "Av. Santa Fe", "Av. Rivadavia", "Av. Cabildo", "Av. San Martín", "Av. Belgrano",
+	"Av. 7", "Calle 12", "Calle 21", "Calle 76", "Bv. Oroño", "Bv. San Juan",
+	"Sarmiento", "Mitre", "Moreno", "Alsina", "Lavalle", "Tucumán",
+	"Maipú", "Esmeralda", "Güemes", "Las Heras", "Pueyrredón", "Urquiza",
+	"9 de Julio", "25 de Mayo", "Entre Ríos", "Independencia", "Colón", "Dorrego",
+}
+
+var ciudades = []string{
+	"CABA", "La Plata", "Córdoba", "Rosario", "Mendoza", "Mar del Plata",
+	"San Miguel de Tucumán", "Salta", "Santa Fe", "Neuquén", "Bahía Blanca", "Paraná",
+}
+
+var tratamientos = []string{
+	"Consulta", "Control", "Limpieza", "Blanqueamiento", "Endodoncia", "Tratamiento de conducto",
+	"Extracción", "Extracción de muela de juicio", "Arreglo de caries", "Ortodoncia", "Control de ortodoncia", "Implante",
+	"Control post quirúrgico", "Prótesis", "Radiografía panorámica", "Urgencia",
+}
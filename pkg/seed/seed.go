@@ -0,0 +1,312 @@
+// Package seed genera datos de prueba realistas: pacientes con nombres,
+// domicilios y DNI argentinos, odontólogos con matrícula única y turnos que
+// no se superponen. Con las mismas Options el resultado es siempre el mismo.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+// Los turnos ocupan franjas de Duracion, de lunes a viernes entre
+// HoraInicio y HoraFin.
+const (
+	Duracion   = 30 * time.Minute
+	HoraInicio = 9
+	HoraFin    = 18
+)
+
+// Rangos de los DNI y matrículas generados.
+const (
+	dniMinimo       = 10000000
+	dniMaximo       = 46000000
+	matriculaMinima = 10000
+	matriculaMaxima = 99999
+)
+
+// intentosUnico es la cantidad de valores al azar que prueba unico antes de
+// recorrer el rango buscando uno libre.
+const intentosUnico = 100
+
+// Options describe qué generar. Semilla inicializa el generador aleatorio;
+// Desde y Hasta son los días (inclusive) en los que caen los turnos. Las
+// altas de los pacientes son anteriores a Desde y, si se indica Hoy, no
+// posteriores a ese día.
+type Options struct {
+	Semilla     int64
+	Odontologos int
+	Pacientes   int
+	Turnos      int
+	Desde       time.Time
+	Hasta       time.Time
+	Hoy         time.Time
+}
+
+// Resultado informa cuántos registros se crearon.
+type Resultado struct {
+	Odontologos int
+	Pacientes   int
+	Turnos      int
+}
+
+// Run genera los datos de opts y los guarda en s en una única transacción,
+// por lo que un error no deja datos a medias. Los registros se crean con los
+// métodos de store.StoreInterface y reciben IDs nuevos, así que s puede tener
+// datos previos: los DNI y matrículas que ya existen no se repiten.
+func Run(ctx context.Context, s store.StoreInterface, opts Options) (Resultado, error) {
+	if err := opts.validar(); err != nil {
+		return Resultado{}, err
+	}
+	franjas := franjas(opts.Desde, opts.Hasta)
+	// Por franja no puede haber más turnos que odontólogos ni que pacientes.
+	sillas := min(opts.Odontologos, opts.Pacientes)
+	if capacidad := len(franjas) * sillas; opts.Turnos > capacidad {
+		return Resultado{}, fmt.Errorf("seed: entre %s y %s entran como máximo %d turnos para %d odontólogos y %d pacientes",
+			opts.Desde.Format("2006-01-02"), opts.Hasta.Format("2006-01-02"), capacidad, opts.Odontologos, opts.Pacientes)
+	}
+
+	g := generador{r: rand.New(rand.NewSource(opts.Semilla))}
+	var res Resultado
+	err := s.WithinTx(ctx, func(tx store.StoreInterface) error {
+		res = Resultado{}
+		var err error
+		if g.usados, err = usados(ctx, tx); err != nil {
+			return err
+		}
+		odontologos := make([]int, opts.Odontologos)
+		for i := range odontologos {
+			o, err := g.odontologo()
+			if err != nil {
+				return err
+			}
+			if o, err = tx.Create(ctx, o); err != nil {
+				return err
+			}
+			odontologos[i] = o.IdOdontologo
+			res.Odontologos++
+		}
+		altas := opts.Desde
+		if !opts.Hoy.IsZero() && opts.Hoy.Before(altas) {
+			altas = opts.Hoy
+		}
+		pacientes := make([]int, opts.Pacientes)
+		for i := range pacientes {
+			p, err := g.paciente(altas)
+			if err != nil {
+				return err
+			}
+			if p, err = tx.CreatePaciente(ctx, p); err != nil {
+				return err
+			}
+			pacientes[i] = p.IdPaciente
+			res.Pacientes++
+		}
+
+		// Cada turno ocupa una silla distinta de una franja. Las sillas de
+		// una franja se reparten entre odontólogos distintos y el paciente se
+		// elige entre los que no tienen otro turno en la misma franja.
+		asignacion := make(map[int][]int)
+		ocupados := make(map[[2]int]bool)
+		for _, n := range muestra(g.r, len(franjas)*sillas, opts.Turnos) {
+			franja, silla := n/sillas, n%sillas
+			if asignacion[franja] == nil {
+				asignacion[franja] = g.r.Perm(len(odontologos))
+			}
+			odontologo := asignacion[franja][silla]
+			paciente := g.r.Intn(len(pacientes))
+			for ocupados[[2]int{franja, paciente}] {
+				paciente = (paciente + 1) % len(pacientes)
+			}
+			ocupados[[2]int{franja, paciente}] = true
+			_, err := tx.CreateTurno(ctx, domain.Turno{
+				DescripcionTurno: elegir(g.r, tratamientos),
+				FechaTurno:       domain.Fecha(franjas[franja]),
+				DuracionMinutos:  int(Duracion / time.Minute),
+				IdOdontologo:     odontologos[odontologo],
+				IdPaciente:       pacientes[paciente],
+			})
+			if err != nil {
+				return err
+			}
+			res.Turnos++
+		}
+		return nil
+	})
+	if err != nil {
+		return Resultado{}, err
+	}
+	return res, nil
+}
+
+// usados devuelve las matrículas y los DNI que ya existen en s, incluidos
+// los de registros dados de baja, para no generarlos de nuevo.
+func usados(ctx context.Context, s store.StoreInterface) (map[string]bool, error) {
+	usados := make(map[string]bool)
+	for _, deleted := range []bool{false, true} {
+		opts := domain.ListOptions{Limit: domain.MaxLimit, Deleted: deleted}
+		for opts.Page = 1; ; opts.Page++ {
+			page, total, err := s.ListOdontologos(ctx, domain.OdontologoFilter{ListOptions: opts})
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range page {
+				usados["matricula:"+o.MatriculaOdontologo] = true
+			}
+			if len(page) == 0 || opts.Page*opts.Limit >= total {
+				break
+			}
+		}
+		for opts.Page = 1; ; opts.Page++ {
+			page, total, err := s.ListPacientes(ctx, domain.PacienteFilter{ListOptions: opts})
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range page {
+				usados["dni:"+p.DniPaciente] = true
+			}
+			if len(page) == 0 || opts.Page*opts.Limit >= total {
+				break
+			}
+		}
+	}
+	return usados, nil
+}
+
+func (o Options) validar() error {
+	var errs []error
+	if o.Odontologos < 0 || o.Pacientes < 0 || o.Turnos < 0 {
+		errs = append(errs, errors.New("las cantidades no pueden ser negativas"))
+	}
+	if o.Odontologos > matriculaMaxima-matriculaMinima+1 {
+		errs = append(errs, fmt.Errorf("no se pueden generar más de %d odontólogos", matriculaMaxima-matriculaMinima+1))
+	}
+	if o.Turnos > 0 && (o.Odontologos == 0 || o.Pacientes == 0) {
+		errs = append(errs, errors.New("para generar turnos hace falta al menos un odontólogo y un paciente"))
+	}
+	if o.Hasta.Before(o.Desde) {
+		errs = append(errs, errors.New("la fecha hasta es anterior a la fecha desde"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("seed: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// franjas devuelve el comienzo de cada franja de turnos entre desde y
+// hasta, en orden.
+func franjas(desde, hasta time.Time) []time.Time {
+	var out []time.Time
+	dia := time.Date(desde.Year(), desde.Month(), desde.Day(), 0, 0, 0, 0, time.UTC)
+	fin := time.Date(hasta.Year(), hasta.Month(), hasta.Day(), 0, 0, 0, 0, time.UTC)
+	for ; !dia.After(fin); dia = dia.AddDate(0, 0, 1) {
+		if dia.Weekday() == time.Saturday || dia.Weekday() == time.Sunday {
+			continue
+		}
+		for t := dia.Add(HoraInicio * time.Hour); t.Before(dia.Add(HoraFin * time.Hour)); t = t.Add(Duracion) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// muestra elige k números distintos de [0, n) y los devuelve ordenados. Es
+// un Fisher-Yates parcial que sólo guarda las posiciones intercambiadas, para
+// no reservar n enteros.
+func muestra(r *rand.Rand, n, k int) []int {
+	intercambios := make(map[int]int)
+	valor := func(i int) int {
+		if v, ok := intercambios[i]; ok {
+			return v
+		}
+		return i
+	}
+	out := make([]int, k)
+	for i := range out {
+		j := i + r.Intn(n-i)
+		out[i] = valor(j)
+		intercambios[j] = valor(i)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func elegir(r *rand.Rand, opciones []string) string {
+	return opciones[r.Intn(len(opciones))]
+}
+
+// generador arma registros al azar. usados evita repetir DNI y matrículas.
+type generador struct {
+	r      *rand.Rand
+	usados map[string]bool
+}
+
+// unico devuelve un valor entre minimo y maximo que no está en usados.
+// Prueba primero valores al azar y, si el rango está casi lleno, lo recorre
+// desde un punto al azar; si no queda ninguno libre devuelve un error.
+func (g *generador) unico(prefijo string, minimo, maximo int) (string, error) {
+	n := maximo - minimo + 1
+	libre := func(i int) (string, bool) {
+		valor := strconv.Itoa(minimo + i%n)
+		if g.usados[prefijo+valor] {
+			return "", false
+		}
+		g.usados[prefijo+valor] = true
+		return valor, true
+	}
+	for i := 0; i < intentosUnico; i++ {
+		if valor, ok := libre(g.r.Intn(n)); ok {
+			return valor, nil
+		}
+	}
+	inicio := g.r.Intn(n)
+	for i := 0; i < n; i++ {
+		if valor, ok := libre(inicio + i); ok {
+			return valor, nil
+		}
+	}
+	return "", fmt.Errorf("seed: no quedan valores de %s libres entre %d y %d", strings.TrimSuffix(prefijo, ":"), minimo, maximo)
+}
+
+func (g *generador) nombre() string {
+	nombre := elegir(g.r, nombres)
+	if g.r.Intn(4) == 0 {
+		if segundo := elegir(g.r, nombres); segundo != nombre {
+			nombre += " " + segundo
+		}
+	}
+	return nombre
+}
+
+func (g *generador) odontologo() (domain.Odontologo, error) {
+	o := domain.Odontologo{
+		NombreOdontologo:   g.nombre(),
+		ApellidoOdontologo: elegir(g.r, apellidos),
+	}
+	var err error
+	o.MatriculaOdontologo, err = g.unico("matricula:", matriculaMinima, matriculaMaxima)
+	return o, err
+}
+
+// paciente genera un paciente dado de alta en los diez años anteriores a
+// hasta.
+func (g *generador) paciente(hasta time.Time) (domain.Paciente, error) {
+	alta := hasta.AddDate(0, 0, -1-g.r.Intn(3650))
+	p := domain.Paciente{
+		NombrePaciente:      g.nombre(),
+		ApellidoPaciente:    elegir(g.r, apellidos),
+		DomicilioPaciente:   fmt.Sprintf("%s %d, %s", elegir(g.r, calles), 1+g.r.Intn(4999), elegir(g.r, ciudades)),
+		FechaDeAltaPaciente: domain.Fecha(alta),
+	}
+	var err error
+	p.DniPaciente, err = g.unico("dni:", dniMinimo, dniMaximo)
+	return p, err
+}
@@ -0,0 +1,72 @@
+package seed
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/MechiBakker/BE3-FINAL/pkg/store"
+)
+
+func dia(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// cargar corre Run sobre un store en memoria vacío y devuelve los pacientes
+// creados.
+func cargar(t *testing.T, opts Options) []domain.Paciente {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	if _, err := Run(ctx, s, opts); err != nil {
+		t.Fatal(err)
+	}
+	pacientes, _, err := s.ListPacientes(ctx, domain.PacienteFilter{ListOptions: domain.ListOptions{Page: 1, Limit: domain.MaxLimit}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pacientes
+}
+
+func TestRunEsDeterminista(t *testing.T) {
+	opts := Options{Semilla: 7, Odontologos: 3, Pacientes: 20, Turnos: 30, Desde: dia(t, "2090-03-04"), Hasta: dia(t, "2090-03-08")}
+	primera, segunda := cargar(t, opts), cargar(t, opts)
+	if !reflect.DeepEqual(primera, segunda) {
+		t.Fatal("con las mismas Options se generaron pacientes distintos")
+	}
+	for _, p := range primera {
+		if !p.FechaDeAltaPaciente.Time().Before(opts.Desde) {
+			t.Fatalf("alta %s no es anterior al primer turno", p.FechaDeAltaPaciente)
+		}
+	}
+
+	opts.Hoy = dia(t, "2030-01-01")
+	for _, p := range cargar(t, opts) {
+		if p.FechaDeAltaPaciente.Time().After(opts.Hoy) {
+			t.Fatalf("alta %s posterior a Hoy", p.FechaDeAltaPaciente)
+		}
+	}
+}
+
+func TestUnicoSinValoresLibres(t *testing.T) {
+	g := generador{r: rand.New(rand.NewSource(1)), usados: map[string]bool{}}
+	for _, v := range []string{"1", "2", "4", "5"} {
+		g.usados["m:"+v] = true
+	}
+	valor, err := g.unico("m:", 1, 5)
+	if err != nil || valor != "3" {
+		t.Fatalf("se esperaba el único valor libre y se obtuvo %q (%v)", valor, err)
+	}
+	if _, err := g.unico("m:", 1, 5); err == nil || !strings.Contains(err.Error(), "no quedan valores") {
+		t.Fatalf("con el rango lleno se esperaba un error y se obtuvo %v", err)
+	}
+}
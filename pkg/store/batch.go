@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// errDiscard hace que Batch descarte su transacción sin informar un error.
+var errDiscard = errors.New("transacción descartada")
+
+// Batch aplica fn a cada elemento de items dentro de una única transacción
+// de s. Los errores de domain se devuelven indexados por la posición del
+// elemento y no cortan el recorrido; cualquier otro error lo aborta. Si algún
+// elemento falló o dryRun es true, la transacción se descarta y no queda
+// nada guardado.
+func Batch[T any](ctx context.Context, s interface {
+	WithinTx(ctx context.Context, fn func(tx StoreInterface) error) error
+}, items []T, dryRun bool, fn func(tx StoreInterface, item T) error) (map[int]error, error) {
+	errores := make(map[int]error)
+	err := s.WithinTx(ctx, func(tx StoreInterface) error {
+		for i, item := range items {
+			err := fn(tx, item)
+			var domainErr *domain.Error
+			if errors.As(err, &domainErr) {
+				errores[i] = err
+			} else if err != nil {
+				return err
+			}
+		}
+		if dryRun || len(errores) > 0 {
+			return errDiscard
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDiscard) {
+		return nil, err
+	}
+	return errores, nil
+}
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// DefaultCacheSize es la cantidad de registros que guarda el caché si no se
+// indica otra.
+const DefaultCacheSize = 1000
+
+// CacheStats resume el uso del caché desde que se creó.
+type CacheStats struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	HitRatio  float64 `json:"hitRatio"`
+	Entries   int     `json:"entries"`
+	Capacity  int     `json:"capacity"`
+	TTL       string  `json:"ttl"`
+}
+
+// cacheKey identifica un registro cacheado. Con id 0 representa a todos los
+// registros de la entidad.
+type cacheKey struct {
+	entidad string
+	id      int
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   interface{}
+	expires time.Time
+}
+
+// cache es un LRU con vencimiento. gen cambia con cada invalidación para
+// descartar las lecturas que empezaron antes y terminan después de ella.
+type cache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	size  int
+	lru   *list.List
+	items map[cacheKey]*list.Element
+	gen   uint64
+	stats CacheStats
+}
+
+func (c *cache) get(key cacheKey) (interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			return entry.value, c.gen, true
+		}
+		c.remove(el)
+	}
+	c.stats.Misses++
+	return nil, c.gen, false
+}
+
+func (c *cache) put(key cacheKey, value interface{}, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	entry := &cacheEntry{key: key, value: value, expires: time.Now().Add(c.ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *cache) invalidate(keys ...cacheKey) {
+	if len(keys) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, key := range keys {
+		if key.id != 0 {
+			if el, ok := c.items[key]; ok {
+				c.remove(el)
+			}
+			continue
+		}
+		for k, el := range c.items {
+			if k.entidad == key.entidad {
+				c.remove(el)
+			}
+		}
+	}
+}
+
+func (c *cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// CacheStore envuelve a otro StoreInterface y guarda en memoria las lecturas
+// por ID durante ttl, hasta un máximo de size registros. Las escrituras
+// invalidan los registros que tocan; las bajas y restauraciones de
+// odontólogos y pacientes invalidan además todos los turnos, porque se
+// propagan a ellos. Los listados y la auditoría no se cachean.
+//
+// Dentro de WithinTx las lecturas van directo a la transacción y las
+// invalidaciones se aplican al terminar, para no publicar datos sin
+// confirmar. View pasa directo al store envuelto, porque el caché no
+// garantiza que todas las lecturas vean el mismo estado.
+type CacheStore struct {
+	StoreInterface
+	c *cache
+	// pending acumula las invalidaciones de la transacción en curso; es nil
+	// fuera de WithinTx.
+	pending *[]cacheKey
+}
+
+// NewCacheStore envuelve inner con un caché de lecturas. Con size <= 0 usa
+// DefaultCacheSize.
+func NewCacheStore(inner StoreInterface, ttl time.Duration, size int) *CacheStore {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &CacheStore{
+		StoreInterface: inner,
+		c: &cache{
+			ttl:   ttl,
+			size:  size,
+			lru:   list.New(),
+			items: make(map[cacheKey]*list.Element),
+		},
+	}
+}
+
+// Stats devuelve las estadísticas de uso del caché.
+func (s *CacheStore) Stats() CacheStats {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	stats := s.c.stats
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	stats.Entries = len(s.c.items)
+	stats.Capacity = s.c.size
+	stats.TTL = s.c.ttl.String()
+	return stats
+}
+
+// cachedRead devuelve el registro key del caché o lo lee con read y lo
+// guarda. Los errores no se cachean.
+func cachedRead[T any](s *CacheStore, key cacheKey, read func() (T, error)) (T, error) {
+	if s.pending != nil {
+		return read()
+	}
+	value, gen, ok := s.c.get(key)
+	if ok {
+		return value.(T), nil
+	}
+	v, err := read()
+	if err == nil {
+		s.c.put(key, v, gen)
+	}
+	return v, err
+}
+
+func (s *CacheStore) invalidate(keys ...cacheKey) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, keys...)
+		return
+	}
+	s.c.invalidate(keys...)
+}
+
+func (s *CacheStore) Read(ctx context.Context, id int) (domain.Odontologo, error) {
+	return cachedRead(s, cacheKey{domain.EntidadOdontologo, id}, func() (domain.Odontologo, error) {
+		return s.StoreInterface.Read(ctx, id)
+	})
+}
+
+func (s *CacheStore) Update(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	defer s.invalidate(cacheKey{domain.EntidadOdontologo, odontologo.IdOdontologo})
+	return s.StoreInterface.Update(ctx, odontologo)
+}
+
+func (s *CacheStore) Delete(ctx context.Context, id, version int) error {
+	defer s.invalidate(cacheKey{domain.EntidadOdontologo, id}, cacheKey{entidad: domain.EntidadTurno})
+	return s.StoreInterface.Delete(ctx, id, version)
+}
+
+func (s *CacheStore) Restore(ctx context.Context, id int) (domain.Odontologo, error) {
+	defer s.invalidate(cacheKey{domain.EntidadOdontologo, id}, cacheKey{entidad: domain.EntidadTurno})
+	return s.StoreInterface.Restore(ctx, id)
+}
+
+func (s *CacheStore) ReadPaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	return cachedRead(s, cacheKey{domain.EntidadPaciente, id}, func() (domain.Paciente, error) {
+		return s.StoreInterface.ReadPaciente(ctx, id)
+	})
+}
+
+func (s *CacheStore) UpdatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	defer s.invalidate(cacheKey{domain.EntidadPaciente, paciente.IdPaciente})
+	return s.StoreInterface.UpdatePaciente(ctx, paciente)
+}
+
+func (s *CacheStore) DeletePaciente(ctx context.Context, id, version int) error {
+	defer s.invalidate(cacheKey{domain.EntidadPaciente, id}, cacheKey{entidad: domain.EntidadTurno})
+	return s.StoreInterface.DeletePaciente(ctx, id, version)
+}
+
+func (s *CacheStore) RestorePaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	defer s.invalidate(cacheKey{domain.EntidadPaciente, id}, cacheKey{entidad: domain.EntidadTurno})
+	return s.StoreInterface.RestorePaciente(ctx, id)
+}
+
+func (s *CacheStore) ReadTurno(ctx context.Context, id int) (domain.Turno, error) {
+	return cachedRead(s, cacheKey{domain.EntidadTurno, id}, func() (domain.Turno, error) {
+		return s.StoreInterface.ReadTurno(ctx, id)
+	})
+}
+
+func (s *CacheStore) UpdateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	defer s.invalidate(cacheKey{domain.EntidadTurno, turno.IdTurno})
+	return s.StoreInterface.UpdateTurno(ctx, turno)
+}
+
+func (s *CacheStore) DeleteTurno(ctx context.Context, id, version int) error {
+	defer s.invalidate(cacheKey{domain.EntidadTurno, id})
+	return s.StoreInterface.DeleteTurno(ctx, id, version)
+}
+
+func (s *CacheStore) RestoreTurno(ctx context.Context, id int) (domain.Turno, error) {
+	defer s.invalidate(cacheKey{domain.EntidadTurno, id})
+	return s.StoreInterface.RestoreTurno(ctx, id)
+}
+
+func (s *CacheStore) Import(ctx context.Context, respaldo domain.Respaldo) error {
+	defer s.invalidate(cacheKey{entidad: domain.EntidadOdontologo}, cacheKey{entidad: domain.EntidadPaciente}, cacheKey{entidad: domain.EntidadTurno})
+	return s.StoreInterface.Import(ctx, respaldo)
+}
+
+func (s *CacheStore) WithinTx(ctx context.Context, fn func(tx StoreInterface) error) error {
+	pending := s.pending
+	if pending == nil {
+		pending = new([]cacheKey)
+		defer func() { s.c.invalidate(*pending...) }()
+	}
+	return s.StoreInterface.WithinTx(ctx, func(tx StoreInterface) error {
+		return fn(&CacheStore{StoreInterface: tx, c: s.c, pending: pending})
+	})
+}
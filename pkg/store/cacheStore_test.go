@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// nuevoCache envuelve con un caché un store en memoria con tres odontólogos,
+// tres pacientes y un turno del odontólogo 1. Devuelve también el store
+// envuelto para escribir sin pasar por el caché.
+func nuevoCache(t *testing.T, ttl time.Duration, size int) (*CacheStore, StoreInterface) {
+	t.Helper()
+	inner := nuevoStore(t, 3)
+	if _, err := inner.CreateTurno(context.Background(), domain.Turno{DescripcionTurno: "Control", FechaTurno: fecha(t, "2030-03-04 10:00"), IdOdontologo: 1, IdPaciente: 1}); err != nil {
+		t.Fatal(err)
+	}
+	return NewCacheStore(inner, ttl, size), inner
+}
+
+func leerApellido(t *testing.T, s StoreInterface, id int) string {
+	t.Helper()
+	o, err := s.Read(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o.ApellidoOdontologo
+}
+
+func renombrar(t *testing.T, s StoreInterface, id int, apellido string) {
+	t.Helper()
+	ctx := context.Background()
+	o, err := s.Read(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sin versión, para no depender de que la lectura esté al día.
+	o.ApellidoOdontologo, o.Version = apellido, 0
+	if _, err := s.Update(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheStoreLecturas(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		ttl       time.Duration
+		size      int
+		lecturas  []int
+		hits      uint64
+		misses    uint64
+		evictions uint64
+		entries   int
+	}{
+		{"la segunda lectura sale del caché", time.Minute, 10, []int{1, 1, 2, 1}, 2, 2, 0, 2},
+		{"los registros vencidos se vuelven a leer", 0, 10, []int{1, 1}, 0, 2, 0, 1},
+		{"descarta el menos usado al llenarse", time.Minute, 1, []int{1, 2, 1}, 0, 3, 2, 1},
+		{"mantiene el usado más recientemente", time.Minute, 2, []int{1, 2, 1, 3, 1, 2}, 2, 4, 2, 2},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			s, _ := nuevoCache(t, c.ttl, c.size)
+			for _, id := range c.lecturas {
+				if _, err := s.Read(context.Background(), id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stats := s.Stats()
+			if stats.Hits != c.hits || stats.Misses != c.misses || stats.Evictions != c.evictions || stats.Entries != c.entries {
+				t.Fatalf("se obtuvo %+v, se esperaban %d hits, %d misses, %d evictions y %d entries", stats, c.hits, c.misses, c.evictions, c.entries)
+			}
+		})
+	}
+}
+
+func TestCacheStoreNoCacheaErrores(t *testing.T) {
+	s, _ := nuevoCache(t, time.Minute, 10)
+	for i := 0; i < 2; i++ {
+		if _, err := s.Read(context.Background(), 99); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("se esperaba ErrNotFound y se obtuvo %v", err)
+		}
+	}
+	if stats := s.Stats(); stats.Hits != 0 || stats.Entries != 0 {
+		t.Fatalf("un error no debería quedar en el caché: %+v", stats)
+	}
+}
+
+func TestCacheStoreInvalidaAlEscribir(t *testing.T) {
+	ctx := context.Background()
+	s, inner := nuevoCache(t, time.Minute, 10)
+
+	leerApellido(t, s, 1)
+	renombrar(t, inner, 1, "Directo")
+	if got := leerApellido(t, s, 1); got != "Pérez" {
+		t.Fatalf("una escritura que no pasa por el caché no debería verse todavía: %q", got)
+	}
+	renombrar(t, s, 1, "López")
+	if got := leerApellido(t, s, 1); got != "López" {
+		t.Fatalf("después de Update se leyó %q", got)
+	}
+
+	if _, err := s.ReadTurno(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(ctx, 1); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("odontólogo dado de baja: se esperaba ErrNotFound y se obtuvo %v", err)
+	}
+	if _, err := s.ReadTurno(ctx, 1); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("la baja del odontólogo debería invalidar sus turnos: se obtuvo %v", err)
+	}
+}
+
+func TestCacheDescartaLecturasAnterioresALaInvalidacion(t *testing.T) {
+	s, _ := nuevoCache(t, time.Minute, 10)
+	key := cacheKey{domain.EntidadOdontologo, 1}
+	_, gen, _ := s.c.get(key)
+	s.c.invalidate(key)
+	s.c.put(key, domain.Odontologo{ApellidoOdontologo: "Viejo"}, gen)
+	if got := leerApellido(t, s, 1); got != "Pérez" {
+		t.Fatalf("una lectura que empezó antes de invalidar no debería guardarse: %q", got)
+	}
+}
+
+func TestCacheStoreWithinTx(t *testing.T) {
+	ctx := context.Background()
+	s, _ := nuevoCache(t, time.Minute, 10)
+	leerApellido(t, s, 1)
+	antes := s.Stats()
+
+	errRollback := errors.New("rollback")
+	err := s.WithinTx(ctx, func(tx StoreInterface) error {
+		renombrar(t, tx, 1, "Sin confirmar")
+		if got := leerApellido(t, tx, 1); got != "Sin confirmar" {
+			t.Fatalf("la transacción debería leer sus propios cambios: %q", got)
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("se esperaba el error de fn y se obtuvo %v", err)
+	}
+	if stats := s.Stats(); stats.Hits != antes.Hits || stats.Misses != antes.Misses {
+		t.Fatalf("las lecturas dentro de la transacción no deberían usar el caché: %+v", stats)
+	}
+	if got := leerApellido(t, s, 1); got != "Pérez" {
+		t.Fatalf("una transacción revertida no debería publicarse: %q", got)
+	}
+
+	if err := s.WithinTx(ctx, func(tx StoreInterface) error {
+		renombrar(t, tx, 1, "López")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := leerApellido(t, s, 1); got != "López" {
+		t.Fatalf("después de confirmar se leyó %q", got)
+	}
+}
@@ -0,0 +1,610 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// document es el contenido completo de los stores que no usan una base de
+// datos. Cada colección vive en su propia sección para que las entidades no
+// se pisen entre sí. Las búsquedas que no encuentran el registro devuelven
+// domain.ErrNotFound, igual que el store SQL. Las bajas son lógicas: el
+// registro queda marcado con DeletedAt y sólo aparece en la papelera.
+type document struct {
+	Odontologos []domain.Odontologo `json:"odontologos"`
+	Pacientes   []domain.Paciente   `json:"pacientes"`
+	Turnos      []domain.Turno      `json:"turnos"`
+	Auditoria   []domain.Auditoria  `json:"auditoria"`
+	Agendas     []domain.Agenda     `json:"agendas,omitempty"`
+	Series      []domain.Serie      `json:"series,omitempty"`
+	Secuencias  secuencias          `json:"secuencias"`
+}
+
+// secuencias guarda el último ID asignado en cada colección. Se persisten
+// junto con los datos para que un ID eliminado nunca se vuelva a asignar.
+type secuencias struct {
+	Odontologos int `json:"odontologos"`
+	Pacientes   int `json:"pacientes"`
+	Turnos      int `json:"turnos"`
+	Auditoria   int `json:"auditoria"`
+	Excepciones int `json:"excepciones"`
+	Series      int `json:"series"`
+}
+
+// UnmarshalJSON lee los turnos como domain.TurnosGuardados para aceptar
+// archivos con los IDs en formato anterior.
+func (d *document) UnmarshalJSON(data []byte) error {
+	type plano document
+	aux := struct {
+		*plano
+		Turnos domain.TurnosGuardados `json:"turnos"`
+	}{plano: (*plano)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	d.Turnos = aux.Turnos
+	return nil
+}
+
+// readDocument lee un documento desde path. Un archivo que contiene sólo un
+// arreglo (el formato anterior de odontologos.json) se interpreta como la
+// colección de odontólogos.
+func readDocument(path string) (*document, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{}
+	trimmed := bytes.TrimSpace(file)
+	if len(trimmed) == 0 {
+		return doc, nil
+	}
+	if trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &doc.Odontologos)
+	} else {
+		err = json.Unmarshal(trimmed, doc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc.syncSecuencias()
+	doc.syncVersiones()
+	doc.syncDuraciones()
+	doc.syncEstados()
+	return doc, nil
+}
+
+// syncSecuencias garantiza que cada secuencia sea al menos el mayor ID
+// existente, por ejemplo al leer un archivo sin secuencias.
+func (d *document) syncSecuencias() {
+	for _, o := range d.Odontologos {
+		d.Secuencias.Odontologos = max(d.Secuencias.Odontologos, o.IdOdontologo)
+	}
+	for _, p := range d.Pacientes {
+		d.Secuencias.Pacientes = max(d.Secuencias.Pacientes, p.IdPaciente)
+	}
+	for _, t := range d.Turnos {
+		d.Secuencias.Turnos = max(d.Secuencias.Turnos, t.IdTurno)
+	}
+	for _, a := range d.Auditoria {
+		d.Secuencias.Auditoria = max(d.Secuencias.Auditoria, a.IdAuditoria)
+	}
+	for _, a := range d.Agendas {
+		for _, e := range a.Excepciones {
+			d.Secuencias.Excepciones = max(d.Secuencias.Excepciones, e.IdExcepcion)
+		}
+	}
+	for _, s := range d.Series {
+		d.Secuencias.Series = max(d.Secuencias.Series, s.IdSerie)
+	}
+}
+
+// syncVersiones asigna la versión inicial a los registros guardados antes de
+// que existiera el control de versiones.
+func (d *document) syncVersiones() {
+	for i := range d.Odontologos {
+		d.Odontologos[i].Version = max(d.Odontologos[i].Version, 1)
+	}
+	for i := range d.Pacientes {
+		d.Pacientes[i].Version = max(d.Pacientes[i].Version, 1)
+	}
+	for i := range d.Turnos {
+		d.Turnos[i].Version = max(d.Turnos[i].Version, 1)
+	}
+}
+
+// syncDuraciones asigna la duración por defecto a los turnos guardados antes
+// de que los turnos tuvieran duración.
+func (d *document) syncDuraciones() {
+	for i := range d.Turnos {
+		d.Turnos[i].DuracionMinutos = minutos(d.Turnos[i])
+	}
+}
+
+// syncEstados asigna el estado inicial a los turnos guardados antes de que
+// los turnos tuvieran estado.
+func (d *document) syncEstados() {
+	for i := range d.Turnos {
+		d.Turnos[i].Estado = d.Turnos[i].EstadoActual()
+	}
+}
+
+// minutos devuelve la duración que se guarda para turno: la suya o, si no
+// la indica, la duración por defecto.
+func minutos(turno domain.Turno) int {
+	return int(turno.Duracion() / time.Minute)
+}
+
+// clone devuelve una copia del documento que se puede modificar sin
+// afectar al original.
+func (d *document) clone() *document {
+	c := &document{
+		Odontologos: append([]domain.Odontologo(nil), d.Odontologos...),
+		Pacientes:   append([]domain.Paciente(nil), d.Pacientes...),
+		Turnos:      append([]domain.Turno(nil), d.Turnos...),
+		Auditoria:   append([]domain.Auditoria(nil), d.Auditoria...),
+		Series:      append([]domain.Serie(nil), d.Series...),
+		Secuencias:  d.Secuencias,
+	}
+	for _, a := range d.Agendas {
+		c.Agendas = append(c.Agendas, cloneAgenda(a))
+	}
+	return c
+}
+
+// cloneAgenda copia la agenda con sus listas, que nunca quedan en nil.
+func cloneAgenda(a domain.Agenda) domain.Agenda {
+	a.Horarios = append([]domain.Horario{}, a.Horarios...)
+	a.Excepciones = append([]domain.Excepcion{}, a.Excepciones...)
+	return a
+}
+
+// deletedNow devuelve la marca de baja lógica para el momento actual. Las
+// bajas en cascada comparten la marca del registro padre, lo que permite
+// restaurar exactamente los turnos que se dieron de baja con él.
+func deletedNow() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+func (d *document) readOdontologo(id int) (domain.Odontologo, error) {
+	for _, o := range d.Odontologos {
+		if o.IdOdontologo == id && o.DeletedAt == "" {
+			return o, nil
+		}
+	}
+	return domain.Odontologo{}, odontologoNotFound(id)
+}
+
+func (d *document) createOdontologo(odontologo domain.Odontologo) (domain.Odontologo, error) {
+	if err := d.checkMatricula(odontologo); err != nil {
+		return domain.Odontologo{}, err
+	}
+	d.Secuencias.Odontologos++
+	odontologo.IdOdontologo = d.Secuencias.Odontologos
+	odontologo.Version = 1
+	odontologo.DeletedAt = ""
+	d.Odontologos = append(d.Odontologos, odontologo)
+	return odontologo, nil
+}
+
+func (d *document) updateOdontologo(odontologo domain.Odontologo) (domain.Odontologo, error) {
+	if err := d.checkMatricula(odontologo); err != nil {
+		return domain.Odontologo{}, err
+	}
+	for i, o := range d.Odontologos {
+		if o.IdOdontologo == odontologo.IdOdontologo && o.DeletedAt == "" {
+			if odontologo.Version != 0 && odontologo.Version != o.Version {
+				return domain.Odontologo{}, versionMismatch("El odontólogo", odontologo.IdOdontologo, o.Version)
+			}
+			odontologo.Version = o.Version + 1
+			odontologo.DeletedAt = ""
+			d.Odontologos[i] = odontologo
+			return odontologo, nil
+		}
+	}
+	return domain.Odontologo{}, odontologoNotFound(odontologo.IdOdontologo)
+}
+
+func (d *document) deleteOdontologo(id, version int) error {
+	for i, o := range d.Odontologos {
+		if o.IdOdontologo == id && o.DeletedAt == "" {
+			if version != 0 && version != o.Version {
+				return versionMismatch("El odontólogo", id, o.Version)
+			}
+			deletedAt := deletedNow()
+			d.Odontologos[i].DeletedAt = deletedAt
+			d.Odontologos[i].Version++
+			d.deleteTurnosWhere(deletedAt, func(t domain.Turno) bool { return t.IdOdontologo == id })
+			return nil
+		}
+	}
+	return odontologoNotFound(id)
+}
+
+func (d *document) restoreOdontologo(id int) (domain.Odontologo, error) {
+	for i, o := range d.Odontologos {
+		if o.IdOdontologo != id {
+			continue
+		}
+		if o.DeletedAt == "" {
+			return domain.Odontologo{}, notDeleted("El odontólogo", id)
+		}
+		d.Odontologos[i].DeletedAt = ""
+		d.Odontologos[i].Version++
+		d.restoreTurnosWhere(o.DeletedAt, func(t domain.Turno) bool { return t.IdOdontologo == id })
+		return d.Odontologos[i], nil
+	}
+	return domain.Odontologo{}, odontologoNotFound(id)
+}
+
+func (d *document) readPaciente(id int) (domain.Paciente, error) {
+	for _, p := range d.Pacientes {
+		if p.IdPaciente == id && p.DeletedAt == "" {
+			return p, nil
+		}
+	}
+	return domain.Paciente{}, pacienteNotFound(id)
+}
+
+func (d *document) createPaciente(paciente domain.Paciente) (domain.Paciente, error) {
+	if err := d.checkDni(paciente); err != nil {
+		return domain.Paciente{}, err
+	}
+	d.Secuencias.Pacientes++
+	paciente.IdPaciente = d.Secuencias.Pacientes
+	paciente.Version = 1
+	paciente.DeletedAt = ""
+	d.Pacientes = append(d.Pacientes, paciente)
+	return paciente, nil
+}
+
+func (d *document) updatePaciente(paciente domain.Paciente) (domain.Paciente, error) {
+	if err := d.checkDni(paciente); err != nil {
+		return domain.Paciente{}, err
+	}
+	for i, p := range d.Pacientes {
+		if p.IdPaciente == paciente.IdPaciente && p.DeletedAt == "" {
+			if paciente.Version != 0 && paciente.Version != p.Version {
+				return domain.Paciente{}, versionMismatch("El paciente", paciente.IdPaciente, p.Version)
+			}
+			paciente.Version = p.Version + 1
+			paciente.DeletedAt = ""
+			d.Pacientes[i] = paciente
+			return paciente, nil
+		}
+	}
+	return domain.Paciente{}, pacienteNotFound(paciente.IdPaciente)
+}
+
+func (d *document) deletePaciente(id, version int) error {
+	for i, p := range d.Pacientes {
+		if p.IdPaciente == id && p.DeletedAt == "" {
+			if version != 0 && version != p.Version {
+				return versionMismatch("El paciente", id, p.Version)
+			}
+			deletedAt := deletedNow()
+			d.Pacientes[i].DeletedAt = deletedAt
+			d.Pacientes[i].Version++
+			d.deleteTurnosWhere(deletedAt, func(t domain.Turno) bool { return t.IdPaciente == id })
+			return nil
+		}
+	}
+	return pacienteNotFound(id)
+}
+
+func (d *document) restorePaciente(id int) (domain.Paciente, error) {
+	for i, p := range d.Pacientes {
+		if p.IdPaciente != id {
+			continue
+		}
+		if p.DeletedAt == "" {
+			return domain.Paciente{}, notDeleted("El paciente", id)
+		}
+		d.Pacientes[i].DeletedAt = ""
+		d.Pacientes[i].Version++
+		d.restoreTurnosWhere(p.DeletedAt, func(t domain.Turno) bool { return t.IdPaciente == id })
+		return d.Pacientes[i], nil
+	}
+	return domain.Paciente{}, pacienteNotFound(id)
+}
+
+func (d *document) readTurno(id int) (domain.Turno, error) {
+	for _, t := range d.Turnos {
+		if t.IdTurno == id && t.DeletedAt == "" {
+			return t, nil
+		}
+	}
+	return domain.Turno{}, turnoNotFound(id)
+}
+
+func (d *document) createTurno(turno domain.Turno) (domain.Turno, error) {
+	if err := d.checkTurnoRefs(turno); err != nil {
+		return domain.Turno{}, err
+	}
+	turno.IdTurno = 0
+	if err := d.checkSolapamiento(turno); err != nil {
+		return domain.Turno{}, err
+	}
+	d.Secuencias.Turnos++
+	turno.IdTurno = d.Secuencias.Turnos
+	turno.DuracionMinutos = minutos(turno)
+	turno.Estado = turno.EstadoActual()
+	turno.Version = 1
+	turno.DeletedAt = ""
+	d.Turnos = append(d.Turnos, turno)
+	return turno, nil
+}
+
+func (d *document) updateTurno(turno domain.Turno) (domain.Turno, error) {
+	if err := d.checkTurnoRefs(turno); err != nil {
+		return domain.Turno{}, err
+	}
+	if err := d.checkSolapamiento(turno); err != nil {
+		return domain.Turno{}, err
+	}
+	for i, t := range d.Turnos {
+		if t.IdTurno == turno.IdTurno && t.DeletedAt == "" {
+			if turno.Version != 0 && turno.Version != t.Version {
+				return domain.Turno{}, versionMismatch("El turno", turno.IdTurno, t.Version)
+			}
+			turno.IdSerie = t.IdSerie
+			turno.DuracionMinutos = minutos(turno)
+			turno.Estado = turno.EstadoActual()
+			turno.Version = t.Version + 1
+			turno.DeletedAt = ""
+			d.Turnos[i] = turno
+			return turno, nil
+		}
+	}
+	return domain.Turno{}, turnoNotFound(turno.IdTurno)
+}
+
+func (d *document) deleteTurno(id, version int) error {
+	for i, t := range d.Turnos {
+		if t.IdTurno == id && t.DeletedAt == "" {
+			if version != 0 && version != t.Version {
+				return versionMismatch("El turno", id, t.Version)
+			}
+			d.Turnos[i].DeletedAt = deletedNow()
+			d.Turnos[i].Version++
+			return nil
+		}
+	}
+	return turnoNotFound(id)
+}
+
+// restoreTurno exige, como una clave foránea, que el odontólogo y el
+// paciente del turno estén activos, y que el turno no se superponga con
+// otro activo.
+func (d *document) restoreTurno(id int) (domain.Turno, error) {
+	for i, t := range d.Turnos {
+		if t.IdTurno != id {
+			continue
+		}
+		if t.DeletedAt == "" {
+			return domain.Turno{}, notDeleted("El turno", id)
+		}
+		if err := d.checkTurnoRefs(t); err != nil {
+			return domain.Turno{}, err
+		}
+		if err := d.checkSolapamiento(t); err != nil {
+			return domain.Turno{}, err
+		}
+		d.Turnos[i].DeletedAt = ""
+		d.Turnos[i].Version++
+		return d.Turnos[i], nil
+	}
+	return domain.Turno{}, turnoNotFound(id)
+}
+
+func (d *document) readAgenda(id int) domain.Agenda {
+	for _, a := range d.Agendas {
+		if a.IdOdontologo == id {
+			return cloneAgenda(a)
+		}
+	}
+	return cloneAgenda(domain.Agenda{IdOdontologo: id})
+}
+
+// agenda devuelve la agenda guardada del odontólogo id, que debe estar
+// activo, creándola si no tiene.
+func (d *document) agenda(id int) (*domain.Agenda, error) {
+	if _, err := d.readOdontologo(id); err != nil {
+		return nil, err
+	}
+	for i := range d.Agendas {
+		if d.Agendas[i].IdOdontologo == id {
+			return &d.Agendas[i], nil
+		}
+	}
+	d.Agendas = append(d.Agendas, cloneAgenda(domain.Agenda{IdOdontologo: id}))
+	return &d.Agendas[len(d.Agendas)-1], nil
+}
+
+func (d *document) updateHorarios(id int, horarios []domain.Horario) error {
+	agenda, err := d.agenda(id)
+	if err != nil {
+		return err
+	}
+	agenda.Horarios = append([]domain.Horario{}, horarios...)
+	return nil
+}
+
+func (d *document) createExcepcion(excepcion domain.Excepcion) (domain.Excepcion, error) {
+	agenda, err := d.agenda(excepcion.IdOdontologo)
+	if err != nil {
+		return domain.Excepcion{}, err
+	}
+	d.Secuencias.Excepciones++
+	excepcion.IdExcepcion = d.Secuencias.Excepciones
+	agenda.Excepciones = append(agenda.Excepciones, excepcion)
+	return excepcion, nil
+}
+
+func (d *document) deleteExcepcion(idOdontologo, idExcepcion int) error {
+	agenda, err := d.agenda(idOdontologo)
+	if err != nil {
+		return err
+	}
+	for i, e := range agenda.Excepciones {
+		if e.IdExcepcion == idExcepcion {
+			agenda.Excepciones = append(agenda.Excepciones[:i:i], agenda.Excepciones[i+1:]...)
+			return nil
+		}
+	}
+	return excepcionNotFound(idExcepcion)
+}
+
+func (d *document) readSerie(id int) (domain.Serie, error) {
+	for _, s := range d.Series {
+		if s.IdSerie == id {
+			return s, nil
+		}
+	}
+	return domain.Serie{}, serieNotFound(id)
+}
+
+// createSerie exige, como las claves foráneas de la tabla series, que el
+// odontólogo y el paciente estén activos.
+func (d *document) createSerie(serie domain.Serie) (domain.Serie, error) {
+	if err := d.checkTurnoRefs(domain.Turno{IdOdontologo: serie.IdOdontologo, IdPaciente: serie.IdPaciente}); err != nil {
+		return domain.Serie{}, err
+	}
+	d.Secuencias.Series++
+	serie.IdSerie = d.Secuencias.Series
+	d.Series = append(d.Series, serie)
+	return serie, nil
+}
+
+func (d *document) updateSerie(serie domain.Serie) (domain.Serie, error) {
+	if err := d.checkTurnoRefs(domain.Turno{IdOdontologo: serie.IdOdontologo, IdPaciente: serie.IdPaciente}); err != nil {
+		return domain.Serie{}, err
+	}
+	for i, s := range d.Series {
+		if s.IdSerie == serie.IdSerie {
+			d.Series[i] = serie
+			return serie, nil
+		}
+	}
+	return domain.Serie{}, serieNotFound(serie.IdSerie)
+}
+
+// appendAuditoria encadena la entrada a la última del registro.
+func (d *document) appendAuditoria(auditoria domain.Auditoria) domain.Auditoria {
+	d.Secuencias.Auditoria++
+	auditoria.IdAuditoria = d.Secuencias.Auditoria
+	auditoria.HashAnterior = ""
+	if n := len(d.Auditoria); n > 0 {
+		auditoria.HashAnterior = d.Auditoria[n-1].Hash
+	}
+	auditoria.Hash = auditoria.CalcularHash()
+	d.Auditoria = append(d.Auditoria, auditoria)
+	return auditoria
+}
+
+// importRespaldo reemplaza el contenido vacío del documento por respaldo.
+func (d *document) importRespaldo(respaldo domain.Respaldo) error {
+	if len(d.Odontologos)+len(d.Pacientes)+len(d.Turnos)+len(d.Auditoria) > 0 {
+		return notEmpty()
+	}
+	d.Odontologos = append([]domain.Odontologo(nil), respaldo.Odontologos...)
+	d.Pacientes = append([]domain.Paciente(nil), respaldo.Pacientes...)
+	d.Turnos = append([]domain.Turno(nil), respaldo.Turnos...)
+	d.Auditoria = append([]domain.Auditoria(nil), respaldo.Auditoria...)
+	d.Series = append([]domain.Serie(nil), respaldo.Series...)
+	d.Agendas = nil
+	for _, a := range respaldo.Agendas {
+		d.Agendas = append(d.Agendas, cloneAgenda(a))
+	}
+	d.syncSecuencias()
+	d.syncVersiones()
+	d.syncDuraciones()
+	d.syncEstados()
+	return nil
+}
+
+// checkMatricula replica el índice único sobre matriculaOdontologo, que
+// también alcanza a los odontólogos dados de baja.
+func (d *document) checkMatricula(odontologo domain.Odontologo) error {
+	for _, o := range d.Odontologos {
+		if o.MatriculaOdontologo == odontologo.MatriculaOdontologo && o.IdOdontologo != odontologo.IdOdontologo {
+			return domain.NewError(domain.ErrDuplicate, "Ya existe un odontólogo con la matrícula %s", odontologo.MatriculaOdontologo)
+		}
+	}
+	return nil
+}
+
+// checkDni replica el índice único sobre dniPaciente, que también alcanza a
+// los pacientes dados de baja.
+func (d *document) checkDni(paciente domain.Paciente) error {
+	for _, p := range d.Pacientes {
+		if p.DniPaciente == paciente.DniPaciente && p.IdPaciente != paciente.IdPaciente {
+			return domain.NewError(domain.ErrDuplicate, "Ya existe un paciente con el DNI %s", paciente.DniPaciente)
+		}
+	}
+	return nil
+}
+
+// checkTurnoRefs replica las claves foráneas de la tabla turnos: el
+// odontólogo y el paciente referenciados deben existir y estar activos, y la
+// serie, si el turno indica una, debe existir.
+func (d *document) checkTurnoRefs(turno domain.Turno) error {
+	if _, err := d.readOdontologo(turno.IdOdontologo); err != nil {
+		return domain.NewError(domain.ErrForeignKey, "El odontólogo %d no existe", turno.IdOdontologo)
+	}
+	if _, err := d.readPaciente(turno.IdPaciente); err != nil {
+		return domain.NewError(domain.ErrForeignKey, "El paciente %d no existe", turno.IdPaciente)
+	}
+	if turno.IdSerie != 0 {
+		if _, err := d.readSerie(turno.IdSerie); err != nil {
+			return domain.NewError(domain.ErrForeignKey, "La serie %d no existe", turno.IdSerie)
+		}
+	}
+	return nil
+}
+
+// checkSolapamiento verifica que turno no se superponga con otro turno
+// activo del mismo odontólogo o del mismo paciente. El propio turno no
+// cuenta, para poder actualizarlo, y los cancelados no ocupan su horario.
+func (d *document) checkSolapamiento(turno domain.Turno) error {
+	if !turno.Ocupa() {
+		return nil
+	}
+	for _, t := range d.Turnos {
+		if t.DeletedAt != "" || t.IdTurno == turno.IdTurno || !t.Ocupa() {
+			continue
+		}
+		if (t.IdOdontologo == turno.IdOdontologo || t.IdPaciente == turno.IdPaciente) && t.SeSuperpone(turno) {
+			return turnoSuperpuesto(turno, t)
+		}
+	}
+	return nil
+}
+
+// deleteTurnosWhere da de baja con la marca deletedAt los turnos activos que
+// cumplen match. Es la cascada de la baja lógica de odontólogos y pacientes.
+func (d *document) deleteTurnosWhere(deletedAt string, match func(t domain.Turno) bool) {
+	for i, t := range d.Turnos {
+		if t.DeletedAt == "" && match(t) {
+			d.Turnos[i].DeletedAt = deletedAt
+			d.Turnos[i].Version++
+		}
+	}
+}
+
+// restoreTurnosWhere deshace deleteTurnosWhere: restaura los turnos con la
+// marca deletedAt que cumplen match, salvo los que siguen referenciando a un
+// odontólogo o paciente dado de baja y los que se superponen con un turno
+// activo.
+func (d *document) restoreTurnosWhere(deletedAt string, match func(t domain.Turno) bool) {
+	for i, t := range d.Turnos {
+		if t.DeletedAt == deletedAt && match(t) && d.checkTurnoRefs(t) == nil && d.checkSolapamiento(t) == nil {
+			d.Turnos[i].DeletedAt = ""
+			d.Turnos[i].Version++
+		}
+	}
+}
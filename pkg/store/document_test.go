@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// nuevoStore crea un store en memoria con n odontólogos y n pacientes, con
+// IDs de 1 a n.
+func nuevoStore(t *testing.T, n int) StoreInterface {
+	t.Helper()
+	ctx := context.Background()
+	s := NewMemoryStore()
+	for i := 1; i <= n; i++ {
+		if _, err := s.Create(ctx, domain.Odontologo{NombreOdontologo: "Ana", ApellidoOdontologo: "Pérez", MatriculaOdontologo: fmt.Sprintf("M-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreatePaciente(ctx, domain.Paciente{NombrePaciente: "Juan", ApellidoPaciente: "Gómez", DomicilioPaciente: "Calle 1", DniPaciente: fmt.Sprint(30000000 + i), FechaDeAltaPaciente: fecha(t, "2030-01-01")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func fecha(t *testing.T, s string) domain.Fecha {
+	t.Helper()
+	f, err := domain.ParseFecha(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestCreateTurnoSolapamiento(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		fecha      string
+		duracion   int
+		odontologo int
+		paciente   int
+		err        error
+	}{
+		{"mismo odontólogo a la misma hora", "2030-03-04 10:00", 30, 1, 2, domain.ErrConflict},
+		{"mismo odontólogo empieza antes y termina durante", "2030-03-04 09:45", 30, 1, 2, domain.ErrConflict},
+		{"mismo odontólogo empieza durante", "2030-03-04 10:15", 30, 1, 2, domain.ErrConflict},
+		{"mismo odontólogo lo contiene", "2030-03-04 09:00", 120, 1, 2, domain.ErrConflict},
+		{"mismo paciente con otro odontólogo", "2030-03-04 10:10", 10, 2, 1, domain.ErrConflict},
+		{"empieza cuando el otro termina", "2030-03-04 10:30", 30, 1, 1, nil},
+		{"termina cuando el otro empieza", "2030-03-04 09:30", 30, 1, 1, nil},
+		{"otro odontólogo y otro paciente", "2030-03-04 10:00", 30, 2, 2, nil},
+	}
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			ctx := context.Background()
+			s := nuevoStore(t, 2)
+			if _, err := s.CreateTurno(ctx, domain.Turno{DescripcionTurno: "Control", FechaTurno: fecha(t, "2030-03-04 10:00"), DuracionMinutos: 30, IdOdontologo: 1, IdPaciente: 1}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := s.CreateTurno(ctx, domain.Turno{DescripcionTurno: "Limpieza", FechaTurno: fecha(t, c.fecha), DuracionMinutos: c.duracion, IdOdontologo: c.odontologo, IdPaciente: c.paciente})
+			if c.err == nil && err != nil {
+				t.Fatalf("se esperaba crear el turno y falló: %v", err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Fatalf("se esperaba %v y se obtuvo %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestSolapamientoAlModificarYRestaurar(t *testing.T) {
+	ctx := context.Background()
+	s := nuevoStore(t, 2)
+	crear := func(f string) domain.Turno {
+		turno, err := s.CreateTurno(ctx, domain.Turno{DescripcionTurno: "Control", FechaTurno: fecha(t, f), IdOdontologo: 1, IdPaciente: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return turno
+	}
+	a, b := crear("2030-03-04 10:00"), crear("2030-03-04 11:00")
+
+	b.FechaTurno = fecha(t, "2030-03-04 10:15")
+	if _, err := s.UpdateTurno(ctx, b); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("mover un turno sobre otro: se esperaba ErrConflict y se obtuvo %v", err)
+	}
+
+	if err := s.DeleteTurno(ctx, a.IdTurno, 0); err != nil {
+		t.Fatal(err)
+	}
+	b.Version = 0
+	if _, err := s.UpdateTurno(ctx, b); err != nil {
+		t.Fatalf("el turno dado de baja no debería ocupar su horario: %v", err)
+	}
+	if _, err := s.RestoreTurno(ctx, a.IdTurno); !errors.Is(err, domain.ErrConflict) {
+		t.Fatalf("restaurar un turno superpuesto: se esperaba ErrConflict y se obtuvo %v", err)
+	}
+
+	b.Estado, b.MotivoCancelacion = domain.EstadoCancelado, "Viaje"
+	if _, err := s.UpdateTurno(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RestoreTurno(ctx, a.IdTurno); err != nil {
+		t.Fatalf("un turno cancelado no debería ocupar su horario: %v", err)
+	}
+}
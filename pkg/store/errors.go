@@ -0,0 +1,95 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Códigos de error de MySQL que se traducen a errores tipados.
+const (
+	mysqlDuplicateEntry  = 1062
+	mysqlRowIsReferenced = 1451
+	mysqlNoReferencedRow = 1452
+)
+
+// mapError traduce los errores de restricción de MySQL y SQLite a los
+// errores tipados de domain. Cualquier otro error se devuelve sin cambios.
+func mapError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return domain.NewError(domain.ErrDuplicate, "Ya existe un registro con esos datos")
+		case mysqlNoReferencedRow:
+			return domain.NewError(domain.ErrForeignKey, "El odontólogo o paciente indicado no existe")
+		case mysqlRowIsReferenced:
+			return domain.NewError(domain.ErrForeignKey, "El registro está referenciado por otros datos")
+		}
+		return err
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return domain.NewError(domain.ErrDuplicate, "Ya existe un registro con esos datos")
+		case sqlite3.ErrConstraintForeignKey:
+			return domain.NewError(domain.ErrForeignKey, "El odontólogo o paciente indicado no existe")
+		}
+	}
+	return err
+}
+
+func odontologoNotFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "El odontólogo %d no existe", id)
+}
+
+func pacienteNotFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "El paciente %d no existe", id)
+}
+
+func turnoNotFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "El turno %d no existe", id)
+}
+
+func excepcionNotFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "La excepción %d no existe", id)
+}
+
+func serieNotFound(id int) error {
+	return domain.NewError(domain.ErrNotFound, "La serie %d no existe", id)
+}
+
+// turnoSuperpuesto indica que turno se superpone con existente, otro turno
+// del mismo odontólogo o paciente. existente viaja como detalle del error.
+func turnoSuperpuesto(turno, existente domain.Turno) error {
+	quien := fmt.Sprintf("del paciente %d", turno.IdPaciente)
+	if existente.IdOdontologo == turno.IdOdontologo {
+		quien = fmt.Sprintf("del odontólogo %d", turno.IdOdontologo)
+	}
+	return &domain.Error{
+		Kind: domain.ErrConflict,
+		Message: fmt.Sprintf("El turno se superpone con el turno %d %s, de %s a %s",
+			existente.IdTurno, quien, existente.FechaTurno, existente.Fin()),
+		Detalle: existente,
+	}
+}
+
+// versionMismatch indica que entidad (por ejemplo "El turno") cambió desde
+// la versión que se quería modificar.
+func versionMismatch(entidad string, id, current int) error {
+	return domain.NewError(domain.ErrPrecondition, "%s %d fue modificado por otra operación (versión actual %d)", entidad, id, current)
+}
+
+// notDeleted indica que se pidió restaurar un registro que está activo.
+func notDeleted(entidad string, id int) error {
+	return domain.NewError(domain.ErrNotFound, "%s %d no está eliminado", entidad, id)
+}
+
+// notEmpty indica que se quiso importar un respaldo en un store con datos.
+func notEmpty() error {
+	return domain.NewError(domain.ErrDuplicate, "El store de destino ya tiene datos; la restauración necesita uno vacío")
+}
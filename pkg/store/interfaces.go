@@ -1,29 +1,99 @@
 package store
 
-import "github.com/MechiBakker/BE3-FINAL/internal/domain"
+import (
+	"context"
 
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// StoreInterface es el acceso a datos de la aplicación. Update y Delete
+// sólo se aplican si la versión recibida coincide con la guardada y
+// devuelven domain.ErrPrecondition si no; con versión 0 no se controla.
+// Update devuelve la entidad con su nueva versión.
+//
+// Delete, DeletePaciente y DeleteTurno son bajas lógicas: Read y los
+// listados dejan de ver el registro salvo con ListOptions.Deleted. Dar de
+// baja un odontólogo o un paciente da de baja también sus turnos, y
+// restaurarlo los restaura.
 type StoreInterface interface {
-	Read(id int) (domain.Odontologo, error)
+	Read(ctx context.Context, id int) (domain.Odontologo, error)
+
+	Create(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error)
+
+	Update(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error)
+
+	Delete(ctx context.Context, id, version int) error
+
+	Restore(ctx context.Context, id int) (domain.Odontologo, error)
+
+	ListOdontologos(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error)
+
+	ReadPaciente(ctx context.Context, id int) (domain.Paciente, error)
+
+	CreatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error)
+
+	UpdatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error)
+
+	DeletePaciente(ctx context.Context, id, version int) error
+
+	RestorePaciente(ctx context.Context, id int) (domain.Paciente, error)
+
+	ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error)
+
+	ReadTurno(ctx context.Context, id int) (domain.Turno, error)
+
+	CreateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error)
+
+	UpdateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error)
+
+	DeleteTurno(ctx context.Context, id, version int) error
+
+	RestoreTurno(ctx context.Context, id int) (domain.Turno, error)
+
+	ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error)
+
+	// ReadAgenda devuelve los horarios y las excepciones del odontólogo id,
+	// vacíos si no tiene. No controla que el odontólogo exista.
+	ReadAgenda(ctx context.Context, id int) (domain.Agenda, error)
+
+	// UpdateHorarios reemplaza el horario semanal del odontólogo id.
+	UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) error
 
-	Create(odontologo domain.Odontologo) error
+	CreateExcepcion(ctx context.Context, excepcion domain.Excepcion) (domain.Excepcion, error)
 
-	Update(odontologo domain.Odontologo) error
+	DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error
 
-	Delete(id int) error
+	// CreateSerie guarda la regla de una serie. Sus turnos se crean aparte,
+	// con CreateTurno e IdSerie; UpdateTurno nunca cambia el IdSerie de un
+	// turno.
+	CreateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error)
 
-	ReadPaciente(id int) (domain.Paciente, error)
+	ReadSerie(ctx context.Context, id int) (domain.Serie, error)
 
-	CreatePaciente(paciente domain.Paciente) error
+	UpdateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error)
 
-	UpdatePaciente(paciente domain.Paciente) error
+	ListSeries(ctx context.Context, opts domain.ListOptions) ([]domain.Serie, int, error)
 
-	DeletePaciente(id int) error
+	// AppendAuditoria agrega una entrada al final del registro de auditoría.
+	// El store asigna IdAuditoria, HashAnterior y Hash; las entradas no se
+	// pueden modificar ni eliminar.
+	AppendAuditoria(ctx context.Context, auditoria domain.Auditoria) (domain.Auditoria, error)
 
-	ReadTurno(id int) (domain.Turno, error)
+	ListAuditoria(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error)
 
-	CreateTurno(paciente domain.Turno) error
+	// Import carga un respaldo conservando IDs, versiones, bajas y la cadena
+	// de auditoría. El store debe estar vacío; si no, devuelve
+	// domain.ErrDuplicate.
+	Import(ctx context.Context, respaldo domain.Respaldo) error
 
-	UpdateTurno(paciente domain.Turno) error
+	// WithinTx ejecuta fn dentro de una transacción. Todas las operaciones
+	// hechas sobre tx se confirman juntas si fn devuelve nil y se descartan
+	// si devuelve un error. fn no debe usar el store original.
+	WithinTx(ctx context.Context, fn func(tx StoreInterface) error) error
 
-	DeleteTurno(id int) error
+	// View ejecuta fn sobre una vista de sólo lectura en la que todas las
+	// lecturas ven el mismo estado. A diferencia de WithinTx no guarda nada
+	// ni bloquea a otras lecturas. fn no debe escribir sobre v ni usar el
+	// store original.
+	View(ctx context.Context, fn func(v StoreInterface) error) error
 }
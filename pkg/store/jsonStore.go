@@ -1,259 +1,388 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 )
 
 type jsonStore struct {
+	mu         sync.RWMutex
 	pathToFile string
 }
 
-func (s *jsonStore) loadOdontologos() ([]domain.Odontologo, error) {
-	var odontologos []domain.Odontologo
-	file, err := os.ReadFile(s.pathToFile)
-	if err != nil {
-		return nil, err
+// NewJsonStore crea un store respaldado por un único archivo JSON con una
+// sección por colección. Si el archivo no existe se crea vacío.
+func NewJsonStore(path string) StoreInterface {
+	s := &jsonStore{
+		pathToFile: path,
+	}
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = s.save(&document{})
 	}
-	err = json.Unmarshal([]byte(file), &odontologos)
 	if err != nil {
-		return nil, err
+		panic(err)
 	}
-	return odontologos, nil
+	return s
+}
+
+// load lee el documento completo desde el archivo.
+func (s *jsonStore) load() (*document, error) {
+	return readDocument(s.pathToFile)
 }
 
-func (s *jsonStore) saveOdontologos(odontologos []domain.Odontologo) error {
-	bytes, err := json.Marshal(odontologos)
+// save escribe el documento en un archivo temporal del mismo directorio y
+// luego lo renombra sobre el original, de modo que una caída a mitad de la
+// escritura nunca deja el archivo corrupto.
+func (s *jsonStore) save(doc *document) error {
+	data, err := json.MarshalIndent(doc, "", "    ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.pathToFile, bytes, 0644)
-}
-
-func NewJsonStore(path string) StoreInterface {
-	_, err := os.Stat(path)
+	tmp, err := os.CreateTemp(filepath.Dir(s.pathToFile), filepath.Base(s.pathToFile)+".tmp-*")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return &jsonStore{
-		pathToFile: path,
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
 	}
-}
-
-func (s *jsonStore) Read(id int) (domain.Odontologo, error) {
-	odontologos, err := s.loadOdontologos()
-	if err != nil {
-		return domain.Odontologo{}, err
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
 	}
-	for _, odontologo := range odontologos {
-		if odontologo.IdOdontologo == id {
-			return odontologo, nil
-		}
+	if err = tmp.Close(); err != nil {
+		return err
 	}
-	return domain.Odontologo{}, errors.New("El odontólogo no existe")
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.pathToFile)
 }
 
-func (s *jsonStore) Create(odontologo domain.Odontologo) error {
-	odontologos, err := s.loadOdontologos()
+// view ejecuta fn sobre el documento actual sin persistir cambios.
+func (s *jsonStore) view(ctx context.Context, fn func(doc *document) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	doc, err := s.load()
 	if err != nil {
 		return err
 	}
-	odontologo.IdOdontologo = len(odontologos) + 1
-	odontologos = append(odontologos, odontologo)
-	return s.saveOdontologos(odontologos)
+	return fn(doc)
 }
 
-func (s *jsonStore) Update(odontologo domain.Odontologo) error {
-	odontologos, err := s.loadOdontologos()
+// update ejecuta fn sobre el documento actual y, si no hay error, lo persiste.
+func (s *jsonStore) update(ctx context.Context, fn func(doc *document) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	doc, err := s.load()
 	if err != nil {
 		return err
 	}
-	for i, p := range odontologos {
-		if p.IdOdontologo == odontologo.IdOdontologo {
-			odontologos[i] = odontologo
-			return s.saveOdontologos(odontologos)
-		}
+	if err = fn(doc); err != nil {
+		return err
 	}
-	return errors.New("Ha ocurrido un error al actualizar odontólogo")
+	return s.save(doc)
 }
 
-func (s *jsonStore) Delete(id int) error {
-	odontologos, err := s.loadOdontologos()
-	if err != nil {
+func (s *jsonStore) Read(ctx context.Context, id int) (domain.Odontologo, error) {
+	var odontologo domain.Odontologo
+	err := s.view(ctx, func(doc *document) (err error) {
+		odontologo, err = doc.readOdontologo(id)
 		return err
-	}
-	for i, p := range odontologos {
-		if p.IdOdontologo == id {
-			odontologos = append(odontologos[:i], odontologos[i+1:]...)
-			return s.saveOdontologos(odontologos)
-		}
-	}
-	return errors.New("Ha ocurrido un error al eliminar odontólogo")
+	})
+	return odontologo, err
 }
 
-func (s *jsonStore) loadPacientes() ([]domain.Paciente, error) {
-	var pacientes []domain.Paciente
-	file, err := os.ReadFile(s.pathToFile)
+func (s *jsonStore) Create(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		odontologo, err = doc.createOdontologo(odontologo)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return domain.Odontologo{}, err
 	}
-	err = json.Unmarshal([]byte(file), &pacientes)
+	return odontologo, nil
+}
+
+func (s *jsonStore) Update(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		odontologo, err = doc.updateOdontologo(odontologo)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return domain.Odontologo{}, err
 	}
-	return pacientes, nil
+	return odontologo, nil
 }
 
-func (s *jsonStore) savePacientes(pacientes []domain.Paciente) error {
-	bytes, err := json.Marshal(pacientes)
-	if err != nil {
+func (s *jsonStore) Delete(ctx context.Context, id, version int) error {
+	return s.update(ctx, func(doc *document) error {
+		return doc.deleteOdontologo(id, version)
+	})
+}
+
+func (s *jsonStore) Restore(ctx context.Context, id int) (domain.Odontologo, error) {
+	var odontologo domain.Odontologo
+	err := s.update(ctx, func(doc *document) (err error) {
+		odontologo, err = doc.restoreOdontologo(id)
 		return err
-	}
-	return os.WriteFile(s.pathToFile, bytes, 0644)
+	})
+	return odontologo, err
 }
 
-func NewJsonStorePaciente(path string) StoreInterface {
-	_, err := os.Stat(path)
-	if err != nil {
-		panic(err)
-	}
-	return &jsonStore{
-		pathToFile: path,
-	}
+func (s *jsonStore) ReadPaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	var paciente domain.Paciente
+	err := s.view(ctx, func(doc *document) (err error) {
+		paciente, err = doc.readPaciente(id)
+		return err
+	})
+	return paciente, err
 }
 
-func (s *jsonStore) ReadPaciente(id int) (domain.Paciente, error) {
-	pacientes, err := s.loadPacientes()
+func (s *jsonStore) CreatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		paciente, err = doc.createPaciente(paciente)
+		return err
+	})
 	if err != nil {
 		return domain.Paciente{}, err
 	}
-	for _, paciente := range pacientes {
-		if paciente.IdPaciente == id {
-			return paciente, nil
-		}
-	}
-	return domain.Paciente{}, errors.New("El paciente no existe")
+	return paciente, nil
 }
 
-func (s *jsonStore) CreatePaciente(paciente domain.Paciente) error {
-	pacientes, err := s.loadPacientes()
-	if err != nil {
+func (s *jsonStore) UpdatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		paciente, err = doc.updatePaciente(paciente)
 		return err
+	})
+	if err != nil {
+		return domain.Paciente{}, err
 	}
-	paciente.IdPaciente = len(pacientes) + 1
-	pacientes = append(pacientes, paciente)
-	return s.savePacientes(pacientes)
+	return paciente, nil
 }
 
-func (s *jsonStore) UpdatePaciente(paciente domain.Paciente) error {
-	pacientes, err := s.loadPacientes()
-	if err != nil {
+func (s *jsonStore) DeletePaciente(ctx context.Context, id, version int) error {
+	return s.update(ctx, func(doc *document) error {
+		return doc.deletePaciente(id, version)
+	})
+}
+
+func (s *jsonStore) RestorePaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	var paciente domain.Paciente
+	err := s.update(ctx, func(doc *document) (err error) {
+		paciente, err = doc.restorePaciente(id)
 		return err
-	}
-	for i, p := range pacientes {
-		if p.IdPaciente == paciente.IdPaciente {
-			pacientes[i] = paciente
-			return s.savePacientes(pacientes)
-		}
-	}
-	return errors.New("Ha ocurrido un error al actualizar paciente")
+	})
+	return paciente, err
 }
 
-func (s *jsonStore) DeletePaciente(id int) error {
-	pacientes, err := s.loadPacientes()
-	if err != nil {
+func (s *jsonStore) ReadTurno(ctx context.Context, id int) (domain.Turno, error) {
+	var turno domain.Turno
+	err := s.view(ctx, func(doc *document) (err error) {
+		turno, err = doc.readTurno(id)
 		return err
-	}
-	for i, p := range pacientes {
-		if p.IdPaciente == id {
-			pacientes = append(pacientes[:i], pacientes[i+1:]...)
-			return s.savePacientes(pacientes)
-		}
-	}
-	return errors.New("Ha ocurrido un error al eliminar paciente")
+	})
+	return turno, err
 }
 
-func (s *jsonStore) loadTurnos() ([]domain.Turno, error) {
-	var turnos []domain.Turno
-	file, err := os.ReadFile(s.pathToFile)
+func (s *jsonStore) CreateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		turno, err = doc.createTurno(turno)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return domain.Turno{}, err
 	}
-	err = json.Unmarshal([]byte(file), &turnos)
+	return turno, nil
+}
+
+func (s *jsonStore) UpdateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		turno, err = doc.updateTurno(turno)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return domain.Turno{}, err
 	}
-	return turnos, nil
+	return turno, nil
 }
 
-func (s *jsonStore) saveTurnos(turnos []domain.Turno) error {
-	bytes, err := json.Marshal(turnos)
-	if err != nil {
+func (s *jsonStore) DeleteTurno(ctx context.Context, id, version int) error {
+	return s.update(ctx, func(doc *document) error {
+		return doc.deleteTurno(id, version)
+	})
+}
+
+func (s *jsonStore) RestoreTurno(ctx context.Context, id int) (domain.Turno, error) {
+	var turno domain.Turno
+	err := s.update(ctx, func(doc *document) (err error) {
+		turno, err = doc.restoreTurno(id)
 		return err
-	}
-	return os.WriteFile(s.pathToFile, bytes, 0644)
+	})
+	return turno, err
 }
 
-func NewJsonStoreTurno(path string) StoreInterface {
-	_, err := os.Stat(path)
+func (s *jsonStore) ListOdontologos(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error) {
+	var odontologos []domain.Odontologo
+	var total int
+	err := s.view(ctx, func(doc *document) (err error) {
+		odontologos, total, err = doc.listOdontologos(filter)
+		return err
+	})
+	return odontologos, total, err
+}
+
+func (s *jsonStore) ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error) {
+	var pacientes []domain.Paciente
+	var total int
+	err := s.view(ctx, func(doc *document) (err error) {
+		pacientes, total, err = doc.listPacientes(filter)
+		return err
+	})
+	return pacientes, total, err
+}
+
+func (s *jsonStore) ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error) {
+	var turnos []domain.Turno
+	var total int
+	err := s.view(ctx, func(doc *document) (err error) {
+		turnos, total, err = doc.listTurnos(filter)
+		return err
+	})
+	return turnos, total, err
+}
+
+func (s *jsonStore) ReadAgenda(ctx context.Context, id int) (domain.Agenda, error) {
+	var agenda domain.Agenda
+	err := s.view(ctx, func(doc *document) error {
+		agenda = doc.readAgenda(id)
+		return nil
+	})
+	return agenda, err
+}
+
+func (s *jsonStore) UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) error {
+	return s.update(ctx, func(doc *document) error {
+		return doc.updateHorarios(id, horarios)
+	})
+}
+
+func (s *jsonStore) CreateExcepcion(ctx context.Context, excepcion domain.Excepcion) (domain.Excepcion, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		excepcion, err = doc.createExcepcion(excepcion)
+		return err
+	})
 	if err != nil {
-		panic(err)
-	}
-	return &jsonStore{
-		pathToFile: path,
+		return domain.Excepcion{}, err
 	}
+	return excepcion, nil
+}
+
+func (s *jsonStore) DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error {
+	return s.update(ctx, func(doc *document) error {
+		return doc.deleteExcepcion(idOdontologo, idExcepcion)
+	})
 }
 
-func (s *jsonStore) ReadTurno(id int) (domain.Turno, error) {
-	turnos, err := s.loadTurnos()
+func (s *jsonStore) CreateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		serie, err = doc.createSerie(serie)
+		return err
+	})
 	if err != nil {
-		return domain.Turno{}, err
-	}
-	for _, turno := range turnos {
-		if turno.IdTurno == id {
-			return turno, nil
-		}
+		return domain.Serie{}, err
 	}
-	return domain.Turno{}, errors.New("El turno no existe")
+	return serie, nil
 }
 
-func (s *jsonStore) CreateTurno(turno domain.Turno) error {
-	turnos, err := s.loadTurnos()
-	if err != nil {
+func (s *jsonStore) ReadSerie(ctx context.Context, id int) (domain.Serie, error) {
+	var serie domain.Serie
+	err := s.view(ctx, func(doc *document) (err error) {
+		serie, err = doc.readSerie(id)
 		return err
-	}
-	turno.IdTurno = len(turnos) + 1
-	turnos = append(turnos, turno)
-	return s.saveTurnos(turnos)
+	})
+	return serie, err
 }
 
-func (s *jsonStore) UpdateTurno(turno domain.Turno) error {
-	turnos, err := s.loadTurnos()
-	if err != nil {
+func (s *jsonStore) UpdateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error) {
+	err := s.update(ctx, func(doc *document) (err error) {
+		serie, err = doc.updateSerie(serie)
 		return err
+	})
+	if err != nil {
+		return domain.Serie{}, err
 	}
-	for i, p := range turnos {
-		if p.IdTurno == turno.IdTurno {
-			turnos[i] = turno
-			return s.saveTurnos(turnos)
-		}
-	}
-	return errors.New("Ha ocurrido un error al actualizar turno")
+	return serie, nil
 }
 
-func (s *jsonStore) DeleteTurno(id int) error {
-	turnos, err := s.loadTurnos()
-	if err != nil {
+func (s *jsonStore) ListSeries(ctx context.Context, opts domain.ListOptions) ([]domain.Serie, int, error) {
+	var series []domain.Serie
+	var total int
+	err := s.view(ctx, func(doc *document) (err error) {
+		series, total, err = doc.listSeries(opts)
 		return err
+	})
+	return series, total, err
+}
+
+func (s *jsonStore) AppendAuditoria(ctx context.Context, auditoria domain.Auditoria) (domain.Auditoria, error) {
+	err := s.update(ctx, func(doc *document) error {
+		auditoria = doc.appendAuditoria(auditoria)
+		return nil
+	})
+	if err != nil {
+		return domain.Auditoria{}, err
 	}
-	for i, p := range turnos {
-		if p.IdTurno == id {
-			turnos = append(turnos[:i], turnos[i+1:]...)
-			return s.saveTurnos(turnos)
+	return auditoria, nil
+}
+
+func (s *jsonStore) ListAuditoria(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error) {
+	var auditoria []domain.Auditoria
+	var total int
+	err := s.view(ctx, func(doc *document) (err error) {
+		auditoria, total, err = doc.listAuditoria(filter)
+		return err
+	})
+	return auditoria, total, err
+}
+
+func (s *jsonStore) Import(ctx context.Context, respaldo domain.Respaldo) error {
+	return s.update(ctx, func(doc *document) error {
+		return doc.importRespaldo(respaldo)
+	})
+}
+
+// WithinTx aplica fn sobre una copia en memoria del documento y la escribe
+// en el archivo sólo si fn no devuelve error.
+func (s *jsonStore) WithinTx(ctx context.Context, fn func(tx StoreInterface) error) error {
+	return s.update(ctx, func(doc *document) error {
+		tx := newMemoryStore(doc)
+		if err := fn(tx); err != nil {
+			return err
 		}
-	}
-	return errors.New("Ha ocurrido un error al eliminar turno")
+		*doc = *tx.doc
+		return nil
+	})
+}
+
+// View aplica fn sobre el documento leído del archivo sin volver a
+// escribirlo.
+func (s *jsonStore) View(ctx context.Context, fn func(v StoreInterface) error) error {
+	return s.view(ctx, func(doc *document) error {
+		return fn(newMemoryStore(doc))
+	})
 }
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// Campos por los que se puede ordenar cada listado. Las claves son los
+// nombres JSON, que coinciden con los nombres de columna de la base.
+var (
+	odontologoSort = map[string]func(a, b domain.Odontologo) bool{
+		"idOdontologo":        func(a, b domain.Odontologo) bool { return a.IdOdontologo < b.IdOdontologo },
+		"nombreOdontologo":    func(a, b domain.Odontologo) bool { return a.NombreOdontologo < b.NombreOdontologo },
+		"apellidoOdontologo":  func(a, b domain.Odontologo) bool { return a.ApellidoOdontologo < b.ApellidoOdontologo },
+		"matriculaOdontologo": func(a, b domain.Odontologo) bool { return a.MatriculaOdontologo < b.MatriculaOdontologo },
+	}
+	pacienteSort = map[string]func(a, b domain.Paciente) bool{
+		"idPaciente":          func(a, b domain.Paciente) bool { return a.IdPaciente < b.IdPaciente },
+		"nombrePaciente":      func(a, b domain.Paciente) bool { return a.NombrePaciente < b.NombrePaciente },
+		"apellidoPaciente":    func(a, b domain.Paciente) bool { return a.ApellidoPaciente < b.ApellidoPaciente },
+		"dniPaciente":         func(a, b domain.Paciente) bool { return a.DniPaciente < b.DniPaciente },
+		"fechaDeAltaPaciente": func(a, b domain.Paciente) bool { return a.FechaDeAltaPaciente.Before(b.FechaDeAltaPaciente) },
+	}
+	turnoSort = map[string]func(a, b domain.Turno) bool{
+		"idTurno":      func(a, b domain.Turno) bool { return a.IdTurno < b.IdTurno },
+		"fechaTurno":   func(a, b domain.Turno) bool { return a.FechaTurno.Before(b.FechaTurno) },
+		"idOdontologo": func(a, b domain.Turno) bool { return a.IdOdontologo < b.IdOdontologo },
+		"idPaciente":   func(a, b domain.Turno) bool { return a.IdPaciente < b.IdPaciente },
+	}
+	serieSort = map[string]func(a, b domain.Serie) bool{
+		"idSerie": func(a, b domain.Serie) bool { return a.IdSerie < b.IdSerie },
+	}
+	auditoriaSort = map[string]func(a, b domain.Auditoria) bool{
+		"idAuditoria": func(a, b domain.Auditoria) bool { return a.IdAuditoria < b.IdAuditoria },
+		"fecha":       func(a, b domain.Auditoria) bool { return a.Fecha < b.Fecha },
+		"entidad":     func(a, b domain.Auditoria) bool { return a.Entidad < b.Entidad },
+		"actor":       func(a, b domain.Auditoria) bool { return a.Actor < b.Actor },
+	}
+)
+
+// sortField valida el campo de orden pedido y devuelve defaultField si no
+// se pidió ninguno.
+func sortField[T any](fields map[string]func(a, b T) bool, opts domain.ListOptions, defaultField string) (string, error) {
+	if opts.Sort == "" {
+		return defaultField, nil
+	}
+	if _, ok := fields[opts.Sort]; !ok {
+		return "", domain.NewError(domain.ErrValidation, "No se puede ordenar por %q", opts.Sort)
+	}
+	return opts.Sort, nil
+}
+
+// sortAndPage ordena items según opts y devuelve la página pedida.
+func sortAndPage[T any](items []T, fields map[string]func(a, b T) bool, opts domain.ListOptions, defaultField string) ([]T, error) {
+	field, err := sortField(fields, opts, defaultField)
+	if err != nil {
+		return nil, err
+	}
+	less := fields[field]
+	sort.SliceStable(items, func(i, j int) bool {
+		if opts.Desc {
+			return less(items[j], items[i])
+		}
+		return less(items[i], items[j])
+	})
+	opts.Normalize()
+	start := min(opts.Offset(), len(items))
+	end := min(start+opts.Limit, len(items))
+	return items[start:end], nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (d *document) listOdontologos(filter domain.OdontologoFilter) ([]domain.Odontologo, int, error) {
+	items := []domain.Odontologo{}
+	for _, o := range d.Odontologos {
+		if (o.DeletedAt != "") != filter.Deleted {
+			continue
+		}
+		if filter.Apellido != "" && !containsFold(o.ApellidoOdontologo, filter.Apellido) {
+			continue
+		}
+		if filter.Matricula != "" && o.MatriculaOdontologo != filter.Matricula {
+			continue
+		}
+		items = append(items, o)
+	}
+	total := len(items)
+	items, err := sortAndPage(items, odontologoSort, filter.ListOptions, "idOdontologo")
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (d *document) listPacientes(filter domain.PacienteFilter) ([]domain.Paciente, int, error) {
+	items := []domain.Paciente{}
+	for _, p := range d.Pacientes {
+		if (p.DeletedAt != "") != filter.Deleted {
+			continue
+		}
+		if filter.Apellido != "" && !containsFold(p.ApellidoPaciente, filter.Apellido) {
+			continue
+		}
+		if filter.Dni != "" && p.DniPaciente != filter.Dni {
+			continue
+		}
+		items = append(items, p)
+	}
+	total := len(items)
+	items, err := sortAndPage(items, pacienteSort, filter.ListOptions, "idPaciente")
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (d *document) listTurnos(filter domain.TurnoFilter) ([]domain.Turno, int, error) {
+	items := []domain.Turno{}
+	for _, t := range d.Turnos {
+		if (t.DeletedAt != "") != filter.Deleted {
+			continue
+		}
+		if filter.IdOdontologo != 0 && t.IdOdontologo != filter.IdOdontologo {
+			continue
+		}
+		if filter.IdPaciente != 0 && t.IdPaciente != filter.IdPaciente {
+			continue
+		}
+		if filter.IdSerie != 0 && t.IdSerie != filter.IdSerie {
+			continue
+		}
+		if !filter.Desde.IsZero() && t.FechaTurno.Before(filter.Desde) {
+			continue
+		}
+		if !filter.Hasta.IsZero() && t.FechaTurno.After(filter.Hasta) {
+			continue
+		}
+		items = append(items, t)
+	}
+	total := len(items)
+	items, err := sortAndPage(items, turnoSort, filter.ListOptions, "fechaTurno")
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (d *document) listSeries(opts domain.ListOptions) ([]domain.Serie, int, error) {
+	items := append([]domain.Serie{}, d.Series...)
+	total := len(items)
+	items, err := sortAndPage(items, serieSort, opts, "idSerie")
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (d *document) listAuditoria(filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error) {
+	items := []domain.Auditoria{}
+	for _, a := range d.Auditoria {
+		if filter.Entidad != "" && a.Entidad != filter.Entidad {
+			continue
+		}
+		if filter.IdEntidad != 0 && a.IdEntidad != filter.IdEntidad {
+			continue
+		}
+		if filter.Actor != "" && a.Actor != filter.Actor {
+			continue
+		}
+		if !filter.Desde.IsZero() && a.Fecha < filter.Desde.String() {
+			continue
+		}
+		if !filter.Hasta.IsZero() && a.Fecha > filter.Hasta.String() {
+			continue
+		}
+		items = append(items, a)
+	}
+	total := len(items)
+	items, err := sortAndPage(items, auditoriaSort, filter.ListOptions, "idAuditoria")
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
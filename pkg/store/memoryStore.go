@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
+)
+
+// memoryStore guarda los datos en memoria. Es seguro para uso concurrente y
+// genera IDs monótonos: un ID eliminado nunca se vuelve a asignar. Las
+// operaciones no bloquean en E/S, por lo que ignoran el contexto recibido.
+type memoryStore struct {
+	mu  sync.RWMutex
+	doc *document
+}
+
+// NewMemoryStore crea un store en memoria vacío.
+func NewMemoryStore() StoreInterface {
+	return newMemoryStore(&document{})
+}
+
+// NewMemoryStoreFromFile crea un store en memoria cargado con los datos de un
+// archivo con el formato de odontologos.json (un arreglo de odontólogos o un
+// documento con una sección por colección).
+func NewMemoryStoreFromFile(path string) (StoreInterface, error) {
+	doc, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	return newMemoryStore(doc), nil
+}
+
+func newMemoryStore(doc *document) *memoryStore {
+	return &memoryStore{doc: doc}
+}
+
+func (s *memoryStore) Read(ctx context.Context, id int) (domain.Odontologo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.readOdontologo(id)
+}
+
+func (s *memoryStore) Create(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.createOdontologo(odontologo)
+}
+
+func (s *memoryStore) Update(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.updateOdontologo(odontologo)
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.deleteOdontologo(id, version)
+}
+
+func (s *memoryStore) Restore(ctx context.Context, id int) (domain.Odontologo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.restoreOdontologo(id)
+}
+
+func (s *memoryStore) ReadPaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.readPaciente(id)
+}
+
+func (s *memoryStore) CreatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.createPaciente(paciente)
+}
+
+func (s *memoryStore) UpdatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.updatePaciente(paciente)
+}
+
+func (s *memoryStore) DeletePaciente(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.deletePaciente(id, version)
+}
+
+func (s *memoryStore) RestorePaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.restorePaciente(id)
+}
+
+func (s *memoryStore) ReadTurno(ctx context.Context, id int) (domain.Turno, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.readTurno(id)
+}
+
+func (s *memoryStore) CreateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.createTurno(turno)
+}
+
+func (s *memoryStore) UpdateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.updateTurno(turno)
+}
+
+func (s *memoryStore) DeleteTurno(ctx context.Context, id, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.deleteTurno(id, version)
+}
+
+func (s *memoryStore) RestoreTurno(ctx context.Context, id int) (domain.Turno, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.restoreTurno(id)
+}
+
+func (s *memoryStore) ListOdontologos(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.listOdontologos(filter)
+}
+
+func (s *memoryStore) ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.listPacientes(filter)
+}
+
+func (s *memoryStore) ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.listTurnos(filter)
+}
+
+func (s *memoryStore) ReadAgenda(ctx context.Context, id int) (domain.Agenda, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.readAgenda(id), nil
+}
+
+func (s *memoryStore) UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.updateHorarios(id, horarios)
+}
+
+func (s *memoryStore) CreateExcepcion(ctx context.Context, excepcion domain.Excepcion) (domain.Excepcion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.createExcepcion(excepcion)
+}
+
+func (s *memoryStore) DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.deleteExcepcion(idOdontologo, idExcepcion)
+}
+
+func (s *memoryStore) CreateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.createSerie(serie)
+}
+
+func (s *memoryStore) ReadSerie(ctx context.Context, id int) (domain.Serie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.readSerie(id)
+}
+
+func (s *memoryStore) UpdateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.updateSerie(serie)
+}
+
+func (s *memoryStore) ListSeries(ctx context.Context, opts domain.ListOptions) ([]domain.Serie, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.listSeries(opts)
+}
+
+func (s *memoryStore) AppendAuditoria(ctx context.Context, auditoria domain.Auditoria) (domain.Auditoria, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.appendAuditoria(auditoria), nil
+}
+
+func (s *memoryStore) ListAuditoria(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.doc.listAuditoria(filter)
+}
+
+func (s *memoryStore) Import(ctx context.Context, respaldo domain.Respaldo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.importRespaldo(respaldo)
+}
+
+// WithinTx aplica fn sobre una copia de los datos y la reemplaza por la
+// original sólo si fn no devuelve error. Mientras fn corre, el resto de las
+// operaciones sobre el store esperan.
+func (s *memoryStore) WithinTx(ctx context.Context, fn func(tx StoreInterface) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := newMemoryStore(s.doc.clone())
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.doc = tx.doc
+	return nil
+}
+
+// View aplica fn sobre los datos actuales sin copiarlos. Mientras fn corre,
+// las escrituras sobre el store esperan y las lecturas no.
+func (s *memoryStore) View(ctx context.Context, fn func(v StoreInterface) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(newMemoryStore(s.doc))
+}
@@ -1,213 +1,1201 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 )
 
+// DefaultQueryTimeout es el tiempo máximo que puede tardar cada consulta si
+// no se configura otro con WithQueryTimeout.
+const DefaultQueryTimeout = 5 * time.Second
+
+// SqlOption configura un store creado con NewSqlStore.
+type SqlOption func(*sqlStore)
+
+// WithQueryTimeout fija el tiempo máximo de cada consulta. Con d <= 0 las
+// consultas sólo terminan cuando se cancela el contexto recibido.
+func WithQueryTimeout(d time.Duration) SqlOption {
+	return func(s *sqlStore) {
+		s.timeout = d
+	}
+}
+
+// stmtCache guarda las sentencias preparadas sobre la conexión para que
+// cada consulta se prepare una sola vez y se reutilice entre requests.
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
 type sqlStore struct {
 	db *sql.DB
+	// tx es la transacción en curso dentro de WithinTx, o nil.
+	tx *sql.Tx
+	// missed acumula las consultas que la transacción en curso preparó sin
+	// encontrarlas en stmts; se cachean cuando la transacción termina.
+	missed  *[]string
+	stmts   *stmtCache
+	timeout time.Duration
+	// rowLocks indica si la base admite SELECT ... FOR UPDATE. SQLite no lo
+	// admite ni lo necesita: con una única conexión las transacciones ya
+	// son serializables.
+	rowLocks bool
+}
+
+func NewSqlStore(db *sql.DB, opts ...SqlOption) StoreInterface {
+	s := &sqlStore{
+		db:       db,
+		stmts:    &stmtCache{stmts: map[string]*sql.Stmt{}},
+		timeout:  DefaultQueryTimeout,
+		rowLocks: true,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func NewSqlStore(db *sql.DB) StoreInterface {
-	return &sqlStore{
-		db: db,
+// Close libera las sentencias preparadas. No cierra la conexión.
+func (s *sqlStore) Close() error {
+	s.stmts.mu.Lock()
+	defer s.stmts.mu.Unlock()
+	var errs []error
+	for query, stmt := range s.stmts.stmts {
+		errs = append(errs, stmt.Close())
+		delete(s.stmts.stmts, query)
 	}
+	return errors.Join(errs...)
 }
 
-func (s *sqlStore) Create(odontologo domain.Odontologo) error {
-	query := "INSERT INTO odontologos (idOdontologo, nombreOdontologo, apellidoOdontologo, matriculaOdontologo) VALUES (?, ?, ?, ?);"
-	stmt, err := s.db.Prepare(query)
+func (s *sqlStore) WithinTx(ctx context.Context, fn func(tx StoreInterface) error) error {
+	if s.tx != nil {
+		// Ya estamos dentro de una transacción: fn se suma a ella.
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	fmt.Println(odontologo)
-	res, err := stmt.Exec(odontologo.IdOdontologo, odontologo.NombreOdontologo, odontologo.ApellidoOdontologo, odontologo.MatriculaOdontologo)
-	if err != nil {
-		fmt.Println(err)
+	txStore := *s
+	txStore.tx, txStore.missed = tx, new([]string)
+	defer func() { s.prepareMissed(ctx, *txStore.missed) }()
+	if err = fn(&txStore); err != nil {
+		tx.Rollback()
 		return err
 	}
-	fmt.Println(res)
-	_, err = res.RowsAffected()
+	return tx.Commit()
+}
+
+// View ejecuta fn en una transacción de sólo lectura que siempre se
+// descarta. Dentro de una transacción en curso fn se suma a ella.
+func (s *sqlStore) View(ctx context.Context, fn func(v StoreInterface) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	if err != nil {
 		return err
 	}
-	return nil
+	txStore := *s
+	txStore.tx, txStore.missed = tx, new([]string)
+	defer func() { s.prepareMissed(ctx, *txStore.missed) }()
+	defer tx.Rollback()
+	return fn(&txStore)
 }
 
-func (s *sqlStore) Read(id int) (domain.Odontologo, error) {
-	var odontologo domain.Odontologo
-	query := "SELECT * FROM odontologos WHERE idOdontologo = ?;"
-	row := s.db.QueryRow(query, id)
-	err := row.Scan(&odontologo.IdOdontologo, &odontologo.NombreOdontologo, &odontologo.ApellidoOdontologo, &odontologo.MatriculaOdontologo)
+// forUpdate devuelve el sufijo que bloquea las filas leídas hasta el fin de
+// la transacción, o "" si la base no lo admite.
+func (s *sqlStore) forUpdate() string {
+	if s.rowLocks {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+// withTimeout limita ctx al tiempo máximo de una consulta.
+func (s *sqlStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// prepare devuelve la sentencia preparada para query, preparándola la
+// primera vez. Dentro de una transacción usa la conexión de ésta: una
+// sentencia ya cacheada se liga a la transacción y una nueva se prepara sobre
+// ella y queda en missed para cachearse al terminar, ya que pedir otra
+// conexión mientras la transacción retiene la suya podría bloquearse (SQLite
+// usa una sola). database/sql cierra ambas al terminar la transacción.
+func (s *sqlStore) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.stmts.mu.Lock()
+	stmt, ok := s.stmts.stmts[query]
+	s.stmts.mu.Unlock()
+	if s.tx != nil {
+		if ok {
+			return s.tx.StmtContext(ctx, stmt), nil
+		}
+		*s.missed = append(*s.missed, query)
+		return s.tx.PrepareContext(ctx, query)
+	}
+	if ok {
+		return stmt, nil
+	}
+	// Se prepara sin tomar el lock para no frenar a otras consultas mientras
+	// se espera una conexión libre.
+	stmt, err := s.db.PrepareContext(ctx, query)
 	if err != nil {
-		return domain.Odontologo{}, err
+		return nil, err
+	}
+	s.stmts.mu.Lock()
+	defer s.stmts.mu.Unlock()
+	if cached, ok := s.stmts.stmts[query]; ok {
+		stmt.Close()
+		return cached, nil
+	}
+	s.stmts.stmts[query] = stmt
+	return stmt, nil
+}
+
+// prepareMissed cachea las consultas que una transacción ya terminada
+// preparó sobre su conexión, para que las próximas las reutilicen. Un error
+// no se informa: la consulta se vuelve a preparar la próxima vez.
+func (s *sqlStore) prepareMissed(ctx context.Context, queries []string) {
+	for _, query := range queries {
+		if ctx.Err() != nil {
+			return
+		}
+		s.prepare(ctx, query)
 	}
-	return odontologo, nil
 }
 
-func (s *sqlStore) Update(odontologo domain.Odontologo) error {
-	query := "UPDATE odontologos SET nombreOdontologo = ?, apellidoOdontologo = ?, matriculaOdontologo = ? WHERE idOdontologo = ?;"
-	stmt, err := s.db.Prepare(query)
+// exec ejecuta una sentencia que no devuelve filas.
+func (s *sqlStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	stmt, err := s.prepare(ctx, query)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	res, err := stmt.Exec(odontologo.NombreOdontologo, odontologo.ApellidoOdontologo, odontologo.MatriculaOdontologo, odontologo.IdOdontologo)
+	res, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
-		return err
+		return nil, mapError(err)
 	}
-	_, err = res.RowsAffected()
+	return res, nil
+}
+
+// queryRow ejecuta una consulta de una sola fila y la escanea en dest.
+func (s *sqlStore) queryRow(ctx context.Context, query string, args []interface{}, dest ...interface{}) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	stmt, err := s.prepare(ctx, query)
 	if err != nil {
 		return err
 	}
-	return nil
+	return stmt.QueryRowContext(ctx, args...).Scan(dest...)
 }
 
-func (s *sqlStore) Delete(id int) error {
-	query := "DELETE FROM odontologos WHERE idOdontologo = ?;"
-	stmt, err := s.db.Prepare(query)
+// query ejecuta una consulta y llama a scan por cada fila.
+func (s *sqlStore) query(ctx context.Context, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	stmt, err := s.prepare(ctx, query)
 	if err != nil {
 		return err
 	}
-	res, err := stmt.Exec(id)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return err
 	}
-	_, err = res.RowsAffected()
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// nullID convierte un ID sin asignar en NULL para que tanto MySQL como
+// SQLite generen el valor autoincremental.
+func nullID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func (s *sqlStore) Create(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	query := "INSERT INTO odontologos (idOdontologo, nombreOdontologo, apellidoOdontologo, matriculaOdontologo) VALUES (?, ?, ?, ?);"
+	res, err := s.exec(ctx, query, nullID(odontologo.IdOdontologo), odontologo.NombreOdontologo, odontologo.ApellidoOdontologo, odontologo.MatriculaOdontologo)
 	if err != nil {
-		return err
+		return domain.Odontologo{}, err
 	}
-	return nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return domain.Odontologo{}, err
+	}
+	odontologo.IdOdontologo = int(id)
+	odontologo.Version = 1
+	return odontologo, nil
 }
 
-func (s *sqlStore) CreatePaciente(paciente domain.Paciente) error {
-	query := "INSERT INTO pacientes (idPaciente, nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente) VALUES (?, ?, ?, ?, ?, ?);"
-	stmt, err := s.db.Prepare(query)
+func (s *sqlStore) Read(ctx context.Context, id int) (domain.Odontologo, error) {
+	var odontologo domain.Odontologo
+	query := "SELECT idOdontologo, nombreOdontologo, apellidoOdontologo, matriculaOdontologo, version, COALESCE(deleted_at, '') FROM odontologos WHERE idOdontologo = ? AND deleted_at IS NULL;"
+	err := s.queryRow(ctx, query, []interface{}{id}, &odontologo.IdOdontologo, &odontologo.NombreOdontologo, &odontologo.ApellidoOdontologo, &odontologo.MatriculaOdontologo, &odontologo.Version, &odontologo.DeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Odontologo{}, odontologoNotFound(id)
+	}
 	if err != nil {
-		return err
+		return domain.Odontologo{}, err
 	}
-	fmt.Println(paciente)
-	res, err := stmt.Exec(paciente.IdPaciente, paciente.NombrePaciente, paciente.ApellidoPaciente, paciente.DomicilioPaciente, paciente.DniPaciente, paciente.FechaDeAltaPaciente)
+	return odontologo, nil
+}
+
+func (s *sqlStore) Update(ctx context.Context, odontologo domain.Odontologo) (domain.Odontologo, error) {
+	query := "UPDATE odontologos SET nombreOdontologo = ?, apellidoOdontologo = ?, matriculaOdontologo = ?, version = version + 1 WHERE idOdontologo = ? AND deleted_at IS NULL AND (? = 0 OR version = ?);"
+	res, err := s.exec(ctx, query, odontologo.NombreOdontologo, odontologo.ApellidoOdontologo, odontologo.MatriculaOdontologo, odontologo.IdOdontologo, odontologo.Version, odontologo.Version)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return domain.Odontologo{}, err
 	}
-	fmt.Println(res)
-	_, err = res.RowsAffected()
+	err = checkVersion(res, "El odontólogo", odontologo.IdOdontologo, func() (int, error) {
+		current, err := s.Read(ctx, odontologo.IdOdontologo)
+		return current.Version, err
+	})
 	if err != nil {
+		return domain.Odontologo{}, err
+	}
+	if odontologo.Version == 0 {
+		return s.Read(ctx, odontologo.IdOdontologo)
+	}
+	odontologo.Version++
+	return odontologo, nil
+}
+
+func (s *sqlStore) Delete(ctx context.Context, id, version int) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		deletedAt := deletedNow()
+		query := "UPDATE odontologos SET deleted_at = ?, version = version + 1 WHERE idOdontologo = ? AND deleted_at IS NULL AND (? = 0 OR version = ?);"
+		res, err := tx.exec(ctx, query, deletedAt, id, version, version)
+		if err != nil {
+			return err
+		}
+		err = checkVersion(res, "El odontólogo", id, func() (int, error) {
+			current, err := tx.Read(ctx, id)
+			return current.Version, err
+		})
+		if err != nil {
+			return err
+		}
+		return tx.deleteTurnosWhere(ctx, "idOdontologo", id, deletedAt)
+	})
+}
+
+func (s *sqlStore) Restore(ctx context.Context, id int) (domain.Odontologo, error) {
+	var odontologo domain.Odontologo
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		deletedAt, err := tx.restoreRow(ctx, "odontologos", "idOdontologo", id, "El odontólogo", odontologoNotFound(id))
+		if err != nil {
+			return err
+		}
+		if err = tx.restoreTurnosWhere(ctx, "idOdontologo", id, deletedAt); err != nil {
+			return err
+		}
+		odontologo, err = tx.Read(ctx, id)
 		return err
+	})
+	if err != nil {
+		return domain.Odontologo{}, err
 	}
-	return nil
+	return odontologo, nil
+}
+
+func (s *sqlStore) CreatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	query := "INSERT INTO pacientes (idPaciente, nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente) VALUES (?, ?, ?, ?, ?, ?);"
+	res, err := s.exec(ctx, query, nullID(paciente.IdPaciente), paciente.NombrePaciente, paciente.ApellidoPaciente, paciente.DomicilioPaciente, paciente.DniPaciente, paciente.FechaDeAltaPaciente)
+	if err != nil {
+		return domain.Paciente{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return domain.Paciente{}, err
+	}
+	paciente.IdPaciente = int(id)
+	paciente.Version = 1
+	return paciente, nil
 }
 
-func (s *sqlStore) ReadPaciente(id int) (domain.Paciente, error) {
+func (s *sqlStore) ReadPaciente(ctx context.Context, id int) (domain.Paciente, error) {
 	var paciente domain.Paciente
-	query := "SELECT * FROM pacientes WHERE idPaciente = ?;"
-	row := s.db.QueryRow(query, id)
-	err := row.Scan(&paciente.IdPaciente, &paciente.NombrePaciente, &paciente.ApellidoPaciente, &paciente.DomicilioPaciente, &paciente.DniPaciente, &paciente.FechaDeAltaPaciente)
+	query := "SELECT idPaciente, nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente, version, COALESCE(deleted_at, '') FROM pacientes WHERE idPaciente = ? AND deleted_at IS NULL;"
+	err := s.queryRow(ctx, query, []interface{}{id}, &paciente.IdPaciente, &paciente.NombrePaciente, &paciente.ApellidoPaciente, &paciente.DomicilioPaciente, &paciente.DniPaciente, &paciente.FechaDeAltaPaciente, &paciente.Version, &paciente.DeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Paciente{}, pacienteNotFound(id)
+	}
 	if err != nil {
 		return domain.Paciente{}, err
 	}
 	return paciente, nil
 }
 
-func (s *sqlStore) UpdatePaciente(paciente domain.Paciente) error {
-	query := "UPDATE pacientes SET nombrePaciente = ?, apellidoPaciente = ?, domicilioPaciente = ?, dniPaciente = ?, fechaDeAltaPaciente = ? WHERE idPaciente = ?;"
-	stmt, err := s.db.Prepare(query)
+func (s *sqlStore) UpdatePaciente(ctx context.Context, paciente domain.Paciente) (domain.Paciente, error) {
+	query := "UPDATE pacientes SET nombrePaciente = ?, apellidoPaciente = ?, domicilioPaciente = ?, dniPaciente = ?, fechaDeAltaPaciente = ?, version = version + 1 WHERE idPaciente = ? AND deleted_at IS NULL AND (? = 0 OR version = ?);"
+	res, err := s.exec(ctx, query, paciente.NombrePaciente, paciente.ApellidoPaciente, paciente.DomicilioPaciente, paciente.DniPaciente, paciente.FechaDeAltaPaciente, paciente.IdPaciente, paciente.Version, paciente.Version)
 	if err != nil {
-		return err
+		return domain.Paciente{}, err
 	}
-	res, err := stmt.Exec(paciente.NombrePaciente, paciente.ApellidoPaciente, paciente.DomicilioPaciente, paciente.DniPaciente, paciente.FechaDeAltaPaciente, paciente.IdPaciente)
+	err = checkVersion(res, "El paciente", paciente.IdPaciente, func() (int, error) {
+		current, err := s.ReadPaciente(ctx, paciente.IdPaciente)
+		return current.Version, err
+	})
 	if err != nil {
+		return domain.Paciente{}, err
+	}
+	if paciente.Version == 0 {
+		return s.ReadPaciente(ctx, paciente.IdPaciente)
+	}
+	paciente.Version++
+	return paciente, nil
+}
+
+func (s *sqlStore) DeletePaciente(ctx context.Context, id, version int) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		deletedAt := deletedNow()
+		query := "UPDATE pacientes SET deleted_at = ?, version = version + 1 WHERE idPaciente = ? AND deleted_at IS NULL AND (? = 0 OR version = ?);"
+		res, err := tx.exec(ctx, query, deletedAt, id, version, version)
+		if err != nil {
+			return err
+		}
+		err = checkVersion(res, "El paciente", id, func() (int, error) {
+			current, err := tx.ReadPaciente(ctx, id)
+			return current.Version, err
+		})
+		if err != nil {
+			return err
+		}
+		return tx.deleteTurnosWhere(ctx, "idPaciente", id, deletedAt)
+	})
+}
+
+func (s *sqlStore) RestorePaciente(ctx context.Context, id int) (domain.Paciente, error) {
+	var paciente domain.Paciente
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		deletedAt, err := tx.restoreRow(ctx, "pacientes", "idPaciente", id, "El paciente", pacienteNotFound(id))
+		if err != nil {
+			return err
+		}
+		if err = tx.restoreTurnosWhere(ctx, "idPaciente", id, deletedAt); err != nil {
+			return err
+		}
+		paciente, err = tx.ReadPaciente(ctx, id)
 		return err
+	})
+	if err != nil {
+		return domain.Paciente{}, err
 	}
-	_, err = res.RowsAffected()
+	return paciente, nil
+}
+
+// turnoColumns son las columnas que se leen de un turno, en el orden de
+// turnoDest.
+const turnoColumns = "idTurno, descripcionTurno, fechaTurno, duracionMinutos, idOdontologo, idPaciente, COALESCE(idSerie, 0), estado, motivoCancelacion, version, COALESCE(deleted_at, '')"
+
+func turnoDest(turno *domain.Turno) []interface{} {
+	return []interface{}{&turno.IdTurno, &turno.DescripcionTurno, &turno.FechaTurno, &turno.DuracionMinutos, &turno.IdOdontologo, &turno.IdPaciente,
+		&turno.IdSerie, &turno.Estado, &turno.MotivoCancelacion, &turno.Version, &turno.DeletedAt}
+}
+
+func (s *sqlStore) CreateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.checkTurnoRefs(ctx, turno); err != nil {
+			return err
+		}
+		if err := tx.checkSolapamiento(ctx, turno); err != nil {
+			return err
+		}
+		query := "INSERT INTO turnos (idTurno, descripcionTurno, fechaTurno, duracionMinutos, idOdontologo, idPaciente, idSerie, estado, motivoCancelacion) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);"
+		res, err := tx.exec(ctx, query, nullID(turno.IdTurno), turno.DescripcionTurno, turno.FechaTurno, minutos(turno), turno.IdOdontologo, turno.IdPaciente,
+			nullID(turno.IdSerie), turno.EstadoActual(), turno.MotivoCancelacion)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		turno.IdTurno = int(id)
+		return nil
+	})
 	if err != nil {
+		return domain.Turno{}, err
+	}
+	turno.DuracionMinutos = minutos(turno)
+	turno.Estado = turno.EstadoActual()
+	turno.Version = 1
+	return turno, nil
+}
+
+func (s *sqlStore) ReadTurno(ctx context.Context, id int) (domain.Turno, error) {
+	var turno domain.Turno
+	query := "SELECT " + turnoColumns + " FROM turnos WHERE idTurno = ? AND deleted_at IS NULL;"
+	err := s.queryRow(ctx, query, []interface{}{id}, turnoDest(&turno)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Turno{}, turnoNotFound(id)
+	}
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return turno, nil
+}
+
+func (s *sqlStore) UpdateTurno(ctx context.Context, turno domain.Turno) (domain.Turno, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.checkTurnoRefs(ctx, turno); err != nil {
+			return err
+		}
+		if err := tx.checkSolapamiento(ctx, turno); err != nil {
+			return err
+		}
+		query := "UPDATE turnos SET descripcionTurno = ?, fechaTurno = ?, duracionMinutos = ?, idOdontologo = ?, idPaciente = ?, estado = ?, motivoCancelacion = ?, version = version + 1" +
+			" WHERE idTurno = ? AND deleted_at IS NULL AND (? = 0 OR version = ?);"
+		res, err := tx.exec(ctx, query, turno.DescripcionTurno, turno.FechaTurno, minutos(turno), turno.IdOdontologo, turno.IdPaciente,
+			turno.EstadoActual(), turno.MotivoCancelacion, turno.IdTurno, turno.Version, turno.Version)
+		if err != nil {
+			return err
+		}
+		err = checkVersion(res, "El turno", turno.IdTurno, func() (int, error) {
+			current, err := tx.ReadTurno(ctx, turno.IdTurno)
+			return current.Version, err
+		})
+		if err != nil {
+			return err
+		}
+		// idSerie no se actualiza: se lee el turno guardado para devolverlo.
+		turno, err = tx.ReadTurno(ctx, turno.IdTurno)
 		return err
+	})
+	if err != nil {
+		return domain.Turno{}, err
 	}
-	return nil
+	return turno, nil
 }
 
-func (s *sqlStore) DeletePaciente(id int) error {
-	query := "DELETE FROM pacientes WHERE idPaciente = ?;"
-	stmt, err := s.db.Prepare(query)
+func (s *sqlStore) DeleteTurno(ctx context.Context, id, version int) error {
+	query := "UPDATE turnos SET deleted_at = ?, version = version + 1 WHERE idTurno = ? AND deleted_at IS NULL AND (? = 0 OR version = ?);"
+	res, err := s.exec(ctx, query, deletedNow(), id, version, version)
 	if err != nil {
 		return err
 	}
-	res, err := stmt.Exec(id)
+	return checkVersion(res, "El turno", id, func() (int, error) {
+		current, err := s.ReadTurno(ctx, id)
+		return current.Version, err
+	})
+}
+
+// RestoreTurno exige que el odontólogo y el paciente del turno estén activos
+// y que el turno no se superponga con otro activo.
+func (s *sqlStore) RestoreTurno(ctx context.Context, id int) (domain.Turno, error) {
+	var turno domain.Turno
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if _, err := tx.restoreRow(ctx, "turnos", "idTurno", id, "El turno", turnoNotFound(id)); err != nil {
+			return err
+		}
+		var err error
+		if turno, err = tx.ReadTurno(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.checkTurnoRefs(ctx, turno); err != nil {
+			return err
+		}
+		return tx.checkSolapamiento(ctx, turno)
+	})
+	if err != nil {
+		return domain.Turno{}, err
+	}
+	return turno, nil
+}
+
+// checkVersion explica por qué una sentencia condicionada a la versión no
+// afectó filas: si el registro no existe devuelve el error de current y si
+// existe, domain.ErrPrecondition con su versión actual. Con MySQL requiere
+// clientFoundRows=true en el DSN para contar las filas encontradas.
+func checkVersion(res sql.Result, entidad string, id int, current func() (int, error)) error {
+	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
-	_, err = res.RowsAffected()
+	if n > 0 {
+		return nil
+	}
+	version, err := current()
 	if err != nil {
 		return err
 	}
-	return nil
+	return versionMismatch(entidad, id, version)
+}
+
+// inTx es WithinTx con acceso a los métodos propios de sqlStore.
+func (s *sqlStore) inTx(ctx context.Context, fn func(tx *sqlStore) error) error {
+	return s.WithinTx(ctx, func(tx StoreInterface) error {
+		return fn(tx.(*sqlStore))
+	})
 }
 
-func (s *sqlStore) CreateTurno(turno domain.Turno) error {
-	query := "INSERT INTO turnos (idTurno, descripcionTurno, fechaTurno, idOdontologo, idPaciente) VALUES (?, ?, ?, ?, ?);"
-	stmt, err := s.db.Prepare(query)
+// deletedCond filtra un listado por registros activos o dados de baja.
+func deletedCond(deleted bool) string {
+	if deleted {
+		return "deleted_at IS NOT NULL"
+	}
+	return "deleted_at IS NULL"
+}
+
+// restoreRow quita la baja lógica de la fila id de table y devuelve la
+// marca que tenía, con la que se restaura su cascada.
+func (s *sqlStore) restoreRow(ctx context.Context, table, pk string, id int, entidad string, notFound error) (string, error) {
+	var deletedAt string
+	err := s.queryRow(ctx, "SELECT COALESCE(deleted_at, '') FROM "+table+" WHERE "+pk+" = ?;", []interface{}{id}, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", notFound
+	}
 	if err != nil {
-		return err
+		return "", err
+	}
+	if deletedAt == "" {
+		return "", notDeleted(entidad, id)
 	}
-	fmt.Println(turno)
-	res, err := stmt.Exec(turno.IdTurno, turno.DescripcionTurno, turno.FechaTurno, turno.IdOdontologo, turno.IdPaciente)
+	_, err = s.exec(ctx, "UPDATE "+table+" SET deleted_at = NULL, version = version + 1 WHERE "+pk+" = ?;", id)
+	return deletedAt, err
+}
+
+// deleteTurnosWhere da de baja con la marca deletedAt los turnos activos
+// cuya columna column vale id. Es la cascada de la baja lógica; las claves
+// foráneas de la tabla sólo actúan ante un borrado real.
+func (s *sqlStore) deleteTurnosWhere(ctx context.Context, column string, id int, deletedAt string) error {
+	query := "UPDATE turnos SET deleted_at = ?, version = version + 1 WHERE " + column + " = ? AND deleted_at IS NULL;"
+	_, err := s.exec(ctx, query, deletedAt, id)
+	return err
+}
+
+// restoreTurnosWhere deshace deleteTurnosWhere salvo para los turnos que
+// siguen referenciando a un odontólogo o paciente dado de baja y los que se
+// superponen con un turno activo. Debe llamarse dentro de una transacción.
+func (s *sqlStore) restoreTurnosWhere(ctx context.Context, column string, id int, deletedAt string) error {
+	var turnos []domain.Turno
+	query := "SELECT " + turnoColumns + " FROM turnos WHERE " + column + " = ? AND deleted_at = ? ORDER BY fechaTurno, idTurno;"
+	err := s.query(ctx, query, []interface{}{id, deletedAt}, func(rows *sql.Rows) error {
+		var turno domain.Turno
+		if err := rows.Scan(turnoDest(&turno)...); err != nil {
+			return err
+		}
+		turnos = append(turnos, turno)
+		return nil
+	})
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-	fmt.Println(res)
-	_, err = res.RowsAffected()
+	for _, turno := range turnos {
+		err := s.checkTurnoRefs(ctx, turno)
+		if err == nil {
+			err = s.checkSolapamiento(ctx, turno)
+		}
+		if errors.Is(err, domain.ErrForeignKey) || errors.Is(err, domain.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.exec(ctx, "UPDATE turnos SET deleted_at = NULL, version = version + 1 WHERE idTurno = ?;", turno.IdTurno); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTurnoRefs completa las claves foráneas de turnos, que no distinguen
+// las bajas lógicas: el odontólogo y el paciente deben estar activos. Dentro
+// de una transacción bloquea además sus filas hasta el final, lo que
+// serializa los cambios de turnos de un mismo odontólogo o paciente mientras
+// checkSolapamiento busca superposiciones.
+func (s *sqlStore) checkTurnoRefs(ctx context.Context, turno domain.Turno) error {
+	var id int
+	query := "SELECT idOdontologo FROM odontologos WHERE idOdontologo = ? AND deleted_at IS NULL" + s.forUpdate() + ";"
+	err := s.queryRow(ctx, query, []interface{}{turno.IdOdontologo}, &id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.NewError(domain.ErrForeignKey, "El odontólogo %d no existe", turno.IdOdontologo)
+	}
 	if err != nil {
 		return err
 	}
+	query = "SELECT idPaciente FROM pacientes WHERE idPaciente = ? AND deleted_at IS NULL" + s.forUpdate() + ";"
+	err = s.queryRow(ctx, query, []interface{}{turno.IdPaciente}, &id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.NewError(domain.ErrForeignKey, "El paciente %d no existe", turno.IdPaciente)
+	}
+	if err != nil || turno.IdSerie == 0 {
+		return err
+	}
+	// SQLite no tiene la clave foránea de idSerie.
+	_, err = s.ReadSerie(ctx, turno.IdSerie)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.NewError(domain.ErrForeignKey, "La serie %d no existe", turno.IdSerie)
+	}
+	return err
+}
+
+// checkSolapamiento verifica que turno no se superponga con otro turno
+// activo del mismo odontólogo o del mismo paciente. Sólo pueden hacerlo los
+// que empiezan antes de que turno termine y menos de DuracionTurnoMaxima
+// antes de que empiece; entre ésos la superposición se calcula con la
+// duración de cada uno, y los cancelados no cuentan. Las lecturas
+// bloqueantes ven los turnos que otras transacciones ya guardaron aunque
+// ésta haya leído antes.
+func (s *sqlStore) checkSolapamiento(ctx context.Context, turno domain.Turno) error {
+	if !turno.Ocupa() {
+		return nil
+	}
+	desde := domain.Fecha(turno.FechaTurno.Time().Add(-domain.DuracionTurnoMaxima * time.Minute))
+	refs := []struct {
+		column string
+		id     int
+	}{{"idOdontologo", turno.IdOdontologo}, {"idPaciente", turno.IdPaciente}}
+	for _, ref := range refs {
+		var candidatos []domain.Turno
+		query := "SELECT " + turnoColumns + " FROM turnos WHERE " + ref.column + " = ? AND idTurno <> ? AND deleted_at IS NULL AND estado <> ?" +
+			" AND fechaTurno > ? AND fechaTurno < ? ORDER BY fechaTurno, idTurno" + s.forUpdate() + ";"
+		err := s.query(ctx, query, []interface{}{ref.id, turno.IdTurno, domain.EstadoCancelado, desde, turno.Fin()}, func(rows *sql.Rows) error {
+			var t domain.Turno
+			if err := rows.Scan(turnoDest(&t)...); err != nil {
+				return err
+			}
+			candidatos = append(candidatos, t)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, t := range candidatos {
+			if t.SeSuperpone(turno) {
+				return turnoSuperpuesto(turno, t)
+			}
+		}
+	}
 	return nil
 }
 
-func (s *sqlStore) ReadTurno(id int) (domain.Turno, error) {
-	var turno domain.Turno
-	query := "SELECT * FROM turnos WHERE idTurno = ?;"
-	row := s.db.QueryRow(query, id)
-	err := row.Scan(&turno.IdTurno, &turno.DescripcionTurno, &turno.FechaTurno, &turno.IdOdontologo, &turno.IdPaciente)
+// whereClause une las condiciones de un listado con AND.
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// orderClause ordena por field y desempata por la clave primaria para que
+// la paginación sea estable. field debe estar validado con sortField.
+func orderClause(field, pk string, desc bool) string {
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", field, dir, pk, dir)
+}
+
+// count devuelve la cantidad de filas de table que cumplen conds.
+func (s *sqlStore) count(ctx context.Context, table string, conds []string, args []interface{}) (int, error) {
+	var total int
+	err := s.queryRow(ctx, "SELECT COUNT(*) FROM "+table+whereClause(conds)+";", args, &total)
+	return total, err
+}
+
+func (s *sqlStore) ListOdontologos(ctx context.Context, filter domain.OdontologoFilter) ([]domain.Odontologo, int, error) {
+	field, err := sortField(odontologoSort, filter.ListOptions, "idOdontologo")
 	if err != nil {
-		return domain.Turno{}, err
+		return nil, 0, err
 	}
-	return turno, nil
+	filter.Normalize()
+	conds := []string{deletedCond(filter.Deleted)}
+	var args []interface{}
+	if filter.Apellido != "" {
+		conds = append(conds, "apellidoOdontologo LIKE ?")
+		args = append(args, "%"+filter.Apellido+"%")
+	}
+	if filter.Matricula != "" {
+		conds = append(conds, "matriculaOdontologo = ?")
+		args = append(args, filter.Matricula)
+	}
+	total, err := s.count(ctx, "odontologos", conds, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := "SELECT idOdontologo, nombreOdontologo, apellidoOdontologo, matriculaOdontologo, version, COALESCE(deleted_at, '') FROM odontologos" +
+		whereClause(conds) + orderClause(field, "idOdontologo", filter.Desc) + " LIMIT ? OFFSET ?;"
+	odontologos := []domain.Odontologo{}
+	err = s.query(ctx, query, append(args, filter.Limit, filter.Offset()), func(rows *sql.Rows) error {
+		var odontologo domain.Odontologo
+		if err := rows.Scan(&odontologo.IdOdontologo, &odontologo.NombreOdontologo, &odontologo.ApellidoOdontologo, &odontologo.MatriculaOdontologo, &odontologo.Version, &odontologo.DeletedAt); err != nil {
+			return err
+		}
+		odontologos = append(odontologos, odontologo)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return odontologos, total, nil
 }
 
-func (s *sqlStore) UpdateTurno(turno domain.Turno) error {
-	query := "UPDATE turnos SET descripcionTurno = ?, fechaTurno = ?, idOdontologo = ?, idPaciente = ? WHERE idTurno = ?;"
-	stmt, err := s.db.Prepare(query)
+func (s *sqlStore) ListPacientes(ctx context.Context, filter domain.PacienteFilter) ([]domain.Paciente, int, error) {
+	field, err := sortField(pacienteSort, filter.ListOptions, "idPaciente")
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	filter.Normalize()
+	conds := []string{deletedCond(filter.Deleted)}
+	var args []interface{}
+	if filter.Apellido != "" {
+		conds = append(conds, "apellidoPaciente LIKE ?")
+		args = append(args, "%"+filter.Apellido+"%")
 	}
-	res, err := stmt.Exec(turno.DescripcionTurno, turno.FechaTurno, turno.IdOdontologo, turno.IdPaciente, turno.IdTurno)
+	if filter.Dni != "" {
+		conds = append(conds, "dniPaciente = ?")
+		args = append(args, filter.Dni)
+	}
+	total, err := s.count(ctx, "pacientes", conds, args)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	_, err = res.RowsAffected()
+	query := "SELECT idPaciente, nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente, version, COALESCE(deleted_at, '') FROM pacientes" +
+		whereClause(conds) + orderClause(field, "idPaciente", filter.Desc) + " LIMIT ? OFFSET ?;"
+	pacientes := []domain.Paciente{}
+	err = s.query(ctx, query, append(args, filter.Limit, filter.Offset()), func(rows *sql.Rows) error {
+		var paciente domain.Paciente
+		if err := rows.Scan(&paciente.IdPaciente, &paciente.NombrePaciente, &paciente.ApellidoPaciente, &paciente.DomicilioPaciente, &paciente.DniPaciente, &paciente.FechaDeAltaPaciente, &paciente.Version, &paciente.DeletedAt); err != nil {
+			return err
+		}
+		pacientes = append(pacientes, paciente)
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	return nil
+	return pacientes, total, nil
 }
 
-func (s *sqlStore) DeleteTurno(id int) error {
-	query := "DELETE FROM turnos WHERE idTurno = ?;"
-	stmt, err := s.db.Prepare(query)
+func (s *sqlStore) ListTurnos(ctx context.Context, filter domain.TurnoFilter) ([]domain.Turno, int, error) {
+	field, err := sortField(turnoSort, filter.ListOptions, "fechaTurno")
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	filter.Normalize()
+	conds := []string{deletedCond(filter.Deleted)}
+	var args []interface{}
+	if filter.IdOdontologo != 0 {
+		conds = append(conds, "idOdontologo = ?")
+		args = append(args, filter.IdOdontologo)
 	}
-	res, err := stmt.Exec(id)
+	if filter.IdPaciente != 0 {
+		conds = append(conds, "idPaciente = ?")
+		args = append(args, filter.IdPaciente)
+	}
+	if filter.IdSerie != 0 {
+		conds = append(conds, "idSerie = ?")
+		args = append(args, filter.IdSerie)
+	}
+	if !filter.Desde.IsZero() {
+		conds = append(conds, "fechaTurno >= ?")
+		args = append(args, filter.Desde)
+	}
+	if !filter.Hasta.IsZero() {
+		conds = append(conds, "fechaTurno <= ?")
+		args = append(args, filter.Hasta)
+	}
+	total, err := s.count(ctx, "turnos", conds, args)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	_, err = res.RowsAffected()
+	query := "SELECT " + turnoColumns + " FROM turnos" +
+		whereClause(conds) + orderClause(field, "idTurno", filter.Desc) + " LIMIT ? OFFSET ?;"
+	turnos := []domain.Turno{}
+	err = s.query(ctx, query, append(args, filter.Limit, filter.Offset()), func(rows *sql.Rows) error {
+		var turno domain.Turno
+		if err := rows.Scan(turnoDest(&turno)...); err != nil {
+			return err
+		}
+		turnos = append(turnos, turno)
+		return nil
+	})
 	if err != nil {
+		return nil, 0, err
+	}
+	return turnos, total, nil
+}
+
+func (s *sqlStore) ReadAgenda(ctx context.Context, id int) (domain.Agenda, error) {
+	agenda := domain.Agenda{IdOdontologo: id, Horarios: []domain.Horario{}, Excepciones: []domain.Excepcion{}}
+	query := "SELECT dia, desde, hasta FROM horarios WHERE idOdontologo = ? ORDER BY dia, desde;"
+	err := s.query(ctx, query, []interface{}{id}, func(rows *sql.Rows) error {
+		var h domain.Horario
+		if err := rows.Scan(&h.Dia, &h.Desde, &h.Hasta); err != nil {
+			return err
+		}
+		agenda.Horarios = append(agenda.Horarios, h)
+		return nil
+	})
+	if err != nil {
+		return domain.Agenda{}, err
+	}
+	query = "SELECT idExcepcion, idOdontologo, desde, hasta, motivo FROM excepciones WHERE idOdontologo = ? ORDER BY desde, idExcepcion;"
+	err = s.query(ctx, query, []interface{}{id}, func(rows *sql.Rows) error {
+		var e domain.Excepcion
+		if err := rows.Scan(&e.IdExcepcion, &e.IdOdontologo, &e.Desde, &e.Hasta, &e.Motivo); err != nil {
+			return err
+		}
+		agenda.Excepciones = append(agenda.Excepciones, e)
+		return nil
+	})
+	if err != nil {
+		return domain.Agenda{}, err
+	}
+	return agenda, nil
+}
+
+func (s *sqlStore) UpdateHorarios(ctx context.Context, id int, horarios []domain.Horario) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		if _, err := tx.Read(ctx, id); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM horarios WHERE idOdontologo = ?;", id); err != nil {
+			return err
+		}
+		for _, h := range horarios {
+			if _, err := tx.exec(ctx, "INSERT INTO horarios (idOdontologo, dia, desde, hasta) VALUES (?, ?, ?, ?);", id, h.Dia, h.Desde, h.Hasta); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) CreateExcepcion(ctx context.Context, excepcion domain.Excepcion) (domain.Excepcion, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if _, err := tx.Read(ctx, excepcion.IdOdontologo); err != nil {
+			return err
+		}
+		query := "INSERT INTO excepciones (idExcepcion, idOdontologo, desde, hasta, motivo) VALUES (?, ?, ?, ?, ?);"
+		res, err := tx.exec(ctx, query, nullID(excepcion.IdExcepcion), excepcion.IdOdontologo, excepcion.Desde, excepcion.Hasta, excepcion.Motivo)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		excepcion.IdExcepcion = int(id)
+		return nil
+	})
+	if err != nil {
+		return domain.Excepcion{}, err
+	}
+	return excepcion, nil
+}
+
+func (s *sqlStore) DeleteExcepcion(ctx context.Context, idOdontologo, idExcepcion int) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		if _, err := tx.Read(ctx, idOdontologo); err != nil {
+			return err
+		}
+		res, err := tx.exec(ctx, "DELETE FROM excepciones WHERE idExcepcion = ? AND idOdontologo = ?;", idExcepcion, idOdontologo)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return excepcionNotFound(idExcepcion)
+		}
+		return nil
+	})
+}
+
+// serieColumns son las columnas de una serie, en el orden de serieDest y
+// serieArgs.
+const serieColumns = "idSerie, descripcionTurno, idOdontologo, idPaciente, fechaInicio, duracionMinutos, frecuencia, intervalo, cantidad, hasta"
+
+func serieDest(serie *domain.Serie) []interface{} {
+	return []interface{}{&serie.IdSerie, &serie.DescripcionTurno, &serie.IdOdontologo, &serie.IdPaciente, &serie.FechaInicio,
+		&serie.DuracionMinutos, &serie.Frecuencia, &serie.Intervalo, &serie.Cantidad, &serie.Hasta}
+}
+
+func serieArgs(serie domain.Serie) []interface{} {
+	return []interface{}{nullID(serie.IdSerie), serie.DescripcionTurno, serie.IdOdontologo, serie.IdPaciente, serie.FechaInicio,
+		serie.DuracionMinutos, serie.Frecuencia, serie.Intervalo, serie.Cantidad, serie.Hasta}
+}
+
+// CreateSerie exige, como checkTurnoRefs, que el odontólogo y el paciente
+// estén activos.
+func (s *sqlStore) CreateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.checkTurnoRefs(ctx, domain.Turno{IdOdontologo: serie.IdOdontologo, IdPaciente: serie.IdPaciente}); err != nil {
+			return err
+		}
+		query := "INSERT INTO series (" + serieColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+		res, err := tx.exec(ctx, query, serieArgs(serie)...)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		serie.IdSerie = int(id)
+		return nil
+	})
+	if err != nil {
+		return domain.Serie{}, err
+	}
+	return serie, nil
+}
+
+func (s *sqlStore) ReadSerie(ctx context.Context, id int) (domain.Serie, error) {
+	var serie domain.Serie
+	query := "SELECT " + serieColumns + " FROM series WHERE idSerie = ?;"
+	err := s.queryRow(ctx, query, []interface{}{id}, serieDest(&serie)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Serie{}, serieNotFound(id)
+	}
+	if err != nil {
+		return domain.Serie{}, err
+	}
+	return serie, nil
+}
+
+func (s *sqlStore) UpdateSerie(ctx context.Context, serie domain.Serie) (domain.Serie, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		if err := tx.checkTurnoRefs(ctx, domain.Turno{IdOdontologo: serie.IdOdontologo, IdPaciente: serie.IdPaciente}); err != nil {
+			return err
+		}
+		query := "UPDATE series SET descripcionTurno = ?, idOdontologo = ?, idPaciente = ?, fechaInicio = ?, duracionMinutos = ?, frecuencia = ?, intervalo = ?, cantidad = ?, hasta = ?" +
+			" WHERE idSerie = ?;"
+		res, err := tx.exec(ctx, query, serie.DescripcionTurno, serie.IdOdontologo, serie.IdPaciente, serie.FechaInicio, serie.DuracionMinutos,
+			serie.Frecuencia, serie.Intervalo, serie.Cantidad, serie.Hasta, serie.IdSerie)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return serieNotFound(serie.IdSerie)
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.Serie{}, err
+	}
+	return serie, nil
+}
+
+func (s *sqlStore) ListSeries(ctx context.Context, opts domain.ListOptions) ([]domain.Serie, int, error) {
+	field, err := sortField(serieSort, opts, "idSerie")
+	if err != nil {
+		return nil, 0, err
+	}
+	opts.Normalize()
+	total, err := s.count(ctx, "series", nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := "SELECT " + serieColumns + " FROM series" + orderClause(field, "idSerie", opts.Desc) + " LIMIT ? OFFSET ?;"
+	series := []domain.Serie{}
+	err = s.query(ctx, query, []interface{}{opts.Limit, opts.Offset()}, func(rows *sql.Rows) error {
+		var serie domain.Serie
+		if err := rows.Scan(serieDest(&serie)...); err != nil {
+			return err
+		}
+		series = append(series, serie)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return series, total, nil
+}
+
+func (s *sqlStore) AppendAuditoria(ctx context.Context, auditoria domain.Auditoria) (domain.Auditoria, error) {
+	err := s.inTx(ctx, func(tx *sqlStore) error {
+		// Actualizar la fila de la cadena antes de leerla la bloquea hasta el
+		// fin de la transacción, así dos altas no encadenan al mismo eslabón.
+		if _, err := tx.exec(ctx, "UPDATE auditoria_cadena SET idAuditoria = idAuditoria + 1 WHERE id = 1;"); err != nil {
+			return err
+		}
+		err := tx.queryRow(ctx, "SELECT idAuditoria, hash FROM auditoria_cadena WHERE id = 1;", nil, &auditoria.IdAuditoria, &auditoria.HashAnterior)
+		if err != nil {
+			return err
+		}
+		auditoria.Hash = auditoria.CalcularHash()
+		query := "INSERT INTO auditoria (idAuditoria, fecha, actor, entidad, idEntidad, accion, antes, despues, hashAnterior, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+		_, err = tx.exec(ctx, query, auditoria.IdAuditoria, auditoria.Fecha, auditoria.Actor, auditoria.Entidad, auditoria.IdEntidad,
+			auditoria.Accion, nullJSON(auditoria.Antes), nullJSON(auditoria.Despues), auditoria.HashAnterior, auditoria.Hash)
+		if err != nil {
+			return err
+		}
+		_, err = tx.exec(ctx, "UPDATE auditoria_cadena SET hash = ? WHERE id = 1;", auditoria.Hash)
 		return err
+	})
+	if err != nil {
+		return domain.Auditoria{}, err
 	}
-	return nil
+	return auditoria, nil
+}
+
+func (s *sqlStore) Import(ctx context.Context, respaldo domain.Respaldo) error {
+	return s.inTx(ctx, func(tx *sqlStore) error {
+		var count int
+		query := "SELECT (SELECT COUNT(*) FROM odontologos) + (SELECT COUNT(*) FROM pacientes) + (SELECT COUNT(*) FROM turnos) + (SELECT COUNT(*) FROM auditoria);"
+		if err := tx.queryRow(ctx, query, nil, &count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return notEmpty()
+		}
+		for _, o := range respaldo.Odontologos {
+			query := "INSERT INTO odontologos (idOdontologo, nombreOdontologo, apellidoOdontologo, matriculaOdontologo, version, deleted_at) VALUES (?, ?, ?, ?, ?, ?);"
+			if _, err := tx.exec(ctx, query, o.IdOdontologo, o.NombreOdontologo, o.ApellidoOdontologo, o.MatriculaOdontologo, max(o.Version, 1), nullString(o.DeletedAt)); err != nil {
+				return err
+			}
+		}
+		for _, p := range respaldo.Pacientes {
+			query := "INSERT INTO pacientes (idPaciente, nombrePaciente, apellidoPaciente, domicilioPaciente, dniPaciente, fechaDeAltaPaciente, version, deleted_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+			if _, err := tx.exec(ctx, query, p.IdPaciente, p.NombrePaciente, p.ApellidoPaciente, p.DomicilioPaciente, p.DniPaciente, p.FechaDeAltaPaciente, max(p.Version, 1), nullString(p.DeletedAt)); err != nil {
+				return err
+			}
+		}
+		for _, serie := range respaldo.Series {
+			query := "INSERT INTO series (" + serieColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+			if _, err := tx.exec(ctx, query, serieArgs(serie)...); err != nil {
+				return err
+			}
+		}
+		for _, t := range respaldo.Turnos {
+			query := "INSERT INTO turnos (idTurno, descripcionTurno, fechaTurno, duracionMinutos, idOdontologo, idPaciente, idSerie, estado, motivoCancelacion, version, deleted_at)" +
+				" VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+			if _, err := tx.exec(ctx, query, t.IdTurno, t.DescripcionTurno, t.FechaTurno, minutos(t), t.IdOdontologo, t.IdPaciente,
+				nullID(t.IdSerie), t.EstadoActual(), t.MotivoCancelacion, max(t.Version, 1), nullString(t.DeletedAt)); err != nil {
+				return err
+			}
+		}
+		for _, agenda := range respaldo.Agendas {
+			for _, h := range agenda.Horarios {
+				query := "INSERT INTO horarios (idOdontologo, dia, desde, hasta) VALUES (?, ?, ?, ?);"
+				if _, err := tx.exec(ctx, query, agenda.IdOdontologo, h.Dia, h.Desde, h.Hasta); err != nil {
+					return err
+				}
+			}
+			for _, e := range agenda.Excepciones {
+				query := "INSERT INTO excepciones (idExcepcion, idOdontologo, desde, hasta, motivo) VALUES (?, ?, ?, ?, ?);"
+				if _, err := tx.exec(ctx, query, e.IdExcepcion, agenda.IdOdontologo, e.Desde, e.Hasta, e.Motivo); err != nil {
+					return err
+				}
+			}
+		}
+		for _, a := range respaldo.Auditoria {
+			query := "INSERT INTO auditoria (idAuditoria, fecha, actor, entidad, idEntidad, accion, antes, despues, hashAnterior, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+			_, err := tx.exec(ctx, query, a.IdAuditoria, a.Fecha, a.Actor, a.Entidad, a.IdEntidad, a.Accion, nullJSON(a.Antes), nullJSON(a.Despues), a.HashAnterior, a.Hash)
+			if err != nil {
+				return err
+			}
+		}
+		if n := len(respaldo.Auditoria); n > 0 {
+			last := respaldo.Auditoria[n-1]
+			if _, err := tx.exec(ctx, "UPDATE auditoria_cadena SET idAuditoria = ?, hash = ? WHERE id = 1;", last.IdAuditoria, last.Hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// nullString guarda un texto vacío como NULL.
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// nullJSON guarda un snapshot vacío como NULL.
+func nullJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func (s *sqlStore) ListAuditoria(ctx context.Context, filter domain.AuditoriaFilter) ([]domain.Auditoria, int, error) {
+	field, err := sortField(auditoriaSort, filter.ListOptions, "idAuditoria")
+	if err != nil {
+		return nil, 0, err
+	}
+	filter.Normalize()
+	var conds []string
+	var args []interface{}
+	if filter.Entidad != "" {
+		conds = append(conds, "entidad = ?")
+		args = append(args, filter.Entidad)
+	}
+	if filter.IdEntidad != 0 {
+		conds = append(conds, "idEntidad = ?")
+		args = append(args, filter.IdEntidad)
+	}
+	if filter.Actor != "" {
+		conds = append(conds, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	// fecha se guarda como texto RFC 3339 en UTC, que ordena igual que el
+	// instante que representa.
+	if !filter.Desde.IsZero() {
+		conds = append(conds, "fecha >= ?")
+		args = append(args, filter.Desde.String())
+	}
+	if !filter.Hasta.IsZero() {
+		conds = append(conds, "fecha <= ?")
+		args = append(args, filter.Hasta.String())
+	}
+	total, err := s.count(ctx, "auditoria", conds, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := "SELECT idAuditoria, fecha, actor, entidad, idEntidad, accion, COALESCE(antes, ''), COALESCE(despues, ''), hashAnterior, hash FROM auditoria" +
+		whereClause(conds) + orderClause(field, "idAuditoria", filter.Desc) + " LIMIT ? OFFSET ?;"
+	auditoria := []domain.Auditoria{}
+	err = s.query(ctx, query, append(args, filter.Limit, filter.Offset()), func(rows *sql.Rows) error {
+		var a domain.Auditoria
+		var antes, despues string
+		if err := rows.Scan(&a.IdAuditoria, &a.Fecha, &a.Actor, &a.Entidad, &a.IdEntidad, &a.Accion, &antes, &despues, &a.HashAnterior, &a.Hash); err != nil {
+			return err
+		}
+		if antes != "" {
+			a.Antes = json.RawMessage(antes)
+		}
+		if despues != "" {
+			a.Despues = json.RawMessage(despues)
+		}
+		auditoria = append(auditoria, a)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return auditoria, total, nil
 }
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// OpenSqlite abre (o crea) la base SQLite embebida en path con las claves
+// foráneas habilitadas. Con path ":memory:" la base vive sólo en memoria.
+func OpenSqlite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&cache=shared")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite admite un único escritor; una sola conexión evita errores
+	// "database is locked" y mantiene viva una base en memoria.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// NewSqliteStore devuelve un store sobre una base abierta con OpenSqlite.
+// El esquema lo crea el paquete migrations.
+func NewSqliteStore(db *sql.DB, opts ...SqlOption) StoreInterface {
+	return NewSqlStore(db, append(opts, withoutRowLocks())...)
+}
+
+// withoutRowLocks desactiva SELECT ... FOR UPDATE, que SQLite no admite.
+func withoutRowLocks() SqlOption {
+	return func(s *sqlStore) {
+		s.rowLocks = false
+	}
+}
@@ -1,15 +1,18 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/MechiBakker/BE3-FINAL/internal/domain"
 	"github.com/gin-gonic/gin"
 )
 
 type errorResponse struct {
-	Status  int    `json:"status"`
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Status  int         `json:"status"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 type response struct {
@@ -18,6 +21,20 @@ type response struct {
 	Message string      `json:"message"`
 }
 
+type pagination struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	Total      int `json:"total"`
+	TotalPages int `json:"totalPages"`
+}
+
+type pageResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination pagination  `json:"pagination"`
+	Status     int         `json:"status"`
+	Message    string      `json:"message"`
+}
+
 // Success escribe una respuesta exitosa
 func Success(ctx *gin.Context, status int, data interface{}, msg string) {
 	ctx.JSON(status, response{
@@ -27,11 +44,54 @@ func Success(ctx *gin.Context, status int, data interface{}, msg string) {
 	})
 }
 
-// Failure escribe una respuesta fallida
+// SuccessPage escribe una respuesta exitosa con una página de un listado
+// y los datos de paginación.
+func SuccessPage(ctx *gin.Context, status int, data interface{}, page, limit, total int, msg string) {
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (total + limit - 1) / limit
+	}
+	ctx.JSON(status, pageResponse{
+		Data: data,
+		Pagination: pagination{
+			Page:       page,
+			Limit:      limit,
+			Total:      total,
+			TotalPages: totalPages,
+		},
+		Status:  status,
+		Message: msg,
+	})
+}
+
+// Failure escribe una respuesta fallida. Si err es uno de los errores
+// tipados de domain el código HTTP se deriva de él; si no, se usa status.
+// El Detalle de un *domain.Error se devuelve en data.
 func Failure(ctx *gin.Context, status int, err error) {
-	ctx.JSON(status, errorResponse{
+	status = statusFor(err, status)
+	resp := errorResponse{
 		Message: err.Error(),
 		Status:  status,
 		Code:    http.StatusText(status),
-	})
+	}
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		resp.Data = domainErr.Detalle
+	}
+	ctx.JSON(status, resp)
+}
+
+// statusFor traduce los errores tipados de domain a su código HTTP.
+func statusFor(err error, fallback int) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrDuplicate), errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrForeignKey), errors.Is(err, domain.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrPrecondition):
+		return http.StatusPreconditionFailed
+	}
+	return fallback
 }